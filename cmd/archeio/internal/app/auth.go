@@ -0,0 +1,285 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// tokenClockSkew is how much clock skew we allow when checking token times
+const tokenClockSkew = time.Minute
+
+// withTokenAuth wraps next to require a bearer token from the token service,
+// implementing the docker registry token authentication flow
+// https://distribution.github.io/distribution/spec/auth/token/
+//
+// Requests for a repository require a token with pull access to it,
+// other requests, including the /v2/ check, only require a valid token.
+func withTokenAuth(rc RegistryConfig, verifier *tokenVerifier, next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repository, scope := "", ""
+		if matches := reRepository.FindStringSubmatch(r.URL.Path); len(matches) == 2 {
+			repository = matches[1]
+			scope = "repository:" + repository + ":pull"
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			writeAuthChallenge(w, rc, scope, "")
+			return
+		}
+		claims, err := verifier.Verify(token, time.Now())
+		if err != nil {
+			klog.V(2).InfoS("rejecting invalid token", "path", r.URL.Path, "err", err)
+			writeAuthChallenge(w, rc, scope, "invalid_token")
+			return
+		}
+		if repository != "" && !claims.allows("repository", repository, "pull") {
+			writeAuthChallenge(w, rc, scope, "insufficient_scope")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// writeAuthChallenge writes a 401 pointing the client at the token service
+func writeAuthChallenge(w http.ResponseWriter, rc RegistryConfig, scope, authError string) {
+	challenge := fmt.Sprintf("Bearer realm=%q,service=%q", rc.TokenAuthRealm, rc.TokenAuthService)
+	if scope != "" {
+		challenge += fmt.Sprintf(",scope=%q", scope)
+	}
+	if authError != "" {
+		challenge += fmt.Sprintf(",error=%q", authError)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	// NOTE: OCI does not require this, but docker clients expect it on the /v2/ check
+	w.Header().Set("Docker-Distribution-Api-Version", "registry/2.0")
+	writeError(w, http.StatusUnauthorized, errorCodeUnauthorized, "authentication required")
+}
+
+// tokenClaims are the JWT claims we check in registry tokens
+type tokenClaims struct {
+	Issuer    string        `json:"iss"`
+	Audience  audience      `json:"aud"`
+	ExpiresAt int64         `json:"exp"`
+	NotBefore int64         `json:"nbf"`
+	Access    []tokenAccess `json:"access"`
+}
+
+// tokenAccess is a resource a registry token grants access to
+type tokenAccess struct {
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+}
+
+func (c *tokenClaims) allows(resourceType, name, action string) bool {
+	for _, access := range c.Access {
+		if access.Type == resourceType && access.Name == name && slices.Contains(access.Actions, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// audience is the JWT aud claim, which may be a string or a list of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(b, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+// tokenVerifier verifies registry tokens against keys in a JWKS file
+type tokenVerifier struct {
+	service string
+	// issuer, if set, must match the token issuer
+	issuer string
+	keys   *watchedFile[jsonWebKeySet]
+}
+
+func newTokenVerifier(ctx context.Context, service, issuer, jwksPath string) *tokenVerifier {
+	return &tokenVerifier{
+		service: service,
+		issuer:  issuer,
+		keys:    newWatchedFile(ctx, jwksPath, parseJSONWebKeySet, watchedFileReloadInterval),
+	}
+}
+
+// Verify checks the token signature and claims, returning the claims
+func (v *tokenVerifier) Verify(token string, now time.Time) (*tokenClaims, error) {
+	keys := v.keys.Load()
+	if keys == nil {
+		return nil, errors.New("no keys loaded")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	if err := decodeTokenPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+	signed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !keys.verify(header.KeyID, header.Algorithm, signed[:], signature) {
+		return nil, errors.New("invalid token signature")
+	}
+	claims := &tokenClaims{}
+	if err := decodeTokenPart(parts[1], claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(tokenClockSkew)) {
+		return nil, errors.New("token is expired")
+	}
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0).Add(-tokenClockSkew)) {
+		return nil, errors.New("token is not yet valid")
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return nil, fmt.Errorf("unexpected token issuer: %q", claims.Issuer)
+	}
+	if !slices.Contains(claims.Audience, v.service) {
+		return nil, errors.New("token is not for this service")
+	}
+	return claims, nil
+}
+
+func decodeTokenPart(part string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// jsonWebKeySet is the public keys tokens may be signed with
+// https://datatracker.ietf.org/doc/html/rfc7517
+type jsonWebKeySet struct {
+	// keys by key ID, keys without an ID are stored under ""
+	keys map[string][]crypto.PublicKey
+}
+
+// parseJSONWebKeySet parses the RSA and P-256 EC keys in a JWKS file,
+// other keys are ignored
+func parseJSONWebKeySet(b []byte) (*jsonWebKeySet, error) {
+	var file struct {
+		Keys []struct {
+			KeyType string `json:"kty"`
+			KeyID   string `json:"kid"`
+			Use     string `json:"use"`
+			Curve   string `json:"crv"`
+			N       string `json:"n"`
+			E       string `json:"e"`
+			X       string `json:"x"`
+			Y       string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, err
+	}
+	set := &jsonWebKeySet{keys: map[string][]crypto.PublicKey{}}
+	for _, k := range file.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		switch {
+		case k.KeyType == "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+				return nil, fmt.Errorf("invalid RSA key: %q", k.KeyID)
+			}
+			key = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case k.KeyType == "EC" && k.Curve == "P-256":
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+				return nil, fmt.Errorf("invalid EC key: %q", k.KeyID)
+			}
+			// ecdsa.ParseUncompressedPublicKey validates the point is on the curve
+			ecKey, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+			if err != nil {
+				return nil, fmt.Errorf("invalid EC key %q: %w", k.KeyID, err)
+			}
+			key = ecKey
+		default:
+			continue
+		}
+		set.keys[k.KeyID] = append(set.keys[k.KeyID], key)
+	}
+	if len(set.keys) == 0 {
+		return nil, errors.New("no supported signing keys")
+	}
+	return set, nil
+}
+
+// verify checks signature over the SHA-256 digest with key kid using alg,
+// only RS256 and ES256 are supported
+func (s *jsonWebKeySet) verify(kid, alg string, digest, signature []byte) bool {
+	for _, key := range s.keys[kid] {
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			if alg == "RS256" && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, signature) == nil {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			// JWS ES256 signatures are R || S, each 32 bytes
+			if alg == "ES256" && len(signature) == 64 {
+				r := new(big.Int).SetBytes(signature[:32])
+				sig := new(big.Int).SetBytes(signature[32:])
+				if ecdsa.Verify(key, digest, r, sig) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,306 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTokenKeys are keys for signing test tokens, and their JWKS
+type testTokenKeys struct {
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+	jwks   *jsonWebKeySet
+}
+
+func newTestTokenKeys(t *testing.T) *testTokenKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPoint, err := ecKey.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks, err := json.Marshal(map[string]any{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecPoint[1:33]), "y": b64(ecPoint[33:])},
+			{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := parseJSONWebKeySet(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testTokenKeys{rsaKey: rsaKey, ecKey: ecKey, jwks: set}
+}
+
+// sign returns a token with claims, signed with the key for kid using alg
+func (k *testTokenKeys) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch alg {
+	case "RS256":
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k.rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, k.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestWithTokenAuth(t *testing.T) {
+	keys := newTestTokenKeys(t)
+	rc := RegistryConfig{
+		TokenAuthRealm:   "https://auth.example.com/token",
+		TokenAuthService: "registry.example.com",
+	}
+	verifier := &tokenVerifier{service: rc.TokenAuthService, issuer: "auth.example.com", keys: &watchedFile[jsonWebKeySet]{}}
+	verifier.keys.current.Store(keys.jwks)
+	handler := withTokenAuth(rc, verifier, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	now := time.Now().Unix()
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"iss": "auth.example.com",
+			"aud": "registry.example.com",
+			"exp": now + 300,
+			"nbf": now - 10,
+			"access": []map[string]any{
+				{"type": "repository", "name": "private/pause", "actions": []string{"pull"}},
+			},
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+	testCases := []struct {
+		Name              string
+		Path              string
+		Token             string
+		ExpectedStatus    int
+		ExpectedChallenge string
+	}{
+		{
+			Name:              "/v2/ without token",
+			Path:              "/v2/",
+			ExpectedStatus:    http.StatusUnauthorized,
+			ExpectedChallenge: `Bearer realm="https://auth.example.com/token",service="registry.example.com"`,
+		},
+		{
+			Name:           "/v2/ with token",
+			Path:           "/v2/",
+			Token:          keys.sign(t, "RS256", "rsa", claims(nil)),
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:              "manifest without token",
+			Path:              "/v2/private/pause/manifests/3.9",
+			ExpectedStatus:    http.StatusUnauthorized,
+			ExpectedChallenge: `Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:private/pause:pull"`,
+		},
+		{
+			Name:           "manifest with RS256 token",
+			Path:           "/v2/private/pause/manifests/3.9",
+			Token:          keys.sign(t, "RS256", "rsa", claims(nil)),
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:           "blob with ES256 token",
+			Path:           "/v2/private/pause/blobs/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+			Token:          keys.sign(t, "ES256", "ec", claims(map[string]any{"aud": []string{"other", "registry.example.com"}})),
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:              "other repository",
+			Path:              "/v2/private/etcd/manifests/3.5",
+			Token:             keys.sign(t, "RS256", "rsa", claims(nil)),
+			ExpectedStatus:    http.StatusUnauthorized,
+			ExpectedChallenge: `Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:private/etcd:pull",error="insufficient_scope"`,
+		},
+		{
+			Name:              "expired token",
+			Path:              "/v2/private/pause/manifests/3.9",
+			Token:             keys.sign(t, "RS256", "rsa", claims(map[string]any{"exp": now - 600})),
+			ExpectedStatus:    http.StatusUnauthorized,
+			ExpectedChallenge: `Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:private/pause:pull",error="invalid_token"`,
+		},
+		{
+			Name:           "token not yet valid",
+			Path:           "/v2/",
+			Token:          keys.sign(t, "RS256", "rsa", claims(map[string]any{"nbf": now + 600})),
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "token for another service",
+			Path:           "/v2/",
+			Token:          keys.sign(t, "RS256", "rsa", claims(map[string]any{"aud": "other"})),
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "token from another issuer",
+			Path:           "/v2/",
+			Token:          keys.sign(t, "RS256", "rsa", claims(map[string]any{"iss": "other"})),
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "token signed with the wrong key",
+			Path:           "/v2/",
+			Token:          keys.sign(t, "ES256", "rsa", claims(nil)),
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "unsigned token",
+			Path:           "/v2/",
+			Token:          keys.sign(t, "none", "rsa", claims(nil)),
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "malformed token",
+			Path:           "/v2/",
+			Token:          "e30.e30",
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "malformed token header",
+			Path:           "/v2/",
+			Token:          "!.e30.e30",
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "malformed token signature",
+			Path:           "/v2/",
+			Token:          "e30.e30.!",
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "malformed token claims",
+			Path:           "/v2/",
+			Token:          keys.sign(t, "RS256", "rsa", claims(map[string]any{"exp": "never"})),
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "malformed token audience",
+			Path:           "/v2/",
+			Token:          keys.sign(t, "RS256", "rsa", claims(map[string]any{"aud": 1})),
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "tampered token",
+			Path:           "/v2/private/etcd/manifests/3.5",
+			Token:          strings.Replace(keys.sign(t, "RS256", "rsa", claims(nil)), ".", ".e30", 1),
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest("GET", "http://localhost:8080"+tc.Path, nil)
+			if tc.Token != "" {
+				r.Header.Set("Authorization", "Bearer "+tc.Token)
+			}
+			recorder := httptest.NewRecorder()
+			handler(recorder, r)
+			if tc.ExpectedStatus == http.StatusUnauthorized {
+				checkErrorResponse(t, recorder, http.StatusUnauthorized, errorCodeUnauthorized)
+			} else if recorder.Code != tc.ExpectedStatus {
+				t.Fatalf("expected status: %v, but got status: %v", tc.ExpectedStatus, recorder.Code)
+			}
+			if challenge := recorder.Header().Get("WWW-Authenticate"); tc.ExpectedChallenge != "" && challenge != tc.ExpectedChallenge {
+				t.Fatalf("expected challenge: %q, but got: %q", tc.ExpectedChallenge, challenge)
+			}
+		})
+	}
+}
+
+func TestParseJSONWebKeySetInvalid(t *testing.T) {
+	for _, invalid := range []string{
+		`{"keys": []}`,
+		`{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`,
+		`{"keys": [{"kty": "EC", "crv": "P-256", "x": "AAAA", "y": "AAAA"}]}`,
+		`{"keys": [{"kty": "EC", "crv": "P-256", "x": "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", "y": "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}]}`,
+		`{"keys": [{"kty": "RSA", "n": "AQAB", "e": ""}]}`,
+		`{"keys": [{"kty": "RSA", "use": "enc", "n": "AQAB", "e": "AQAB"}]}`,
+		`not json`,
+	} {
+		if _, err := parseJSONWebKeySet([]byte(invalid)); err == nil {
+			t.Fatalf("expected error for JWKS: %s", invalid)
+		}
+	}
+}
+
+func TestCachingProxyForwardsAuthorization(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(upstream.Close)
+	for _, forward := range []bool{false, true} {
+		p := newCachingProxy(nil)
+		p.forwardAuthorization = forward
+		r := httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/3.9", nil)
+		r.Header.Set("Authorization", "Bearer token")
+		recorder := httptest.NewRecorder()
+		p.Proxy(recorder, r, upstream.URL+"/v2/pause/manifests/3.9", "", "")
+		expectedStatus := http.StatusUnauthorized
+		if forward {
+			expectedStatus = http.StatusOK
+		}
+		if recorder.Code != expectedStatus {
+			t.Fatalf("expected status: %v, but got status: %v", expectedStatus, recorder.Code)
+		}
+	}
+}
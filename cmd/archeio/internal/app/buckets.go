@@ -35,6 +35,9 @@ func awsRegionToHostURL(region, defaultURL string) string {
 	//
 	// As of late 2025, we don't have access to cn-northwest-1 or cn-north-1 regions as they are part of the aws-cn partition.
 	// So we are mapping them to ap-east-1(Hong Kong) for now.
+	//
+	// The "GLOBAL" region (cloudcidrs.GlobalRegion) is not a location, callers
+	// should locate those clients some other way, or they get defaultURL.
 	// aws ec2 describe-regions --all-regions --query "Regions[].RegionName" --output json | jq .[] | awk '{print $0","}' | sort --version-sort
 
 	// Africa (Cape Town)
@@ -154,10 +157,12 @@ type blobChecker interface {
 // should be plenty fast for now, HTTP HEAD on s3 is cheap
 type cachedBlobChecker struct {
 	blobCache
+	// signer, if set, is used to sign requests to private buckets
+	signer urlSigner
 }
 
-func newCachedBlobChecker() *cachedBlobChecker {
-	return &cachedBlobChecker{}
+func newCachedBlobChecker(signer urlSigner) *cachedBlobChecker {
+	return &cachedBlobChecker{signer: signer}
 }
 
 type blobCache struct {
@@ -185,7 +190,16 @@ func (c *cachedBlobChecker) BlobExists(blobURL string) bool {
 		// ensure sensible timeouts
 		Timeout: time.Second * 5,
 	}
-	r, err := client.Head(blobURL)
+	headURL := blobURL
+	if c.signer != nil {
+		signed, err := c.signer.SignURL(http.MethodHead, blobURL)
+		if err != nil {
+			klog.ErrorS(err, "failed to sign blob URL", "url", blobURL)
+			return false
+		}
+		headURL = signed
+	}
+	r, err := client.Head(headURL)
 	// fallback to assuming blob is unavailable on errors
 	if err != nil {
 		klog.V(3).InfoS("failed to check remote blob", "url", blobURL, "err", err)
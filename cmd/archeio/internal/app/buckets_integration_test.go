@@ -32,7 +32,7 @@ import (
 func TestIntegrationCachedBlobChecker(t *testing.T) {
 	t.Parallel()
 	bucket := awsRegionToHostURL("us-east-1", "")
-	blobs := newCachedBlobChecker()
+	blobs := newCachedBlobChecker(nil)
 	testCases := []struct {
 		Name         string
 		BlobURL      string
@@ -97,15 +97,18 @@ func TestIntegrationAllBucketsValid(t *testing.T) {
 	}
 	// iterate all AWS regions and their mapped buckets
 	ipInfos := cloudcidrs.AllIPInfos()
+	// regions appear once per service and border group
+	seenRegions := map[string]bool{}
 	for i := range ipInfos {
 		ipInfo := ipInfos[i]
 		// we only have bucket mappings for AWS currently
 		// otherwise these are the deployed terraform defaults,
 		// which are a subset of the buckets for AWS-external traffic
 		// see also: https://github.com/kubernetes/registry.k8s.io/issues/194
-		if ipInfo.Cloud != cloudcidrs.AWS {
+		if ipInfo.Cloud != cloudcidrs.AWS || seenRegions[ipInfo.Region] {
 			continue
 		}
+		seenRegions[ipInfo.Region] = true
 		// skip regions that aren't mapped and would've used the default
 		baseURL := awsRegionToHostURL(ipInfo.Region, "")
 		if baseURL == "" {
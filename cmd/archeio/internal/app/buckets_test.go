@@ -29,7 +29,7 @@ func TestRegionToAWSRegionToHostURL(t *testing.T) {
 	for _, ipInfo := range cloudcidrs.AllIPInfos() {
 		// AWS regions, excluding "GLOBAL" meta region, AWS US Gov Cloud and European Soveign Cloud
 		if ipInfo.Cloud == cloudcidrs.AWS &&
-			ipInfo.Region != "GLOBAL" && !strings.HasPrefix(ipInfo.Region, "us-gov-") && !strings.HasPrefix(ipInfo.Region, "eusc-") {
+			ipInfo.Region != cloudcidrs.GlobalRegion && !strings.HasPrefix(ipInfo.Region, "us-gov-") && !strings.HasPrefix(ipInfo.Region, "eusc-") {
 			regions = append(regions, ipInfo.Region)
 		}
 	}
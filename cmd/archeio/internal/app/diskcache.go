@@ -0,0 +1,311 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"container/list"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// diskCache is a size bounded, least recently used cache of content
+// addressed registry responses on local disk
+//
+// Entries are stored at $root/$kind/$algorithm/$encoded, along with the
+// response media type at the same path + ".mediatype", where kind is
+// "blobs" or "manifests". Entries are only added after verifying their digest.
+//
+// The cache is rebuilt from disk on startup, with least recently used order
+// approximated by file modification times.
+type diskCache struct {
+	root     string
+	maxBytes int64
+
+	mu   sync.Mutex
+	size int64
+	// lru holds *diskCacheEntry, most recently used first
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type diskCacheEntry struct {
+	key  string
+	size int64
+}
+
+// newDiskCache returns a diskCache at root, loading any existing entries
+func newDiskCache(root string, maxBytes int64) (*diskCache, error) {
+	d := &diskCache{
+		root:     root,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  map[string]*list.Element{},
+	}
+	// incomplete entries from a previous run will never be completed
+	if err := errors.Join(os.RemoveAll(d.tmpDir()), os.MkdirAll(d.tmpDir(), 0o755)); err != nil {
+		return nil, err
+	}
+	if err := d.load(os.DirFS(root)); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.evictLocked()
+	return d, nil
+}
+
+func (d *diskCache) tmpDir() string {
+	return filepath.Join(d.root, ".tmp")
+}
+
+// cacheKey returns the key for digest of kind,
+// or false if digest cannot be cached
+func cacheKey(kind, digest string) (string, bool) {
+	algorithm, _, _ := strings.Cut(digest, ":")
+	if _, ok := digestAlgorithms[algorithm]; !ok || !reDigest.MatchString(digest) {
+		return "", false
+	}
+	return kind + "/" + digest, true
+}
+
+func (d *diskCache) path(key string) string {
+	kind, digest, _ := strings.Cut(key, "/")
+	algorithm, encoded, _ := strings.Cut(digest, ":")
+	return filepath.Join(d.root, kind, algorithm, encoded)
+}
+
+// load populates the cache from existing entries in fsys, the cache root
+func (d *diskCache) load(fsys fs.FS) error {
+	type loadedEntry struct {
+		diskCacheEntry
+		modTime time.Time
+	}
+	loaded := []loadedEntry{}
+	err := fs.WalkDir(fsys, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path == filepath.Base(d.tmpDir()) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(path, ".mediatype") {
+			return nil
+		}
+		// $kind/$algorithm/$encoded
+		parts := strings.Split(path, "/")
+		if len(parts) != 3 {
+			return nil
+		}
+		key, ok := cacheKey(parts[0], parts[1]+":"+parts[2])
+		if !ok {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		loaded = append(loaded, loadedEntry{
+			diskCacheEntry: diskCacheEntry{key: key, size: info.Size()},
+			modTime:        info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(loaded, func(i, j int) bool {
+		return loaded[i].modTime.After(loaded[j].modTime)
+	})
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range loaded {
+		entry := loaded[i].diskCacheEntry
+		d.entries[entry.key] = d.lru.PushBack(&entry)
+		d.size += entry.size
+	}
+	return nil
+}
+
+// Open returns the cached content for key and its media type,
+// or false if it is not cached
+func (d *diskCache) Open(key string) (*os.File, string, bool) {
+	d.mu.Lock()
+	elem, ok := d.entries[key]
+	if ok {
+		d.lru.MoveToFront(elem)
+	}
+	d.mu.Unlock()
+	if !ok {
+		return nil, "", false
+	}
+	path := d.path(key)
+	mediaType, err := os.ReadFile(path + ".mediatype")
+	if err != nil {
+		d.remove(key)
+		return nil, "", false
+	}
+	// #nosec G304 -- keys are validated by cacheKey
+	f, err := os.Open(path)
+	if err != nil {
+		d.remove(key)
+		return nil, "", false
+	}
+	// persist the LRU order for the next startup, this is best effort
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return f, string(mediaType), true
+}
+
+// remove drops key from the cache, if it has been removed from disk
+func (d *diskCache) remove(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if elem, ok := d.entries[key]; ok {
+		d.removeLocked(elem)
+	}
+}
+
+func (d *diskCache) removeLocked(elem *list.Element) {
+	entry := d.lru.Remove(elem).(*diskCacheEntry)
+	delete(d.entries, entry.key)
+	d.size -= entry.size
+	path := d.path(entry.key)
+	for _, p := range []string{path, path + ".mediatype"} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			klog.ErrorS(err, "failed to remove cache entry", "path", p)
+		}
+	}
+}
+
+// evictLocked evicts the least recently used entries until we're within maxBytes
+func (d *diskCache) evictLocked() {
+	for d.size > d.maxBytes && d.lru.Len() != 0 {
+		elem := d.lru.Back()
+		klog.V(3).InfoS("evicting cache entry", "key", elem.Value.(*diskCacheEntry).key)
+		d.removeLocked(elem)
+	}
+}
+
+// Create returns a writer for adding key to the cache, the content is only
+// added once committed, and if it matches the digest in key
+func (d *diskCache) Create(key string) (*diskCacheWriter, error) {
+	_, digest, _ := strings.Cut(key, "/")
+	algorithm, encoded, _ := strings.Cut(digest, ":")
+	newHash, ok := digestAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported digest algorithm: %q", algorithm)
+	}
+	f, err := os.CreateTemp(d.tmpDir(), "entry-")
+	if err != nil {
+		return nil, err
+	}
+	return &diskCacheWriter{
+		cache:    d,
+		key:      key,
+		expected: encoded,
+		f:        f,
+		hash:     newHash(),
+	}, nil
+}
+
+// diskCacheWriter writes a new diskCache entry
+//
+// Writes never fail, so that a failure to cache does not interrupt streaming
+// the content to a client, instead Commit will return the first error.
+type diskCacheWriter struct {
+	cache    *diskCache
+	key      string
+	expected string
+	f        *os.File
+	hash     hash.Hash
+	size     int64
+	err      error
+}
+
+func (w *diskCacheWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return len(p), nil
+	}
+	w.size += int64(len(p))
+	if w.size > w.cache.maxBytes {
+		w.err = errors.New("content is larger than the cache")
+		return len(p), nil
+	}
+	if _, err := w.f.Write(p); err != nil {
+		w.err = err
+		return len(p), nil
+	}
+	_, _ = w.hash.Write(p)
+	return len(p), nil
+}
+
+// Abort discards the content written so far
+func (w *diskCacheWriter) Abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
+
+// Commit adds the content to the cache with mediaType, if it matches the digest
+func (w *diskCacheWriter) Commit(mediaType string) error {
+	defer w.Abort()
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(w.hash.Sum(nil)); actual != w.expected {
+		return fmt.Errorf("content does not match digest for %s", w.key)
+	}
+	path := w.cache.path(w.key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// the media type is written first, so an entry is always complete on disk
+	if err := os.WriteFile(path+".mediatype", []byte(mediaType), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(w.f.Name(), path); err != nil {
+		return err
+	}
+	d := w.cache
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// another request may have cached the same content concurrently
+	if elem, ok := d.entries[w.key]; ok {
+		d.lru.MoveToFront(elem)
+		return nil
+	}
+	d.entries[w.key] = d.lru.PushFront(&diskCacheEntry{key: w.key, size: w.size})
+	d.size += w.size
+	d.evictLocked()
+	return nil
+}
@@ -0,0 +1,358 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func addToCache(t *testing.T, d *diskCache, content string) string {
+	t.Helper()
+	key, ok := cacheKey("blobs", sha256Digest([]byte(content)))
+	if !ok {
+		t.Fatal("expected key to be cacheable")
+	}
+	w, err := d.Create(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte(content))
+	if err := w.Commit("application/octet-stream"); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func cached(d *diskCache, key string) bool {
+	f, _, ok := d.Open(key)
+	if ok {
+		f.Close()
+	}
+	return ok
+}
+
+func TestDiskCacheEviction(t *testing.T) {
+	root := t.TempDir()
+	d, err := newDiskCache(root, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := addToCache(t, d, strings.Repeat("a", 8))
+	b := addToCache(t, d, strings.Repeat("b", 8))
+	// use a, so that b is the least recently used
+	if !cached(d, a) {
+		t.Fatal("expected a to be cached")
+	}
+	c := addToCache(t, d, strings.Repeat("c", 8))
+	if cached(d, b) {
+		t.Fatal("expected b to be evicted")
+	}
+	if !cached(d, a) || !cached(d, c) {
+		t.Fatal("expected a and c to be cached")
+	}
+
+	// entries should persist across restarts
+	d, err = newDiskCache(root, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cached(d, a) || !cached(d, c) || cached(d, b) {
+		t.Fatal("expected only a and c to be cached after reloading")
+	}
+	// and the limit should still apply
+	d, err = newDiskCache(root, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.size > 10 || d.lru.Len() != 1 {
+		t.Fatalf("expected one entry within the limit, got %d entries and %d bytes", d.lru.Len(), d.size)
+	}
+}
+
+func TestDiskCacheRejects(t *testing.T) {
+	d, err := newDiskCache(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := cacheKey("blobs", sha256Digest([]byte("content")))
+	// content that does not match the digest
+	w, err := d.Create(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("other"))
+	if err := w.Commit("application/octet-stream"); err == nil {
+		t.Fatal("expected mismatched content to be rejected")
+	}
+	// content larger than the cache
+	big := strings.Repeat("a", 11)
+	key, _ = cacheKey("blobs", sha256Digest([]byte(big)))
+	w, err = d.Create(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if n, err := w.Write([]byte(big)); n != len(big) || err != nil {
+			t.Fatalf("expected writes to never fail, got %d, %v", n, err)
+		}
+	}
+	if err := w.Commit("application/octet-stream"); err == nil {
+		t.Fatal("expected content larger than the cache to be rejected")
+	}
+	if d.lru.Len() != 0 {
+		t.Fatalf("expected nothing to be cached, got %d entries", d.lru.Len())
+	}
+	// digests we cannot verify, or that would not be safe paths
+	for _, digest := range []string{"md5:" + strings.Repeat("a", 32), "..:abc", "sha256"} {
+		if _, ok := cacheKey("blobs", digest); ok {
+			t.Fatalf("expected %q not to be cacheable", digest)
+		}
+	}
+}
+
+// failingFS is a fstest.MapFS where reading the directory or entry info
+// at failPath fails
+type failingFS struct {
+	fstest.MapFS
+	failPath string
+}
+
+func (f failingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == f.failPath {
+		return nil, errors.New("injected failure")
+	}
+	entries, err := f.MapFS.ReadDir(name)
+	for i := range entries {
+		if path.Join(name, entries[i].Name()) == f.failPath {
+			entries[i] = failingDirEntry{entries[i]}
+		}
+	}
+	return entries, err
+}
+
+type failingDirEntry struct {
+	fs.DirEntry
+}
+
+func (failingDirEntry) Info() (fs.FileInfo, error) {
+	return nil, fs.ErrNotExist
+}
+
+func TestDiskCacheLoad(t *testing.T) {
+	const encoded = "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73"
+	files := fstest.MapFS{
+		".tmp/entry-1":                                {},
+		"oci-layout":                                  {},
+		"blobs/md5/" + encoded:                        {},
+		"blobs/sha256/" + encoded + ".mediatype":      {},
+		"blobs/sha256/" + encoded:                     {Data: []byte("content")},
+		"manifests/sha256/" + encoded + "/unexpected": {},
+	}
+	d, err := newDiskCache(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.load(files); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(d.entries) != 1 || d.entries["blobs/sha256:"+encoded] == nil || d.size != 7 {
+		t.Fatalf("expected only the valid entry to be loaded, got %d entries and %d bytes", len(d.entries), d.size)
+	}
+	for _, failPath := range []string{"blobs", "blobs/sha256/" + encoded} {
+		if err := d.load(failingFS{MapFS: files, failPath: failPath}); err == nil {
+			t.Errorf("expected error when failing to read %s", failPath)
+		}
+	}
+	// directories that cannot be read fail loading, here one with a path
+	// longer than PATH_MAX, built by nesting directories from the bottom up
+	root := t.TempDir()
+	long := strings.Repeat("a", 255)
+	for i := range 20 {
+		if err := os.Mkdir(filepath.Join(root, "next"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if i != 0 {
+			if err := os.Rename(filepath.Join(root, long), filepath.Join(root, "next", long)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Rename(filepath.Join(root, "next"), filepath.Join(root, long)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := newDiskCache(root, 10); err == nil {
+		t.Fatal("expected error")
+	}
+	// the cache root must be a directory
+	root = filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(root, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newDiskCache(root, 10); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDiskCacheMissingFiles(t *testing.T) {
+	d, err := newDiskCache(t.TempDir(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missingMediaType := addToCache(t, d, "a")
+	if err := os.Remove(d.path(missingMediaType) + ".mediatype"); err != nil {
+		t.Fatal(err)
+	}
+	missingContent := addToCache(t, d, "b")
+	if err := os.Remove(d.path(missingContent)); err != nil {
+		t.Fatal(err)
+	}
+	// the media type cannot be removed, which is only logged
+	unremovable := addToCache(t, d, "c")
+	mediaTypePath := d.path(unremovable) + ".mediatype"
+	if err := os.Remove(mediaTypePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(mediaTypePath, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{missingMediaType, missingContent, unremovable} {
+		if cached(d, key) {
+			t.Errorf("expected %s not to be cached", key)
+		}
+		if _, ok := d.entries[key]; ok {
+			t.Errorf("expected %s to be removed from the cache", key)
+		}
+	}
+	if d.size != 0 {
+		t.Fatalf("expected empty cache, got %d bytes", d.size)
+	}
+}
+
+func TestDiskCacheWriterErrors(t *testing.T) {
+	const content = "content"
+	testCases := []struct {
+		Name string
+		// Prepare breaks the cache or writer for key before committing
+		Prepare func(t *testing.T, d *diskCache, key string, w *diskCacheWriter)
+	}{
+		{
+			Name: "write fails",
+			Prepare: func(t *testing.T, d *diskCache, key string, w *diskCacheWriter) {
+				w.f.Close()
+				_, _ = w.Write([]byte(content))
+			},
+		},
+		{
+			Name: "close fails",
+			Prepare: func(t *testing.T, d *diskCache, key string, w *diskCacheWriter) {
+				_, _ = w.Write([]byte(content))
+				w.f.Close()
+			},
+		},
+		{
+			Name: "creating the directory fails",
+			Prepare: func(t *testing.T, d *diskCache, key string, w *diskCacheWriter) {
+				_, _ = w.Write([]byte(content))
+				if err := os.WriteFile(filepath.Join(d.root, "blobs"), nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			Name: "writing the media type fails",
+			Prepare: func(t *testing.T, d *diskCache, key string, w *diskCacheWriter) {
+				_, _ = w.Write([]byte(content))
+				if err := os.MkdirAll(d.path(key)+".mediatype", 0o755); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			Name: "renaming fails",
+			Prepare: func(t *testing.T, d *diskCache, key string, w *diskCacheWriter) {
+				_, _ = w.Write([]byte(content))
+				if err := os.MkdirAll(filepath.Join(d.path(key), "dir"), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			d, err := newDiskCache(t.TempDir(), 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, _ := cacheKey("blobs", sha256Digest([]byte(content)))
+			w, err := d.Create(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.Prepare(t, d, key, w)
+			if err := w.Commit("application/octet-stream"); err == nil {
+				t.Fatal("expected error")
+			}
+			if d.lru.Len() != 0 {
+				t.Fatalf("expected nothing to be cached, got %d entries", d.lru.Len())
+			}
+		})
+	}
+}
+
+func TestDiskCacheCreate(t *testing.T) {
+	d, err := newDiskCache(t.TempDir(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Create("blobs/md5:" + strings.Repeat("a", 32)); err == nil {
+		t.Fatal("expected error for unsupported digest algorithm")
+	}
+	// the same content may be cached concurrently
+	key, _ := cacheKey("blobs", sha256Digest([]byte("content")))
+	writers := []*diskCacheWriter{}
+	for range 2 {
+		w, err := d.Create(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte("content"))
+		writers = append(writers, w)
+	}
+	for _, w := range writers {
+		if err := w.Commit("application/octet-stream"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if d.lru.Len() != 1 || d.size != 7 {
+		t.Fatalf("expected one entry, got %d entries and %d bytes", d.lru.Len(), d.size)
+	}
+	if err := os.RemoveAll(d.tmpDir()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Create(key); err == nil {
+		t.Fatal("expected error without a temporary directory")
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// errorCode is a registry API error code
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#error-codes
+type errorCode string
+
+const (
+	// errorCodeUnsupported is for operations we do not support,
+	// such as pushing, or the non-standard _catalog API
+	errorCodeUnsupported errorCode = "UNSUPPORTED"
+	// errorCodeNameInvalid is for invalid repository names
+	errorCodeNameInvalid errorCode = "NAME_INVALID"
+	// errorCodeUnauthorized is for requests without a valid token
+	errorCodeUnauthorized errorCode = "UNAUTHORIZED"
+	// errorCodeDenied is for content blocked by policy
+	errorCodeDenied errorCode = "DENIED"
+	// errorCodeUnknown is for errors without a more specific code, it is not
+	// in the OCI spec but matches the docker distribution registry
+	errorCodeUnknown errorCode = "UNKNOWN"
+)
+
+// registryError is a single error in a registryErrors response
+type registryError struct {
+	Code    errorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// registryErrors is the OCI distribution spec error response body
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#error-codes
+type registryErrors struct {
+	Errors []registryError `json:"errors"`
+}
+
+// writeError writes an OCI distribution spec error response,
+// it is a drop in replacement for http.Error for registry clients
+func writeError(w http.ResponseWriter, status int, code errorCode, message string) {
+	// marshalling only fails for unsupported types, which these are not
+	body, _ := json.Marshal(registryErrors{
+		Errors: []registryError{{Code: code, Message: message}},
+	})
+	// like http.Error, the error may replace a response that set these
+	w.Header().Del("Content-Length")
+	w.Header().Del("Docker-Content-Digest")
+	w.Header().Del("Etag")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
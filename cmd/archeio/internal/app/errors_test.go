@@ -0,0 +1,135 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"k8s.io/registry.k8s.io/pkg/net/cloudcidrs"
+)
+
+// reErrorCode matches valid error codes per the OCI distribution spec
+var reErrorCode = regexp.MustCompile("^[A-Z_]+$")
+
+// checkErrorResponse validates response is an OCI distribution spec error
+func checkErrorResponse(t *testing.T, recorder *httptest.ResponseRecorder, expectedStatus int, expectedCode errorCode) {
+	t.Helper()
+	response := recorder.Result()
+	if response.StatusCode != expectedStatus {
+		t.Fatalf("expected status: %v, but got status: %v", expectedStatus, response.StatusCode)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "application/json" {
+		t.Fatalf("expected Content-Type: application/json, but got: %q", contentType)
+	}
+	decoder := json.NewDecoder(strings.NewReader(recorder.Body.String()))
+	decoder.DisallowUnknownFields()
+	body := registryErrors{}
+	if err := decoder.Decode(&body); err != nil {
+		t.Fatalf("failed to decode error body %q: %v", recorder.Body.String(), err)
+	}
+	if len(body.Errors) == 0 {
+		t.Fatalf("expected at least one error, but got: %q", recorder.Body.String())
+	}
+	for _, e := range body.Errors {
+		if !reErrorCode.MatchString(string(e.Code)) {
+			t.Fatalf("invalid error code: %q", e.Code)
+		}
+		if e.Message == "" {
+			t.Fatalf("expected error message for code: %q", e.Code)
+		}
+	}
+	if body.Errors[0].Code != expectedCode {
+		t.Fatalf("expected code: %q, but got: %q", expectedCode, body.Errors[0].Code)
+	}
+}
+
+func TestMakeHandlerErrors(t *testing.T) {
+	handler := MakeHandler(t.Context(), RegistryConfig{
+		UpstreamRegistryEndpoint: "https://us-central1-docker.pkg.dev",
+		UpstreamRegistryPath:     "k8s-artifacts-prod/images",
+	})
+	testCases := []struct {
+		Name           string
+		Request        *http.Request
+		ExpectedStatus int
+		ExpectedCode   errorCode
+	}{
+		{
+			Name:           "PUT manifest",
+			Request:        httptest.NewRequest("PUT", "http://localhost:8080/v2/pause/manifests/3.9", nil),
+			ExpectedStatus: http.StatusMethodNotAllowed,
+			ExpectedCode:   errorCodeUnsupported,
+		},
+		{
+			Name:           "POST blob upload",
+			Request:        httptest.NewRequest("POST", "http://localhost:8080/v2/pause/blobs/uploads/", nil),
+			ExpectedStatus: http.StatusMethodNotAllowed,
+			ExpectedCode:   errorCodeUnsupported,
+		},
+		{
+			Name:           "unknown path",
+			Request:        httptest.NewRequest("GET", "http://localhost:8080/v1/_ping", nil),
+			ExpectedStatus: http.StatusNotFound,
+			ExpectedCode:   errorCodeUnsupported,
+		},
+		{
+			Name:           "_catalog",
+			Request:        httptest.NewRequest("GET", "http://localhost:8080/v2/_catalog", nil),
+			ExpectedStatus: http.StatusNotFound,
+			ExpectedCode:   errorCodeUnsupported,
+		},
+		{
+			Name: "bogus remote addr",
+			Request: func() *http.Request {
+				r := httptest.NewRequest("GET", "http://localhost:8080/v2/pause/blobs/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e", nil)
+				r.RemoteAddr = "35.180.1.1asdfasdfsd:888"
+				return r
+			}(),
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedCode:   errorCodeUnknown,
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, tc.Request)
+			checkErrorResponse(t, recorder, tc.ExpectedStatus, tc.ExpectedCode)
+			if tc.ExpectedStatus == http.StatusMethodNotAllowed && recorder.Header().Get("Allow") != "GET, HEAD" {
+				t.Fatalf("expected Allow: GET, HEAD, but got: %q", recorder.Header().Get("Allow"))
+			}
+		})
+	}
+}
+
+func TestCachingProxyErrors(t *testing.T) {
+	// an upstream that is not listening
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstream.Close()
+	handler := makeV2Handler(RegistryConfig{
+		UpstreamRegistryEndpoint: upstream.URL,
+	}, &fakeBlobsChecker{}, &fakeManifestFetcher{}, nil, newCachingProxy(nil), nil, nil, nil, cloudcidrs.NewIPMapper(), nil)
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/3.9", nil))
+	checkErrorResponse(t, recorder, http.StatusBadGateway, errorCodeUnknown)
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+
+	"k8s.io/registry.k8s.io/pkg/net/geoip"
+)
+
+// countryToAWSRegion maps ISO 3166-1 country codes to the AWS region for the
+// bucket in (or for small neighbours, nearest to) that country,
+// countries not listed here use continentToAWSRegion
+var countryToAWSRegion = map[string]string{
+	// Africa
+	"ZA": "af-south-1",
+	// Asia
+	"HK": "ap-east-1",
+	"MO": "ap-east-1",
+	"CN": "ap-east-1",
+	"TW": "ap-east-2",
+	"JP": "ap-northeast-1",
+	"KR": "ap-northeast-2",
+	"SG": "ap-southeast-1",
+	"ID": "ap-southeast-3",
+	"MY": "ap-southeast-5",
+	"TH": "ap-southeast-7",
+	"IN": "ap-south-1",
+	"IL": "il-central-1",
+	"AE": "me-central-1",
+	"BH": "me-south-1",
+	// Europe
+	"DE": "eu-central-1",
+	"CH": "eu-central-2",
+	"SE": "eu-north-1",
+	"NO": "eu-north-1",
+	"FI": "eu-north-1",
+	"DK": "eu-north-1",
+	"IT": "eu-south-1",
+	"ES": "eu-south-2",
+	"PT": "eu-south-2",
+	"IE": "eu-west-1",
+	"GB": "eu-west-2",
+	"FR": "eu-west-3",
+	// North America
+	"CA": "ca-central-1",
+	"MX": "mx-central-1",
+	"US": "us-east-2",
+	// Oceania
+	"AU": "ap-southeast-2",
+	"NZ": "ap-southeast-6",
+	// South America
+	"BR": "sa-east-1",
+}
+
+// continentToAWSRegion maps continent codes to the AWS region for a
+// central bucket on that continent, for countries not in countryToAWSRegion
+var continentToAWSRegion = map[string]string{
+	"AF": "af-south-1",
+	"AS": "ap-southeast-1",
+	"EU": "eu-central-1",
+	"NA": "us-east-2",
+	"OC": "ap-southeast-2",
+	"SA": "sa-east-1",
+}
+
+// awsRegionForLocation returns the AWS region nearest to loc, or "" if unknown
+func awsRegionForLocation(loc geoip.Location) string {
+	if region, ok := countryToAWSRegion[loc.Country]; ok {
+		return region
+	}
+	return continentToAWSRegion[loc.Continent]
+}
+
+// awsRegionForISORegion returns the AWS region nearest to an ISO 3166-2
+// region, e.g. "US-NY", or ISO 3166-1 country, e.g. "SG", as geofeed clouds
+// without a region mapping use, ok is false for other region names
+func awsRegionForISORegion(region string) (awsRegion string, ok bool) {
+	country, _, _ := strings.Cut(region, "-")
+	if len(country) != 2 || strings.ToUpper(country) != country {
+		return "", false
+	}
+	return awsRegionForLocation(geoip.Location{Country: country}), true
+}
+
+// fileGeoIP looks up client locations in a MaxMind DB watchedFile,
+// until the first valid database is loaded nothing matches
+type fileGeoIP struct {
+	file *watchedFile[geoip.Reader]
+}
+
+func newFileGeoIP(ctx context.Context, path string) *fileGeoIP {
+	return &fileGeoIP{
+		file: newWatchedFile(ctx, path, geoip.NewReader, watchedFileReloadInterval),
+	}
+}
+
+func (g *fileGeoIP) GetIP(ip netip.Addr) (geoip.Location, bool) {
+	reader := g.file.Load()
+	if reader == nil {
+		return geoip.Location{}, false
+	}
+	return reader.GetIP(ip)
+}
@@ -0,0 +1,291 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/registry.k8s.io/pkg/net/cloudcidrs"
+	"k8s.io/registry.k8s.io/pkg/net/geoip"
+)
+
+type fakeGeoIP struct {
+	locations map[netip.Addr]geoip.Location
+}
+
+func (f *fakeGeoIP) GetIP(ip netip.Addr) (geoip.Location, bool) {
+	loc, ok := f.locations[ip]
+	return loc, ok
+}
+
+func TestAWSRegionForLocation(t *testing.T) {
+	testCases := []struct {
+		Location geoip.Location
+		Expected string
+	}{
+		{Location: geoip.Location{Country: "FR", Continent: "EU"}, Expected: "eu-west-3"},
+		{Location: geoip.Location{Country: "PL", Continent: "EU"}, Expected: "eu-central-1"},
+		{Location: geoip.Location{Country: "JP", Continent: "AS"}, Expected: "ap-northeast-1"},
+		{Location: geoip.Location{Continent: "SA"}, Expected: "sa-east-1"},
+		{Location: geoip.Location{Country: "AQ", Continent: "AN"}, Expected: ""},
+		{Location: geoip.Location{}, Expected: ""},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Location.Country+"/"+tc.Location.Continent, func(t *testing.T) {
+			t.Parallel()
+			if region := awsRegionForLocation(tc.Location); region != tc.Expected {
+				t.Fatalf("expected region: %q, but got: %q", tc.Expected, region)
+			}
+		})
+	}
+}
+
+func TestAWSRegionForISORegion(t *testing.T) {
+	testCases := []struct {
+		Region     string
+		Expected   string
+		ExpectedOK bool
+	}{
+		{Region: "US-NY", Expected: "us-east-2", ExpectedOK: true},
+		{Region: "JP-13", Expected: "ap-northeast-1", ExpectedOK: true},
+		{Region: "SG", Expected: "ap-southeast-1", ExpectedOK: true},
+		// known ISO 3166 codes, but no nearby bucket
+		{Region: "AQ", Expected: "", ExpectedOK: true},
+		// cloud region names are not ISO 3166 codes
+		{Region: "us-east-1", Expected: "", ExpectedOK: false},
+		{Region: "eastus", Expected: "", ExpectedOK: false},
+		{Region: "GLOBAL", Expected: "", ExpectedOK: false},
+		{Region: "", Expected: "", ExpectedOK: false},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Region, func(t *testing.T) {
+			t.Parallel()
+			region, ok := awsRegionForISORegion(tc.Region)
+			if region != tc.Expected || ok != tc.ExpectedOK {
+				t.Fatalf("expected region: %q, %v, but got: %q, %v", tc.Expected, tc.ExpectedOK, region, ok)
+			}
+		})
+	}
+}
+
+// fakeCloudIPs maps IPs to clouds, for clouds not in the generated data
+type fakeCloudIPs map[netip.Addr]cloudcidrs.IPInfo
+
+func (f fakeCloudIPs) GetIP(ip netip.Addr) (cloudcidrs.IPInfo, bool) {
+	info, ok := f[ip]
+	return info, ok
+}
+
+func TestMakeV2HandlerGeofeedRegions(t *testing.T) {
+	const digest = "sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e"
+	registryConfig := RegistryConfig{
+		UpstreamRegistryEndpoint: "https://us-central1-docker.pkg.dev",
+		UpstreamRegistryPath:     "k8s-artifacts-prod/images",
+		DefaultAWSBaseURL:        "https://default.example",
+	}
+	blobs := fakeBlobsChecker{
+		knownURLs: map[string]bool{
+			"https://prod-registry-k8s-io-us-east-2.s3.dualstack.us-east-2.amazonaws.com/containers/images/" + digest:           true,
+			"https://prod-registry-k8s-io-ap-southeast-1.s3.dualstack.ap-southeast-1.amazonaws.com/containers/images/" + digest: true,
+			"https://default.example/containers/images/" + digest:                                                               true,
+		},
+	}
+	// geofeed clouds without a region mapping use ISO 3166 codes as regions
+	clouds := fakeCloudIPs{
+		netip.MustParseAddr("192.0.2.1"):    {Cloud: "CorpEgress", Region: "US-NY"},
+		netip.MustParseAddr("192.0.2.2"):    {Cloud: "CorpEgress", Region: "SG"},
+		netip.MustParseAddr("192.0.2.3"):    {Cloud: "CorpEgress", Region: "AQ"},
+		netip.MustParseAddr("198.51.100.1"): {Cloud: "Azure", Region: "eastus"},
+	}
+	handler := makeV2Handler(registryConfig, &blobs, &fakeManifestFetcher{}, nil, nil, nil, nil, nil, clouds, nil)
+	testCases := []struct {
+		Name        string
+		RemoteAddr  string
+		ExpectedURL string
+	}{
+		{
+			Name:        "ISO 3166-2 region",
+			RemoteAddr:  "192.0.2.1:888",
+			ExpectedURL: "https://prod-registry-k8s-io-us-east-2.s3.dualstack.us-east-2.amazonaws.com/containers/images/" + digest,
+		},
+		{
+			Name:        "ISO 3166-1 country",
+			RemoteAddr:  "192.0.2.2:888",
+			ExpectedURL: "https://prod-registry-k8s-io-ap-southeast-1.s3.dualstack.ap-southeast-1.amazonaws.com/containers/images/" + digest,
+		},
+		{
+			Name:        "country without a nearby bucket",
+			RemoteAddr:  "192.0.2.3:888",
+			ExpectedURL: "https://default.example/containers/images/" + digest,
+		},
+		{
+			Name:        "other cloud region",
+			RemoteAddr:  "198.51.100.1:888",
+			ExpectedURL: "https://default.example/containers/images/" + digest,
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest("GET", "http://localhost:8080/v2/pause/blobs/"+digest, nil)
+			r.RemoteAddr = tc.RemoteAddr
+			recorder := httptest.NewRecorder()
+			handler(recorder, r)
+			response := recorder.Result()
+			if response.StatusCode != http.StatusTemporaryRedirect {
+				t.Fatalf("expected status: %v, but got status: %v", http.StatusTemporaryRedirect, response.StatusCode)
+			}
+			if location := response.Header.Get("Location"); location != tc.ExpectedURL {
+				t.Fatalf("expected url: %q, but got: %q", tc.ExpectedURL, location)
+			}
+		})
+	}
+}
+
+func TestMakeV2HandlerGeoIP(t *testing.T) {
+	registryConfig := RegistryConfig{
+		UpstreamRegistryEndpoint: "https://us-central1-docker.pkg.dev",
+		UpstreamRegistryPath:     "k8s-artifacts-prod/images",
+		DefaultAWSBaseURL:        "https://default.example",
+	}
+	blobs := fakeBlobsChecker{
+		knownURLs: map[string]bool{
+			"https://prod-registry-k8s-io-eu-west-3.s3.dualstack.eu-west-3.amazonaws.com/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e":           true,
+			"https://prod-registry-k8s-io-ap-northeast-1.s3.dualstack.ap-northeast-1.amazonaws.com/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e": true,
+			"https://default.example/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e":                                                               true,
+		},
+	}
+	geo := &fakeGeoIP{
+		locations: map[netip.Addr]geoip.Location{
+			netip.MustParseAddr("192.168.0.1"): {Country: "FR", Continent: "EU"},
+			netip.MustParseAddr("2001:db8::1"): {Country: "JP", Continent: "AS"},
+			// cloud clients are located by cloud region, not GeoIP
+			netip.MustParseAddr("35.180.1.1"): {Country: "JP", Continent: "AS"},
+			// except for global / edge network ranges, e.g. CloudFront
+			netip.MustParseAddr("108.138.0.1"): {Country: "FR", Continent: "EU"},
+		},
+	}
+	handler := makeV2Handler(registryConfig, &blobs, &fakeManifestFetcher{}, nil, nil, nil, nil, nil, cloudcidrs.NewIPMapper(), geo)
+	testCases := []struct {
+		Name        string
+		RemoteAddr  string
+		ExpectedURL string
+	}{
+		{
+			Name:        "located IPv4 client",
+			RemoteAddr:  "192.168.0.1:888",
+			ExpectedURL: "https://prod-registry-k8s-io-eu-west-3.s3.dualstack.eu-west-3.amazonaws.com/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+		},
+		{
+			Name:        "located IPv6 client",
+			RemoteAddr:  "[2001:db8::1]:888",
+			ExpectedURL: "https://prod-registry-k8s-io-ap-northeast-1.s3.dualstack.ap-northeast-1.amazonaws.com/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+		},
+		{
+			Name:        "unlocated client",
+			RemoteAddr:  "192.168.0.2:888",
+			ExpectedURL: "https://default.example/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+		},
+		{
+			Name:        "located AWS GLOBAL client",
+			RemoteAddr:  "108.138.0.1:888",
+			ExpectedURL: "https://prod-registry-k8s-io-eu-west-3.s3.dualstack.eu-west-3.amazonaws.com/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+		},
+		{
+			Name:        "unlocated AWS GLOBAL client",
+			RemoteAddr:  "108.138.0.2:888",
+			ExpectedURL: "https://default.example/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+		},
+		{
+			Name:        "AWS client",
+			RemoteAddr:  "35.180.1.1:888",
+			ExpectedURL: "https://prod-registry-k8s-io-eu-west-3.s3.dualstack.eu-west-3.amazonaws.com/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest("GET", "http://localhost:8080/v2/pause/blobs/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e", nil)
+			r.RemoteAddr = tc.RemoteAddr
+			recorder := httptest.NewRecorder()
+			handler(recorder, r)
+			response := recorder.Result()
+			if response.StatusCode != http.StatusTemporaryRedirect {
+				t.Fatalf("expected status: %v, but got status: %v", http.StatusTemporaryRedirect, response.StatusCode)
+			}
+			if location := response.Header.Get("Location"); location != tc.ExpectedURL {
+				t.Fatalf("expected url: %q, but got: %q", tc.ExpectedURL, location)
+			}
+		})
+	}
+}
+
+func TestFileGeoIPMissingFile(t *testing.T) {
+	g := newFileGeoIP(t.Context(), t.TempDir()+"/missing.mmdb")
+	if _, ok := g.GetIP(netip.MustParseAddr("192.168.0.1")); ok {
+		t.Fatalf("expected no location before a database is loaded")
+	}
+}
+
+// writeTestGeoIPDatabase writes a MaxMind DB locating 128.0.0.0/1 in France
+func writeTestGeoIPDatabase(t *testing.T) string {
+	t.Helper()
+	var db []byte
+	// a single node IPv4 search tree with 32 bit records, the left record
+	// has no data and the right points to the first data section value
+	db = append(db, 0, 0, 0, 1, 0, 0, 0, 17)
+	db = append(db, make([]byte, 16)...)
+	// {"country": {"iso_code": "FR"}}
+	db = append(db, 0xe1, 0x47)
+	db = append(db, "country"...)
+	db = append(db, 0xe1, 0x48)
+	db = append(db, "iso_code"...)
+	db = append(db, 0x42, 'F', 'R')
+	// {"node_count": 1, "record_size": 32, "ip_version": 4}
+	db = append(db, "\xAB\xCD\xEFMaxMind.com"...)
+	db = append(db, 0xe3, 0x4a)
+	db = append(db, "node_count"...)
+	db = append(db, 0xc1, 1, 0x4b)
+	db = append(db, "record_size"...)
+	db = append(db, 0xa1, 32, 0x4a)
+	db = append(db, "ip_version"...)
+	db = append(db, 0xa1, 4)
+	path := filepath.Join(t.TempDir(), "GeoLite2-Country.mmdb")
+	if err := os.WriteFile(path, db, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileGeoIP(t *testing.T) {
+	g := newFileGeoIP(t.Context(), writeTestGeoIPDatabase(t))
+	if loc, ok := g.GetIP(netip.MustParseAddr("192.168.0.1")); !ok || loc.Country != "FR" {
+		t.Fatalf("expected 192.168.0.1 to be located in FR, got %+v, %v", loc, ok)
+	}
+	if loc, ok := g.GetIP(netip.MustParseAddr("10.0.0.1")); ok {
+		t.Fatalf("expected no location for 10.0.0.1, got %+v", loc)
+	}
+}
@@ -17,24 +17,83 @@ limitations under the License.
 package app
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"net/netip"
 	"path"
 	"regexp"
 	"strings"
+	"time"
 
 	"k8s.io/klog/v2"
 
+	"k8s.io/registry.k8s.io/pkg/net/cidrs"
 	"k8s.io/registry.k8s.io/pkg/net/clientip"
 	"k8s.io/registry.k8s.io/pkg/net/cloudcidrs"
+	"k8s.io/registry.k8s.io/pkg/net/geoip"
 )
 
 type RegistryConfig struct {
-	UpstreamRegistryEndpoint  string
-	UpstreamRegistryPath      string
+	UpstreamRegistryEndpoint string
+	UpstreamRegistryPath     string
+	// UpstreamRoutes route some repositories to other upstream registries,
+	// instead of UpstreamRegistryEndpoint and UpstreamRegistryPath, for
+	// both IPv4 and IPv6 clients
+	UpstreamRoutes []UpstreamRoute
+	// UpstreamRegistryLocations maps GCP regions to Artifact Registry
+	// locations, GCP clients in a mapped region are redirected to that
+	// location instead of UpstreamRegistryEndpoint
+	UpstreamRegistryLocations map[string]string
 	SignatureUpstreamEndpoint string
-	InfoURL                   string
-	PrivacyURL                string
-	DefaultAWSBaseURL         string
+	// UpstreamRegistryEndpointIPv6, SignatureUpstreamEndpointIPv6 and
+	// DefaultAWSBaseURLIPv6, if set, are used instead for IPv6 clients,
+	// for when the other endpoints are not reachable over IPv6
+	UpstreamRegistryEndpointIPv6  string
+	SignatureUpstreamEndpointIPv6 string
+	DefaultAWSBaseURLIPv6         string
+	InfoURL                       string
+	PrivacyURL                    string
+	DefaultAWSBaseURL             string
+	// ServeManifestsFromBuckets enables serving manifest requests by digest
+	// from the same bucket we would redirect blob requests to, when geranos
+	// has mirrored them there
+	ServeManifestsFromBuckets bool
+	// LocalBlobDirectory, if set, is a directory to serve blobs from directly
+	// instead of redirecting, see localBlobServer
+	LocalBlobDirectory string
+	// ProxyUpstream enables fetching content from upstream and serving it
+	// to clients directly, instead of redirecting clients, see cachingProxy
+	ProxyUpstream bool
+	// ProxyCacheDirectory, if set with ProxyUpstream, is a directory to cache
+	// content addressed responses in, up to ProxyCacheMaxBytes
+	ProxyCacheDirectory string
+	ProxyCacheMaxBytes  int64
+	// PolicyFile, if set, is a JSON file of repositories and digests to
+	// allow or deny, which is reloaded when it changes, manifests requested
+	// by tag are checked by the digest the tag currently resolves to upstream
+	PolicyFile string
+	// PinnedTagsFile, if set, is a JSON file of tags to always serve as a
+	// fixed digest, which is reloaded when it changes
+	PinnedTagsFile string
+	// GeoIPDatabaseFile, if set, is a MaxMind DB file used to route clients
+	// outside any known cloud to their nearest bucket, reloaded when it changes
+	GeoIPDatabaseFile string
+	// SignBucketURLs enables signing bucket URLs for private buckets,
+	// with credentials from the standard AWS and Google SDK chains
+	SignBucketURLs bool
+	// SignedURLExpiry is how long signed bucket URLs are valid for
+	SignedURLExpiry time.Duration
+	// TokenAuthRealm, if set, requires clients to authenticate with bearer
+	// tokens from the token service at this URL, for private upstreams
+	TokenAuthRealm string
+	// TokenAuthService is the service name tokens must be issued for
+	TokenAuthService string
+	// TokenAuthIssuer, if set, is the issuer tokens must be issued by
+	TokenAuthIssuer string
+	// TokenAuthJWKSFile is a JSON Web Key Set file of keys tokens may be
+	// signed with, which is reloaded when it changes
+	TokenAuthJWKSFile string
 }
 
 // MakeHandler returns the root archeio HTTP handler
@@ -43,15 +102,59 @@ type RegistryConfig struct {
 // archeio is fronting.
 //
 // Exact behavior should be documented in docs/request-handling.md
-func MakeHandler(rc RegistryConfig) http.Handler {
-	blobs := newCachedBlobChecker()
-	doV2 := makeV2Handler(rc, blobs)
+func MakeHandler(ctx context.Context, rc RegistryConfig) http.Handler {
+	var signer urlSigner
+	if rc.SignBucketURLs {
+		signer = newBucketURLSigner(ctx, rc.SignedURLExpiry)
+	}
+	blobs := newCachedBlobChecker(signer)
+	manifests := newCachedManifestFetcher(signer)
+	var local blobServer
+	if rc.LocalBlobDirectory != "" {
+		local = newLocalBlobServer(rc.LocalBlobDirectory)
+	}
+	var proxy upstreamProxy
+	if rc.ProxyUpstream {
+		var cache *diskCache
+		if rc.ProxyCacheDirectory != "" {
+			var err error
+			cache, err = newDiskCache(rc.ProxyCacheDirectory, rc.ProxyCacheMaxBytes)
+			if err != nil {
+				// we can still serve requests, just slower
+				klog.ErrorS(err, "failed to initialize cache, proxying without caching")
+				cache = nil
+			}
+		}
+		p := newCachingProxy(cache)
+		// the upstream may accept the same tokens
+		p.forwardAuthorization = rc.TokenAuthRealm != ""
+		proxy = p
+	}
+	var policy accessPolicy
+	if rc.PolicyFile != "" {
+		policy = newFilePolicy(ctx, rc.PolicyFile)
+	}
+	var pins tagPinner
+	if rc.PinnedTagsFile != "" {
+		pins = newFilePinnedTags(ctx, rc.PinnedTagsFile)
+	}
+	var geo cidrs.IPMapper[geoip.Location]
+	if rc.GeoIPDatabaseFile != "" {
+		geo = newFileGeoIP(ctx, rc.GeoIPDatabaseFile)
+	}
+	// initialize map of clientIP to cloud and region
+	clouds := cloudcidrs.NewIPMapper()
+	doV2 := makeV2Handler(rc, blobs, manifests, local, proxy, policy, pins, signer, clouds, geo)
+	if rc.TokenAuthRealm != "" {
+		doV2 = withTokenAuth(rc, newTokenVerifier(ctx, rc.TokenAuthService, rc.TokenAuthIssuer, rc.TokenAuthJWKSFile), doV2)
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// only allow GET, HEAD
 		// this is all a client needs to pull images
 		// we do *not* support mutation
 		if r.Method != http.MethodGet && r.Method != http.MethodHead {
-			http.Error(w, "Only GET and HEAD are allowed.", http.StatusMethodNotAllowed)
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, http.StatusMethodNotAllowed, errorCodeUnsupported, "Only GET and HEAD are allowed.")
 			return
 		}
 		// all valid registry requests should be at /v2/
@@ -66,12 +169,12 @@ func MakeHandler(rc RegistryConfig) http.Handler {
 			http.Redirect(w, r, rc.PrivacyURL, http.StatusTemporaryRedirect)
 		default:
 			klog.V(2).InfoS("unknown request", "path", path)
-			http.NotFound(w, r)
+			writeError(w, http.StatusNotFound, errorCodeUnsupported, "unknown path: only the registry API at /v2/ is supported")
 		}
 	})
 }
 
-func makeV2Handler(rc RegistryConfig, blobs blobChecker) func(w http.ResponseWriter, r *http.Request) {
+func makeV2Handler(rc RegistryConfig, blobs blobChecker, manifests manifestFetcher, local blobServer, proxy upstreamProxy, policy accessPolicy, pins tagPinner, signer urlSigner, clouds cidrs.IPMapper[cloudcidrs.IPInfo], geo cidrs.IPMapper[geoip.Location]) func(w http.ResponseWriter, r *http.Request) {
 	// matches blob requests, captures the requested blob hash
 	// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pull
 	// Blobs are at `/v2/<name>/blobs/<digest>`
@@ -81,8 +184,94 @@ func makeV2Handler(rc RegistryConfig, blobs blobChecker) func(w http.ResponseWri
 	reBlob := regexp.MustCompile("^/v2/.*/blobs/([^/]+:[a-zA-Z0-9=_-]+)$")
 	// matches cosign signature and attestation tag requests
 	reCosignTag := regexp.MustCompile(`^/v2/.*/manifests/sha256-[a-f0-9]{64}\.(sig|att)$`)
-	// initialize map of clientIP to AWS region
-	regionMapper := cloudcidrs.NewIPMapper()
+	// matches manifest requests by digest, captures the requested digest
+	// the same as reBlob, tags cannot contain ':' so these are distinct
+	reManifestDigest := regexp.MustCompile("^/v2/.*/manifests/([^/]+:[a-zA-Z0-9=_-]+)$")
+	// used to resolve tags upstream to check them against policy
+	// NOTE: this client will still share http.DefaultTransport
+	tagClient := &http.Client{Timeout: 30 * time.Second}
+	// lookupClient determines the client IP of r and its cloud, once per
+	// request, for configForClient and bucketForClient
+	lookupClient := func(r *http.Request) clientInfo {
+		clientIP, err := clientip.Get(r)
+		if err != nil {
+			return clientInfo{err: err}
+		}
+		klog.V(3).InfoS("client IP", "ip", clientIP, "family", ipFamily(clientIP))
+		ipInfo, ipIsKnown := clouds.GetIP(clientIP)
+		return clientInfo{ip: clientIP, cloud: ipInfo, inCloud: ipIsKnown}
+	}
+	// configForClient returns rc adjusted for the client:
+	// IPv6 clients use the IPv6 endpoints where configured, so that IPv6-only
+	// clients are never redirected to IPv4-only hosts, otherwise GCP clients
+	// use the nearest configured Artifact Registry location
+	//
+	// rc is returned as-is if the client IP cannot be determined
+	configForClient := func(client clientInfo) RegistryConfig {
+		if client.err != nil {
+			return rc
+		}
+		clientConfig := rc
+		if ipFamily(client.ip) == "IPv6" {
+			if rc.UpstreamRegistryEndpointIPv6 != "" {
+				// we don't know if Artifact Registry locations are reachable
+				// over IPv6, so the IPv6 endpoint takes precedence
+				clientConfig.UpstreamRegistryEndpoint = rc.UpstreamRegistryEndpointIPv6
+				clientConfig.UpstreamRegistryLocations = nil
+			}
+			if rc.SignatureUpstreamEndpointIPv6 != "" {
+				clientConfig.SignatureUpstreamEndpoint = rc.SignatureUpstreamEndpointIPv6
+			}
+			if rc.DefaultAWSBaseURLIPv6 != "" {
+				clientConfig.DefaultAWSBaseURL = rc.DefaultAWSBaseURLIPv6
+			}
+		}
+		if len(clientConfig.UpstreamRegistryLocations) == 0 {
+			return clientConfig
+		}
+		if !client.inCloud || client.cloud.Cloud != cloudcidrs.GCP {
+			return clientConfig
+		}
+		if location, ok := arLocationForRegion(clientConfig.UpstreamRegistryLocations, client.cloud.Region); ok {
+			clientConfig.UpstreamRegistryEndpoint = arLocationEndpoint(location)
+		}
+		return clientConfig
+	}
+	// bucketForClient checks the client IP and determines the best bucket,
+	// returning "" if the client should stay on the upstream registry
+	//
+	// if ok is false an error response has already been written
+	bucketForClient := func(w http.ResponseWriter, client clientInfo) (bucketURL string, ok bool) {
+		if client.err != nil {
+			// this should not happen
+			klog.ErrorS(client.err, "failed to get client IP")
+			writeError(w, http.StatusBadRequest, errorCodeUnknown, client.err.Error())
+			return "", false
+		}
+
+		// if client is coming from GCP, stay in GCP
+		if client.inCloud && client.cloud.Cloud == cloudcidrs.GCP {
+			return "", true
+		}
+
+		// otherwise use our AWS storage for the region
+		region := ""
+		if client.inCloud {
+			region = client.cloud.Region
+			if awsRegion, ok := awsRegionForISORegion(region); ok {
+				region = awsRegion
+			}
+		}
+		// if not in any known cloud, or in a global / edge network range that
+		// may be anywhere, fall back to the client location
+		if (!client.inCloud || client.cloud.IsGlobal()) && geo != nil {
+			if loc, ok := geo.GetIP(client.ip); ok {
+				region = awsRegionForLocation(loc)
+				klog.V(3).InfoS("located client", "ip", client.ip, "country", loc.Country, "continent", loc.Continent, "region", region)
+			}
+		}
+		return awsRegionToHostURL(region, configForClient(client).DefaultAWSBaseURL), true
+	}
 	// capture these in a http handler lambda
 	return func(w http.ResponseWriter, r *http.Request) {
 		rPath := r.URL.Path
@@ -110,22 +299,119 @@ func makeV2Handler(rc RegistryConfig, blobs blobChecker) func(w http.ResponseWri
 		// we don't support the non-standard _catalog API
 		// https://github.com/kubernetes/registry.k8s.io/issues/162
 		if rPath == "/v2/_catalog" {
-			http.Error(w, "_catalog is not supported", http.StatusNotFound)
+			writeError(w, http.StatusNotFound, errorCodeUnsupported, "_catalog is not supported")
+			return
+		}
+
+		// validate the repository name and check if the content is blocked
+		if matches := reRepository.FindStringSubmatch(rPath); len(matches) == 2 {
+			repository := matches[1]
+			if !reName.MatchString(repository) {
+				writeError(w, http.StatusBadRequest, errorCodeNameInvalid, "invalid repository name")
+				return
+			}
+			if policy != nil {
+				digest := ""
+				if matches := reBlob.FindStringSubmatch(rPath); len(matches) == 2 {
+					digest = matches[1]
+				} else if matches := reManifestDigest.FindStringSubmatch(rPath); len(matches) == 2 {
+					digest = matches[1]
+				}
+				if !policy.Allowed(repository, digest) {
+					klog.V(2).InfoS("denying request blocked by policy", "path", rPath)
+					writeError(w, http.StatusForbidden, errorCodeDenied, "this content is blocked by registry policy")
+					return
+				}
+			}
+			// serve pinned tags as their digest, regardless of upstream
+			if pins != nil {
+				if tag, ok := strings.CutPrefix(rPath, "/v2/"+repository+"/manifests/"); ok && reTag.MatchString(tag) {
+					if digest, ok := pins.PinnedDigest(repository, tag, upstreamRedirectURL(rc, rPath)); ok {
+						redirectURL := path.Join("/v2/", repository, "manifests", digest)
+						klog.V(2).InfoS("redirecting pinned tag request to digest", "path", rPath, "redirect", redirectURL)
+						http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
+						return
+					}
+				}
+			}
+			// check other tags by the digest they currently resolve to, so
+			// that denied digests cannot be pulled by tag
+			if policy != nil && policy.DeniesDigests() {
+				if tag, ok := strings.CutPrefix(rPath, "/v2/"+repository+"/manifests/"); ok && reTag.MatchString(tag) {
+					upstreamURL := upstreamRedirectURL(rc, rPath)
+					if useSignatureUpstream(rc, reCosignTag, rPath) {
+						upstreamURL = signatureRedirectURL(rc, rPath)
+					}
+					// tags missing upstream are passed through to fail there
+					digest, err := resolveManifestDigest(tagClient, upstreamURL)
+					if err != nil && !errors.Is(err, errManifestNotFound) {
+						klog.ErrorS(err, "failed to resolve tag for registry policy", "path", rPath)
+						writeError(w, http.StatusBadGateway, errorCodeUnknown, "failed to check this tag against registry policy")
+						return
+					}
+					if err == nil && !policy.Allowed(repository, digest) {
+						klog.V(2).InfoS("denying tag request blocked by policy", "path", rPath, "digest", digest)
+						writeError(w, http.StatusForbidden, errorCodeDenied, "this content is blocked by registry policy")
+						return
+					}
+				}
+			}
+		}
+
+		// in proxy mode we serve everything ourselves instead of redirecting
+		if proxy != nil {
+			upstreamURL := upstreamRedirectURL(rc, rPath)
+			if useSignatureUpstream(rc, reCosignTag, rPath) {
+				upstreamURL = signatureRedirectURL(rc, rPath)
+			}
+			kind, digest := "", ""
+			if matches := reBlob.FindStringSubmatch(rPath); len(matches) == 2 {
+				kind, digest = "blobs", matches[1]
+				if local != nil && local.ServeBlob(w, r, digest) {
+					klog.V(2).InfoS("serving blob from local directory", "path", rPath)
+					return
+				}
+			} else if matches := reManifestDigest.FindStringSubmatch(rPath); len(matches) == 2 {
+				kind, digest = "manifests", matches[1]
+			}
+			klog.V(2).InfoS("proxying request to upstream", "path", rPath, "upstream", upstreamURL)
+			proxy.Proxy(w, r, upstreamURL, kind, digest)
 			return
 		}
 
+		// the client is looked up once, for the redirects below
+		client := lookupClient(r)
+
 		// check if blob request
 		matches := reBlob.FindStringSubmatch(rPath)
 		if len(matches) != 2 {
 			// check if this is a cosign signature/attestation request
-			if rc.SignatureUpstreamEndpoint != "" && reCosignTag.MatchString(rPath) {
-				redirectURL := signatureRedirectURL(rc, rPath)
+			if useSignatureUpstream(rc, reCosignTag, rPath) {
+				redirectURL := signatureRedirectURL(configForClient(client), rPath)
 				klog.V(2).InfoS("redirecting cosign signature request to canonical upstream", "path", rPath, "redirect", redirectURL)
 				http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
 				return
 			}
+			// check if this is a manifest request by digest, which we may
+			// be able to serve from a mirror
+			if rc.ServeManifestsFromBuckets {
+				if matches := reManifestDigest.FindStringSubmatch(rPath); len(matches) == 2 {
+					digest := matches[1]
+					bucketURL, ok := bucketForClient(w, client)
+					if !ok {
+						return
+					}
+					if bucketURL != "" {
+						if m, ok := manifests.FetchManifest(bucketURL, digest); ok {
+							klog.V(2).InfoS("serving manifest from mirror", "path", rPath)
+							serveManifest(w, r, digest, m)
+							return
+						}
+					}
+				}
+			}
 			// not a blob request so forward it to the main upstream registry
-			redirectURL := upstreamRedirectURL(rc, rPath)
+			redirectURL := upstreamRedirectURL(configForClient(client), rPath)
 			klog.V(2).InfoS("redirecting manifest request to upstream registry", "path", rPath, "redirect", redirectURL)
 			http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
 			return
@@ -133,50 +419,86 @@ func makeV2Handler(rc RegistryConfig, blobs blobChecker) func(w http.ResponseWri
 		// it is a blob request, grab the hash for later
 		digest := matches[1]
 
+		// serve the blob ourselves if it is available locally
+		if local != nil && local.ServeBlob(w, r, digest) {
+			klog.V(2).InfoS("serving blob from local directory", "path", rPath)
+			return
+		}
+
 		// for blob requests, check the client IP and determine the best backend
-		clientIP, err := clientip.Get(r)
-		if err != nil {
-			// this should not happen
-			klog.ErrorS(err, "failed to get client IP")
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		bucketURL, ok := bucketForClient(w, client)
+		if !ok {
 			return
 		}
 
 		// if client is coming from GCP, stay in GCP
-		ipInfo, ipIsKnown := regionMapper.GetIP(clientIP)
-		if ipIsKnown && ipInfo.Cloud == cloudcidrs.GCP {
-			redirectURL := upstreamRedirectURL(rc, rPath)
+		if bucketURL == "" {
+			redirectURL := upstreamRedirectURL(configForClient(client), rPath)
 			klog.V(2).InfoS("redirecting GCP blob request to upstream registry", "path", rPath, "redirect", redirectURL)
 			http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
 			return
 		}
 
 		// check if blob is available in our AWS layer storage for the region
-		region := ""
-		if ipIsKnown {
-			region = ipInfo.Region
-		}
-		bucketURL := awsRegionToHostURL(region, rc.DefaultAWSBaseURL)
 		// this matches GCR's GCS layout, which we will use for other buckets
 		blobURL := bucketURL + "/containers/images/" + digest
 		if blobs.BlobExists(blobURL) {
-			// blob known to be available in AWS, redirect client there
-			klog.V(2).InfoS("redirecting blob request to AWS", "path", rPath)
-			http.Redirect(w, r, blobURL, http.StatusTemporaryRedirect)
-			return
+			// private buckets need a signed URL for the client's request method
+			redirectURL, err := blobURL, error(nil)
+			if signer != nil {
+				redirectURL, err = signer.SignURL(r.Method, blobURL)
+			}
+			if err == nil {
+				// blob known to be available in AWS, redirect client there
+				klog.V(2).InfoS("redirecting blob request to AWS", "path", rPath)
+				http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
+				return
+			}
+			klog.ErrorS(err, "failed to sign blob URL, falling back to upstream", "url", blobURL)
 		}
 
 		// fall back to redirect to upstream
-		redirectURL := upstreamRedirectURL(rc, rPath)
+		redirectURL := upstreamRedirectURL(configForClient(client), rPath)
 		klog.V(2).InfoS("redirecting blob request to upstream registry", "path", rPath, "redirect", redirectURL)
 		http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
 	}
 }
 
+// clientInfo is the client IP of a request and its cloud, if known
+type clientInfo struct {
+	ip      netip.Addr
+	cloud   cloudcidrs.IPInfo
+	inCloud bool
+	// err is set if the client IP cannot be determined
+	err error
+}
+
 func upstreamRedirectURL(rc RegistryConfig, originalPath string) string {
+	if route, rest := upstreamRouteFor(rc, originalPath); route != nil {
+		return route.Endpoint + path.Join("/v2/", route.Path, rest)
+	}
 	return rc.UpstreamRegistryEndpoint + path.Join("/v2/", rc.UpstreamRegistryPath, strings.TrimPrefix(originalPath, "/v2"))
 }
 
 func signatureRedirectURL(rc RegistryConfig, originalPath string) string {
 	return rc.SignatureUpstreamEndpoint + path.Join("/v2/", rc.UpstreamRegistryPath, strings.TrimPrefix(originalPath, "/v2"))
 }
+
+// useSignatureUpstream returns true if rPath is a cosign signature or
+// attestation request that should be served from SignatureUpstreamEndpoint,
+// which only mirrors the default upstream, not UpstreamRoutes
+func useSignatureUpstream(rc RegistryConfig, reCosignTag *regexp.Regexp, rPath string) bool {
+	if rc.SignatureUpstreamEndpoint == "" || !reCosignTag.MatchString(rPath) {
+		return false
+	}
+	route, _ := upstreamRouteFor(rc, rPath)
+	return route == nil
+}
+
+// ipFamily returns "IPv4" or "IPv6" for addr, IPv4-mapped IPv6 addresses are IPv4
+func ipFamily(addr netip.Addr) string {
+	if addr.Unmap().Is4() {
+		return "IPv4"
+	}
+	return "IPv6"
+}
@@ -20,7 +20,14 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"k8s.io/registry.k8s.io/pkg/net/cidrs"
+	"k8s.io/registry.k8s.io/pkg/net/cloudcidrs"
 )
 
 func TestMakeHandler(t *testing.T) {
@@ -32,7 +39,7 @@ func TestMakeHandler(t *testing.T) {
 		PrivacyURL:               "https://www.linuxfoundation.org/privacy-policy/",
 		// SignatureUpstreamEndpoint intentionally unset to test fallback behavior
 	}
-	handler := MakeHandler(registryConfig)
+	handler := MakeHandler(t.Context(), registryConfig)
 	testCases := []struct {
 		Name           string
 		Request        *http.Request
@@ -159,6 +166,15 @@ func (f *fakeBlobsChecker) BlobExists(blobURL string) bool {
 	return f.knownURLs[blobURL]
 }
 
+type fakeManifestFetcher struct {
+	knownURLs map[string]*mirroredManifest
+}
+
+func (f *fakeManifestFetcher) FetchManifest(bucketURL, digest string) (*mirroredManifest, bool) {
+	m, ok := f.knownURLs[bucketURL+manifestKeyPrefix+digest]
+	return m, ok
+}
+
 func TestMakeV2Handler(t *testing.T) {
 	registryConfig := RegistryConfig{
 		UpstreamRegistryEndpoint:  "https://k8s.gcr.io",
@@ -179,7 +195,7 @@ func TestMakeV2Handler(t *testing.T) {
 			"https://prod-registry-k8s-io-us-west-1.s3.dualstack.us-west-1.amazonaws.com/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e":           true,
 		},
 	}
-	handler := makeV2Handler(registryConfig, &blobs)
+	handler := makeV2Handler(registryConfig, &blobs, &fakeManifestFetcher{}, nil, nil, nil, nil, nil, cloudcidrs.NewIPMapper(), nil)
 	testCases := []struct {
 		Name           string
 		Request        *http.Request
@@ -310,3 +326,521 @@ func TestMakeV2Handler(t *testing.T) {
 		})
 	}
 }
+
+func TestMakeV2HandlerManifests(t *testing.T) {
+	registryConfig := RegistryConfig{
+		UpstreamRegistryEndpoint:  "https://k8s.gcr.io",
+		UpstreamRegistryPath:      "",
+		DefaultAWSBaseURL:         "https://default.example",
+		ServeManifestsFromBuckets: true,
+	}
+	const digest = "sha256:7031c1b283388d2c2e09b57badb803c05ebed362dc88d84b480cc47f72a21097"
+	manifest := &mirroredManifest{
+		Raw:       []byte(`{"schemaVersion":2}`),
+		MediaType: "application/vnd.oci.image.index.v1+json",
+	}
+	manifests := fakeManifestFetcher{
+		knownURLs: map[string]*mirroredManifest{
+			"https://prod-registry-k8s-io-eu-west-3.s3.dualstack.eu-west-3.amazonaws.com/geranos/uploaded-images/" + digest: manifest,
+			"https://default.example/geranos/uploaded-images/" + digest:                                                     manifest,
+		},
+	}
+	handler := makeV2Handler(registryConfig, &fakeBlobsChecker{}, &manifests, nil, nil, nil, nil, nil, cloudcidrs.NewIPMapper(), nil)
+	testCases := []struct {
+		Name           string
+		Request        *http.Request
+		ExpectedStatus int
+		ExpectedURL    string
+		ExpectedBody   string
+	}{
+		{
+			Name: "AWS eu-west-3 IP, GET manifest by digest",
+			Request: func() *http.Request {
+				r := httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/"+digest, nil)
+				r.RemoteAddr = "35.180.1.1:888"
+				return r
+			}(),
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   string(manifest.Raw),
+		},
+		{
+			Name: "AWS eu-west-3 IP, HEAD manifest by digest",
+			Request: func() *http.Request {
+				r := httptest.NewRequest("HEAD", "http://localhost:8080/v2/pause/manifests/"+digest, nil)
+				r.RemoteAddr = "35.180.1.1:888"
+				return r
+			}(),
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:           "External IP, GET manifest by digest",
+			Request:        httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/"+digest, nil),
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   string(manifest.Raw),
+		},
+		{
+			Name: "GCP IP, GET manifest by digest",
+			Request: func() *http.Request {
+				r := httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/"+digest, nil)
+				r.RemoteAddr = "35.220.26.1:888"
+				return r
+			}(),
+			ExpectedStatus: http.StatusTemporaryRedirect,
+			ExpectedURL:    "https://k8s.gcr.io/v2/pause/manifests/" + digest,
+		},
+		{
+			Name:           "GET manifest by tag",
+			Request:        httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/3.9", nil),
+			ExpectedStatus: http.StatusTemporaryRedirect,
+			ExpectedURL:    "https://k8s.gcr.io/v2/pause/manifests/3.9",
+		},
+		{
+			Name: "AWS eu-west-3 IP, GET unknown manifest by digest",
+			Request: func() *http.Request {
+				r := httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1234567", nil)
+				r.RemoteAddr = "35.180.1.1:888"
+				return r
+			}(),
+			ExpectedStatus: http.StatusTemporaryRedirect,
+			ExpectedURL:    "https://k8s.gcr.io/v2/pause/manifests/sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1234567",
+		},
+		{
+			Name: "Somehow bogus remote addr, GET manifest by digest",
+			Request: func() *http.Request {
+				r := httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/"+digest, nil)
+				r.RemoteAddr = "35.180.1.1asdfasdfsd:888"
+				return r
+			}(),
+			ExpectedStatus: http.StatusBadRequest,
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			recorder := httptest.NewRecorder()
+			handler(recorder, tc.Request)
+			response := recorder.Result()
+			if response.StatusCode != tc.ExpectedStatus {
+				t.Fatalf(
+					"expected status: %v, but got status: %v",
+					http.StatusText(tc.ExpectedStatus),
+					http.StatusText(response.StatusCode),
+				)
+			}
+			if location := response.Header.Get("Location"); location != tc.ExpectedURL {
+				t.Fatalf("expected url: %q, but got: %q", tc.ExpectedURL, location)
+			}
+			if tc.ExpectedStatus != http.StatusOK {
+				return
+			}
+			if contentType := response.Header.Get("Content-Type"); contentType != manifest.MediaType {
+				t.Fatalf("expected Content-Type: %q, but got: %q", manifest.MediaType, contentType)
+			}
+			if contentDigest := response.Header.Get("Docker-Content-Digest"); contentDigest != digest {
+				t.Fatalf("expected Docker-Content-Digest: %q, but got: %q", digest, contentDigest)
+			}
+			if body := recorder.Body.String(); body != tc.ExpectedBody {
+				t.Fatalf("expected body: %q, but got: %q", tc.ExpectedBody, body)
+			}
+		})
+	}
+}
+
+type fakeBlobServer struct {
+	knownDigests map[string]string
+}
+
+func (f *fakeBlobServer) ServeBlob(w http.ResponseWriter, _ *http.Request, digest string) bool {
+	content, ok := f.knownDigests[digest]
+	if ok {
+		_, _ = w.Write([]byte(content))
+	}
+	return ok
+}
+
+func TestMakeV2HandlerLocalBlobs(t *testing.T) {
+	registryConfig := RegistryConfig{
+		UpstreamRegistryEndpoint: "https://k8s.gcr.io",
+		UpstreamRegistryPath:     "",
+		DefaultAWSBaseURL:        "https://default.example",
+	}
+	const digest = "sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e"
+	const missing = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1234567"
+	local := fakeBlobServer{knownDigests: map[string]string{digest: "layer"}}
+	handler := makeV2Handler(registryConfig, &fakeBlobsChecker{}, &fakeManifestFetcher{}, &local, nil, nil, nil, nil, cloudcidrs.NewIPMapper(), nil)
+	testCases := []struct {
+		Name           string
+		Request        *http.Request
+		ExpectedStatus int
+		ExpectedURL    string
+		ExpectedBody   string
+	}{
+		{
+			Name: "GCP IP, GET local blob",
+			Request: func() *http.Request {
+				r := httptest.NewRequest("GET", "http://localhost:8080/v2/pause/blobs/"+digest, nil)
+				r.RemoteAddr = "35.220.26.1:888"
+				return r
+			}(),
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   "layer",
+		},
+		{
+			Name:           "External IP, GET local blob",
+			Request:        httptest.NewRequest("GET", "http://localhost:8080/v2/pause/blobs/"+digest, nil),
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   "layer",
+		},
+		{
+			Name:           "GET missing local blob",
+			Request:        httptest.NewRequest("GET", "http://localhost:8080/v2/pause/blobs/"+missing, nil),
+			ExpectedStatus: http.StatusTemporaryRedirect,
+			ExpectedURL:    "https://k8s.gcr.io/v2/pause/blobs/" + missing,
+		},
+		{
+			Name:           "GET manifest",
+			Request:        httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/"+digest, nil),
+			ExpectedStatus: http.StatusTemporaryRedirect,
+			ExpectedURL:    "https://k8s.gcr.io/v2/pause/manifests/" + digest,
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			recorder := httptest.NewRecorder()
+			handler(recorder, tc.Request)
+			response := recorder.Result()
+			if response.StatusCode != tc.ExpectedStatus {
+				t.Fatalf(
+					"expected status: %v, but got status: %v",
+					http.StatusText(tc.ExpectedStatus),
+					http.StatusText(response.StatusCode),
+				)
+			}
+			if location := response.Header.Get("Location"); location != tc.ExpectedURL {
+				t.Fatalf("expected url: %q, but got: %q", tc.ExpectedURL, location)
+			}
+			if body := recorder.Body.String(); tc.ExpectedStatus == http.StatusOK && body != tc.ExpectedBody {
+				t.Fatalf("expected body: %q, but got: %q", tc.ExpectedBody, body)
+			}
+		})
+	}
+}
+
+func TestMakeV2HandlerIPFamilies(t *testing.T) {
+	registryConfig := RegistryConfig{
+		UpstreamRegistryEndpoint:     "https://us-central1-docker.pkg.dev",
+		UpstreamRegistryEndpointIPv6: "https://ipv6.example",
+		UpstreamRegistryPath:         "k8s-artifacts-prod/images",
+		UpstreamRegistryLocations: map[string]string{
+			"europe-*": "europe",
+		},
+		SignatureUpstreamEndpoint:     "https://us-central1-docker.pkg.dev",
+		SignatureUpstreamEndpointIPv6: "https://signatures-ipv6.example",
+		DefaultAWSBaseURL:             "https://default.example",
+		DefaultAWSBaseURLIPv6:         "https://default-ipv6.example",
+	}
+	blobs := fakeBlobsChecker{
+		knownURLs: map[string]bool{
+			"https://prod-registry-k8s-io-eu-west-3.s3.dualstack.eu-west-3.amazonaws.com/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e": true,
+			"https://default.example/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e":                                                     true,
+			"https://default-ipv6.example/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e":                                                true,
+		},
+	}
+	handler := makeV2Handler(registryConfig, &blobs, &fakeManifestFetcher{}, nil, nil, nil, nil, nil, cloudcidrs.NewIPMapper(), nil)
+	testCases := []struct {
+		Name        string
+		Path        string
+		ClientIP    string
+		ExpectedURL string
+	}{
+		{
+			Name:        "IPv4 GCP client, manifest",
+			Path:        "/v2/pause/manifests/3.9",
+			ClientIP:    "35.220.26.1",
+			ExpectedURL: "https://europe-docker.pkg.dev/v2/k8s-artifacts-prod/images/pause/manifests/3.9",
+		},
+		{
+			Name:        "IPv6 GCP client, manifest",
+			Path:        "/v2/pause/manifests/3.9",
+			ClientIP:    "2600:1900:4010::1",
+			ExpectedURL: "https://ipv6.example/v2/k8s-artifacts-prod/images/pause/manifests/3.9",
+		},
+		{
+			Name:        "IPv4 external client, manifest",
+			Path:        "/v2/pause/manifests/3.9",
+			ClientIP:    "192.168.0.1",
+			ExpectedURL: "https://us-central1-docker.pkg.dev/v2/k8s-artifacts-prod/images/pause/manifests/3.9",
+		},
+		{
+			Name:        "IPv4-mapped IPv6 external client, manifest",
+			Path:        "/v2/pause/manifests/3.9",
+			ClientIP:    "::ffff:192.168.0.1",
+			ExpectedURL: "https://us-central1-docker.pkg.dev/v2/k8s-artifacts-prod/images/pause/manifests/3.9",
+		},
+		{
+			Name:        "IPv6 external client, manifest",
+			Path:        "/v2/pause/manifests/3.9",
+			ClientIP:    "2001:db8::1",
+			ExpectedURL: "https://ipv6.example/v2/k8s-artifacts-prod/images/pause/manifests/3.9",
+		},
+		{
+			Name:        "IPv4 external client, signature",
+			Path:        "/v2/pause/manifests/sha256-da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e.sig",
+			ClientIP:    "192.168.0.1",
+			ExpectedURL: "https://us-central1-docker.pkg.dev/v2/k8s-artifacts-prod/images/pause/manifests/sha256-da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e.sig",
+		},
+		{
+			Name:        "IPv6 external client, signature",
+			Path:        "/v2/pause/manifests/sha256-da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e.sig",
+			ClientIP:    "2001:db8::1",
+			ExpectedURL: "https://signatures-ipv6.example/v2/k8s-artifacts-prod/images/pause/manifests/sha256-da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e.sig",
+		},
+		{
+			Name:        "IPv4 AWS client, blob",
+			Path:        "/v2/pause/blobs/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+			ClientIP:    "35.180.1.1",
+			ExpectedURL: "https://prod-registry-k8s-io-eu-west-3.s3.dualstack.eu-west-3.amazonaws.com/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+		},
+		{
+			// our regional buckets are dualstack, so are used for both families
+			Name:        "IPv6 AWS client, blob",
+			Path:        "/v2/pause/blobs/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+			ClientIP:    "2600:1f01:4810::1",
+			ExpectedURL: "https://prod-registry-k8s-io-eu-west-3.s3.dualstack.eu-west-3.amazonaws.com/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+		},
+		{
+			Name:        "IPv6 AWS client without a regional bucket, blob",
+			Path:        "/v2/pause/blobs/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+			ClientIP:    "2600:1f15::1",
+			ExpectedURL: "https://default-ipv6.example/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest("GET", "http://localhost:8080"+tc.Path, nil)
+			// mirror the fake load balancer headers from the integration tests
+			r.Header.Set("X-Forwarded-For", tc.ClientIP+",0.0.0.0")
+			recorder := httptest.NewRecorder()
+			handler(recorder, r)
+			response := recorder.Result()
+			if response.StatusCode != http.StatusTemporaryRedirect {
+				t.Fatalf("expected status: %v, but got status: %v", http.StatusTemporaryRedirect, response.StatusCode)
+			}
+			if location := response.Header.Get("Location"); location != tc.ExpectedURL {
+				t.Fatalf("expected url: %q, but got: %q", tc.ExpectedURL, location)
+			}
+		})
+	}
+}
+
+// countingCloudIPs counts lookups of the wrapped mapper
+type countingCloudIPs struct {
+	cidrs.IPMapper[cloudcidrs.IPInfo]
+	lookups int
+}
+
+func (c *countingCloudIPs) GetIP(ip netip.Addr) (cloudcidrs.IPInfo, bool) {
+	c.lookups++
+	return c.IPMapper.GetIP(ip)
+}
+
+func TestMakeV2HandlerLooksUpClientOnce(t *testing.T) {
+	registryConfig := RegistryConfig{
+		UpstreamRegistryEndpoint: "https://us-central1-docker.pkg.dev",
+		UpstreamRegistryPath:     "k8s-artifacts-prod/images",
+		UpstreamRegistryLocations: map[string]string{
+			"europe-*": "europe",
+		},
+		DefaultAWSBaseURL: "https://default.example",
+	}
+	clouds := &countingCloudIPs{IPMapper: fakeCloudIPs{
+		netip.MustParseAddr("35.220.26.1"): {Cloud: cloudcidrs.GCP, Region: "europe-west1"},
+	}}
+	handler := makeV2Handler(registryConfig, &fakeBlobsChecker{}, &fakeManifestFetcher{}, nil, nil, nil, nil, nil, clouds, nil)
+	// both the bucket and the upstream registry depend on the client,
+	// for blobs from GCP and blobs missing from the bucket
+	for _, clientIP := range []string{"35.220.26.1", "192.168.0.1"} {
+		clouds.lookups = 0
+		r := httptest.NewRequest("GET", "http://localhost:8080/v2/pause/blobs/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e", nil)
+		r.Header.Set("X-Forwarded-For", clientIP+",0.0.0.0")
+		recorder := httptest.NewRecorder()
+		handler(recorder, r)
+		if recorder.Code != http.StatusTemporaryRedirect {
+			t.Fatalf("%s: expected status: %v, but got status: %v", clientIP, http.StatusTemporaryRedirect, recorder.Code)
+		}
+		if clouds.lookups != 1 {
+			t.Fatalf("%s: expected the client to be looked up once, but got %d lookups", clientIP, clouds.lookups)
+		}
+	}
+}
+
+func TestIPFamily(t *testing.T) {
+	testCases := []struct {
+		Addr     string
+		Expected string
+	}{
+		{Addr: "35.180.1.1", Expected: "IPv4"},
+		{Addr: "::ffff:35.180.1.1", Expected: "IPv4"},
+		{Addr: "2600:1f01:4810::1", Expected: "IPv6"},
+		{Addr: "::1", Expected: "IPv6"},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Addr, func(t *testing.T) {
+			t.Parallel()
+			if family := ipFamily(netip.MustParseAddr(tc.Addr)); family != tc.Expected {
+				t.Fatalf("expected family: %q, but got: %q", tc.Expected, family)
+			}
+		})
+	}
+}
+
+func TestMakeHandlerOptions(t *testing.T) {
+	const digest = "sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e"
+	localBlobs := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(localBlobs, "containers", "images"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(localBlobs, "containers", "images", digest), []byte("layer"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("upstream"))
+	}))
+	t.Cleanup(upstream.Close)
+	// a cache directory that cannot be used
+	brokenCache := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(brokenCache, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	pinnedTagsFile := filepath.Join(t.TempDir(), "pins.json")
+	if err := os.WriteFile(pinnedTagsFile, []byte(`{"pause": {"3.9": "`+digest+`"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// a token cannot be verified until the JWKS file exists
+	tokenRequest := httptest.NewRequest("GET", "http://localhost:8080/v2/", nil)
+	tokenRequest.Header.Set("Authorization", "Bearer e30.e30.e30")
+	// clients whose IP cannot be determined get the default endpoints
+	unknownIPRequest := httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/3.9", nil)
+	unknownIPRequest.RemoteAddr = "unknown"
+	testCases := []struct {
+		Name           string
+		Config         RegistryConfig
+		Request        *http.Request
+		ExpectedStatus int
+		ExpectedURL    string
+		ExpectedBody   string
+	}{
+		{
+			Name:           "local blob",
+			Config:         RegistryConfig{LocalBlobDirectory: localBlobs},
+			Request:        httptest.NewRequest("GET", "http://localhost:8080/v2/pause/blobs/"+digest, nil),
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   "layer",
+		},
+		{
+			Name:           "missing policy file denies everything",
+			Config:         RegistryConfig{PolicyFile: filepath.Join(t.TempDir(), "missing.json")},
+			Request:        httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/3.9", nil),
+			ExpectedStatus: http.StatusForbidden,
+		},
+		{
+			Name:           "pinned tag",
+			Config:         RegistryConfig{PinnedTagsFile: pinnedTagsFile, UpstreamRegistryEndpoint: upstream.URL},
+			Request:        httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/3.9", nil),
+			ExpectedStatus: http.StatusTemporaryRedirect,
+			ExpectedURL:    "/v2/pause/manifests/" + digest,
+		},
+		{
+			// blobs would be checked for over the network, so GeoIP
+			// routing is covered by TestMakeV2HandlerGeoIP
+			Name:           "GeoIP database",
+			Config:         RegistryConfig{GeoIPDatabaseFile: writeTestGeoIPDatabase(t)},
+			Request:        httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/3.9", nil),
+			ExpectedStatus: http.StatusTemporaryRedirect,
+			ExpectedURL:    "https://k8s.gcr.io/v2/pause/manifests/3.9",
+		},
+		{
+			Name:           "unknown client IP",
+			Config:         RegistryConfig{UpstreamRegistryEndpointIPv6: "https://ipv6.example.com"},
+			Request:        unknownIPRequest,
+			ExpectedStatus: http.StatusTemporaryRedirect,
+			ExpectedURL:    "https://k8s.gcr.io/v2/pause/manifests/3.9",
+		},
+		{
+			Name: "token auth without keys",
+			Config: RegistryConfig{
+				TokenAuthRealm:    "https://auth.example.com/token",
+				TokenAuthService:  "registry.example.com",
+				TokenAuthJWKSFile: filepath.Join(t.TempDir(), "missing.json"),
+			},
+			Request:        tokenRequest,
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "signed bucket URLs",
+			Config:         RegistryConfig{SignBucketURLs: true, SignedURLExpiry: time.Minute},
+			Request:        httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/3.9", nil),
+			ExpectedStatus: http.StatusTemporaryRedirect,
+			ExpectedURL:    "https://k8s.gcr.io/v2/pause/manifests/3.9",
+		},
+		{
+			Name:           "proxied local blob",
+			Config:         RegistryConfig{LocalBlobDirectory: localBlobs, ProxyUpstream: true, UpstreamRegistryEndpoint: upstream.URL},
+			Request:        httptest.NewRequest("GET", "http://localhost:8080/v2/pause/blobs/"+digest, nil),
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   "layer",
+		},
+		{
+			Name:           "proxied with cache",
+			Config:         RegistryConfig{ProxyUpstream: true, ProxyCacheDirectory: t.TempDir(), ProxyCacheMaxBytes: 1024, UpstreamRegistryEndpoint: upstream.URL},
+			Request:        httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/3.9", nil),
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   "upstream",
+		},
+		{
+			Name:           "proxied signature",
+			Config:         RegistryConfig{ProxyUpstream: true, SignatureUpstreamEndpoint: upstream.URL},
+			Request:        httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/sha256-da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e.sig", nil),
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   "upstream",
+		},
+		{
+			Name:           "proxied without a usable cache",
+			Config:         RegistryConfig{ProxyUpstream: true, ProxyCacheDirectory: brokenCache, UpstreamRegistryEndpoint: upstream.URL},
+			Request:        httptest.NewRequest("GET", "http://localhost:8080/v2/pause/manifests/3.9", nil),
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   "upstream",
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			if tc.Config.UpstreamRegistryEndpoint == "" {
+				tc.Config.UpstreamRegistryEndpoint = "https://k8s.gcr.io"
+			}
+			recorder := httptest.NewRecorder()
+			MakeHandler(t.Context(), tc.Config).ServeHTTP(recorder, tc.Request)
+			response := recorder.Result()
+			if response.StatusCode != tc.ExpectedStatus {
+				t.Fatalf(
+					"expected status: %v, but got status: %v",
+					http.StatusText(tc.ExpectedStatus),
+					http.StatusText(response.StatusCode),
+				)
+			}
+			if location := response.Header.Get("Location"); location != tc.ExpectedURL {
+				t.Fatalf("expected url: %q, but got: %q", tc.ExpectedURL, location)
+			}
+			if body := recorder.Body.String(); tc.ExpectedBody != "" && body != tc.ExpectedBody {
+				t.Fatalf("expected body: %q, but got: %q", tc.ExpectedBody, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// reDigest matches digests per the OCI image spec grammar, notably this
+// excludes '/' and '..' so a digest is always safe to use in a path
+// https://github.com/opencontainers/image-spec/blob/main/descriptor.md#digests
+var reDigest = regexp.MustCompile(`^[a-z0-9]+(?:[+._-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+
+// blobServer is used to serve blobs directly instead of redirecting
+type blobServer interface {
+	// ServeBlob should write a response for the blob with digest,
+	// returning false without writing anything if it is not available
+	ServeBlob(w http.ResponseWriter, r *http.Request, digest string) bool
+}
+
+// localBlobServer serves blobs from a local directory, for air-gapped and
+// on-prem mirrors, the directory may either be:
+//
+// - an OCI image layout, with blobs at blobs/$algorithm/$encoded
+// https://github.com/opencontainers/image-spec/blob/main/image-layout.md
+//
+// - the bucket layout geranos mirrors to, with blobs at containers/images/$digest
+//
+// The directory is trusted, so unlike mirror buckets we do not verify blobs.
+type localBlobServer struct {
+	root      string
+	ociLayout bool
+}
+
+// newLocalBlobServer returns a localBlobServer for root, detecting the layout
+func newLocalBlobServer(root string) *localBlobServer {
+	_, err := os.Stat(filepath.Join(root, "oci-layout"))
+	return &localBlobServer{
+		root:      root,
+		ociLayout: err == nil,
+	}
+}
+
+func (l *localBlobServer) blobPath(digest string) string {
+	if l.ociLayout {
+		algorithm, encoded, _ := strings.Cut(digest, ":")
+		return filepath.Join(l.root, "blobs", algorithm, encoded)
+	}
+	return filepath.Join(l.root, "containers", "images", digest)
+}
+
+func (l *localBlobServer) ServeBlob(w http.ResponseWriter, r *http.Request, digest string) bool {
+	if !reDigest.MatchString(digest) {
+		return false
+	}
+	blobPath := l.blobPath(digest)
+	// #nosec G304 -- digest is validated above
+	f, err := os.Open(blobPath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			klog.ErrorS(err, "failed to open local blob", "path", blobPath)
+		}
+		return false
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() {
+		klog.ErrorS(err, "local blob is not a regular file", "path", blobPath)
+		return false
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", digest)
+	// blobs are content addressed, so the digest is a strong ETag
+	w.Header().Set("Etag", `"`+digest+`"`)
+	// ServeContent handles HEAD, Range and Content-Length for us,
+	// the zero time avoids setting Last-Modified
+	http.ServeContent(w, r, "", time.Time{}, f)
+	return true
+}
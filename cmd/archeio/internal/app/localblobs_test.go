@@ -0,0 +1,119 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string, content []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLocalBlobServer(t *testing.T) {
+	blob := []byte("some layer content")
+	digest := sha256Digest(blob)
+	encoded := strings.TrimPrefix(digest, "sha256:")
+
+	ociLayout := t.TempDir()
+	writeFile(t, filepath.Join(ociLayout, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`))
+	writeFile(t, filepath.Join(ociLayout, "index.json"), []byte(`{"schemaVersion":2,"manifests":[]}`))
+	writeFile(t, filepath.Join(ociLayout, "blobs", "sha256", encoded), blob)
+
+	bucketLayout := t.TempDir()
+	writeFile(t, filepath.Join(bucketLayout, "containers", "images", digest), blob)
+
+	for _, root := range []string{ociLayout, bucketLayout} {
+		server := newLocalBlobServer(root)
+		testCases := []struct {
+			Name            string
+			Method          string
+			Digest          string
+			Range           string
+			ExpectedServed  bool
+			ExpectedStatus  int
+			ExpectedContent string
+		}{
+			{Name: "GET", Method: http.MethodGet, Digest: digest, ExpectedServed: true, ExpectedStatus: http.StatusOK, ExpectedContent: string(blob)},
+			{Name: "HEAD", Method: http.MethodHead, Digest: digest, ExpectedServed: true, ExpectedStatus: http.StatusOK},
+			{Name: "Range", Method: http.MethodGet, Digest: digest, Range: "bytes=5-9", ExpectedServed: true, ExpectedStatus: http.StatusPartialContent, ExpectedContent: "layer"},
+			{Name: "missing", Method: http.MethodGet, Digest: sha256Digest([]byte("missing")), ExpectedServed: false},
+			{Name: "invalid digest", Method: http.MethodGet, Digest: "..:" + encoded, ExpectedServed: false},
+		}
+		for i := range testCases {
+			tc := testCases[i]
+			t.Run(filepath.Base(root)+"/"+tc.Name, func(t *testing.T) {
+				r := httptest.NewRequest(tc.Method, "http://localhost/v2/pause/blobs/"+tc.Digest, nil)
+				if tc.Range != "" {
+					r.Header.Set("Range", tc.Range)
+				}
+				recorder := httptest.NewRecorder()
+				if served := server.ServeBlob(recorder, r, tc.Digest); served != tc.ExpectedServed {
+					t.Fatalf("expected served to be %v", tc.ExpectedServed)
+				}
+				if !tc.ExpectedServed {
+					return
+				}
+				response := recorder.Result()
+				if response.StatusCode != tc.ExpectedStatus {
+					t.Fatalf("expected status %d but got %d", tc.ExpectedStatus, response.StatusCode)
+				}
+				if d := response.Header.Get("Docker-Content-Digest"); d != tc.Digest {
+					t.Fatalf("expected Docker-Content-Digest %q but got %q", tc.Digest, d)
+				}
+				if tc.Method == http.MethodHead && response.ContentLength != int64(len(blob)) {
+					t.Fatalf("expected Content-Length %d but got %d", len(blob), response.ContentLength)
+				}
+				if body := recorder.Body.String(); body != tc.ExpectedContent {
+					t.Fatalf("expected content %q but got %q", tc.ExpectedContent, body)
+				}
+			})
+		}
+	}
+}
+
+func TestLocalBlobServerUnreadable(t *testing.T) {
+	digest := sha256Digest([]byte("some layer content"))
+
+	// blobs must be regular files
+	directory := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(directory, "containers", "images", digest), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// the layout may be broken
+	file := t.TempDir()
+	writeFile(t, filepath.Join(file, "containers"), []byte("not a directory"))
+
+	for _, root := range []string{directory, file} {
+		r := httptest.NewRequest(http.MethodGet, "http://localhost/v2/pause/blobs/"+digest, nil)
+		recorder := httptest.NewRecorder()
+		if newLocalBlobServer(root).ServeBlob(recorder, r, digest) {
+			t.Errorf("expected blob not to be served from %s", root)
+		}
+	}
+}
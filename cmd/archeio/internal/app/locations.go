@@ -0,0 +1,75 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// reARLocation matches Artifact Registry locations, e.g. "us", "europe-west1"
+var reARLocation = regexp.MustCompile("^[a-z][a-z0-9-]*[a-z0-9]$")
+
+// ParseUpstreamRegistryLocations parses comma separated region=location pairs
+// mapping GCP regions to Artifact Registry locations, e.g.
+// "us-*=us,europe-*=europe,asia-*=asia,australia-southeast1=australia-southeast1"
+//
+// A region ending in "*" matches any region with that prefix.
+func ParseUpstreamRegistryLocations(s string) (map[string]string, error) {
+	locations := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		region, location, ok := strings.Cut(pair, "=")
+		if !ok || region == "" || region == "*" {
+			return nil, fmt.Errorf("invalid upstream registry location, expected region=location: %q", pair)
+		}
+		if !reARLocation.MatchString(location) {
+			return nil, fmt.Errorf("invalid Artifact Registry location for region %q: %q", region, location)
+		}
+		locations[region] = location
+	}
+	return locations, nil
+}
+
+// arLocationForRegion returns the Artifact Registry location for a GCP region,
+// preferring an exact match and then the longest matching wildcard prefix,
+// or false if there is no matching location
+func arLocationForRegion(locations map[string]string, region string) (string, bool) {
+	if location, ok := locations[region]; ok {
+		return location, true
+	}
+	bestPrefix, bestLocation := "", ""
+	for pattern, location := range locations {
+		prefix, isWildcard := strings.CutSuffix(pattern, "*")
+		if !isWildcard || !strings.HasPrefix(region, prefix) {
+			continue
+		}
+		if bestLocation == "" || len(prefix) > len(bestPrefix) {
+			bestPrefix, bestLocation = prefix, location
+		}
+	}
+	return bestLocation, bestLocation != ""
+}
+
+// arLocationEndpoint returns the Artifact Registry endpoint for a location
+func arLocationEndpoint(location string) string {
+	return "https://" + location + "-docker.pkg.dev"
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/registry.k8s.io/pkg/net/cloudcidrs"
+)
+
+func TestParseUpstreamRegistryLocations(t *testing.T) {
+	locations, err := ParseUpstreamRegistryLocations("us-*=us, europe-*=europe,europe-north1=europe-north1,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(locations) != 3 || locations["europe-*"] != "europe" {
+		t.Fatalf("unexpected locations: %v", locations)
+	}
+	for _, invalid := range []string{"us-*", "=us", "*=us", "us-*=https://us-docker.pkg.dev", "us-*=US"} {
+		if _, err := ParseUpstreamRegistryLocations(invalid); err == nil {
+			t.Fatalf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestARLocationForRegion(t *testing.T) {
+	locations := map[string]string{
+		"us-*":          "us",
+		"europe-*":      "europe",
+		"europe-west*":  "europe-west1",
+		"europe-north1": "europe-north1",
+	}
+	testCases := []struct {
+		Region           string
+		ExpectedLocation string
+		ExpectedOK       bool
+	}{
+		{Region: "europe-north1", ExpectedLocation: "europe-north1", ExpectedOK: true},
+		{Region: "europe-west4", ExpectedLocation: "europe-west1", ExpectedOK: true},
+		{Region: "europe-central2", ExpectedLocation: "europe", ExpectedOK: true},
+		{Region: "us-east4", ExpectedLocation: "us", ExpectedOK: true},
+		{Region: "asia-east1", ExpectedLocation: "", ExpectedOK: false},
+		{Region: "", ExpectedLocation: "", ExpectedOK: false},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Region, func(t *testing.T) {
+			t.Parallel()
+			location, ok := arLocationForRegion(locations, tc.Region)
+			if location != tc.ExpectedLocation || ok != tc.ExpectedOK {
+				t.Fatalf("expected location: %q, %v but got: %q, %v", tc.ExpectedLocation, tc.ExpectedOK, location, ok)
+			}
+		})
+	}
+}
+
+func TestMakeV2HandlerUpstreamRegistryLocations(t *testing.T) {
+	registryConfig := RegistryConfig{
+		UpstreamRegistryEndpoint: "https://us-central1-docker.pkg.dev",
+		UpstreamRegistryPath:     "k8s-artifacts-prod/images",
+		UpstreamRegistryLocations: map[string]string{
+			"europe-*": "europe",
+		},
+		UpstreamRoutes: []UpstreamRoute{
+			{Prefix: "sig-storage", Endpoint: "https://us-docker.pkg.dev", Path: "k8s-sig-storage/images"},
+		},
+	}
+	handler := makeV2Handler(registryConfig, &fakeBlobsChecker{}, &fakeManifestFetcher{}, nil, nil, nil, nil, nil, cloudcidrs.NewIPMapper(), nil)
+	testCases := []struct {
+		Name        string
+		Path        string
+		RemoteAddr  string
+		ExpectedURL string
+	}{
+		{
+			Name:        "GCP europe IP, manifest",
+			Path:        "/v2/pause/manifests/3.9",
+			RemoteAddr:  "35.220.26.1:888",
+			ExpectedURL: "https://europe-docker.pkg.dev/v2/k8s-artifacts-prod/images/pause/manifests/3.9",
+		},
+		{
+			Name:        "GCP europe IP, blob",
+			Path:        "/v2/pause/blobs/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+			RemoteAddr:  "34.76.0.1:888",
+			ExpectedURL: "https://europe-docker.pkg.dev/v2/k8s-artifacts-prod/images/pause/blobs/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+		},
+		{
+			Name:        "GCP europe IP, routed repository",
+			Path:        "/v2/sig-storage/csi-attacher/manifests/v4.0.0",
+			RemoteAddr:  "35.220.26.1:888",
+			ExpectedURL: "https://us-docker.pkg.dev/v2/k8s-sig-storage/images/csi-attacher/manifests/v4.0.0",
+		},
+		{
+			Name:        "AWS IP, manifest",
+			Path:        "/v2/pause/manifests/3.9",
+			RemoteAddr:  "35.180.1.1:888",
+			ExpectedURL: "https://us-central1-docker.pkg.dev/v2/k8s-artifacts-prod/images/pause/manifests/3.9",
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest("GET", "http://localhost:8080"+tc.Path, nil)
+			r.RemoteAddr = tc.RemoteAddr
+			recorder := httptest.NewRecorder()
+			handler(recorder, r)
+			response := recorder.Result()
+			if response.StatusCode != http.StatusTemporaryRedirect {
+				t.Fatalf("expected status: %v, but got status: %v", http.StatusTemporaryRedirect, response.StatusCode)
+			}
+			if location := response.Header.Get("Location"); location != tc.ExpectedURL {
+				t.Fatalf("expected url: %q, but got: %q", tc.ExpectedURL, location)
+			}
+		})
+	}
+}
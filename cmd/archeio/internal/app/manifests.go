@@ -0,0 +1,161 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// geranos records the manifests it has finished mirroring at
+// /geranos/uploaded-images/$digest in each bucket, along with the media type
+// at /geranos/uploaded-images/$digest.mediatype
+//
+// a manifest is only recorded after all of its blobs have been mirrored
+const manifestKeyPrefix = "/geranos/uploaded-images/"
+
+// maxManifestSize is the largest manifest we will fetch from a bucket, this
+// matches the limit recommended by the OCI distribution spec
+const maxManifestSize = 4 * 1024 * 1024
+
+// digestAlgorithms are the digest algorithms we can verify content with,
+// these are the algorithms registered in the OCI image spec
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// mirroredManifest is a manifest fetched from a mirror bucket
+type mirroredManifest struct {
+	Raw       []byte
+	MediaType string
+}
+
+// manifestFetcher is used to fetch manifests mirrored to a bucket, possibly with caching
+type manifestFetcher interface {
+	// FetchManifest should fetch the manifest for digest from bucketURL,
+	// returning false if it is not available
+	FetchManifest(bucketURL, digest string) (*mirroredManifest, bool)
+}
+
+// cachedManifestFetcher fetches manifests from buckets and caches them
+//
+// Manifests are content addressed, so we only need to cache by digest and
+// never need to invalidate the cache. Manifests are also small, and clients
+// request relatively few unique manifests, so we don't bother limiting the
+// cache size.
+type cachedManifestFetcher struct {
+	m sync.Map
+	// signer, if set, is used to sign requests to private buckets
+	signer urlSigner
+}
+
+func newCachedManifestFetcher(signer urlSigner) *cachedManifestFetcher {
+	return &cachedManifestFetcher{signer: signer}
+}
+
+func (c *cachedManifestFetcher) FetchManifest(bucketURL, digest string) (*mirroredManifest, bool) {
+	if m, ok := c.m.Load(digest); ok {
+		klog.V(3).InfoS("manifest found in cache", "digest", digest)
+		return m.(*mirroredManifest), true
+	}
+	algorithm, expectedHex, _ := strings.Cut(digest, ":")
+	newHash, ok := digestAlgorithms[algorithm]
+	if !ok {
+		return nil, false
+	}
+	manifestURL := bucketURL + manifestKeyPrefix + digest
+	raw, ok := c.fetchSmallObject(manifestURL)
+	if !ok {
+		return nil, false
+	}
+	// buckets are less trusted than the upstream registry,
+	// so make sure we serve exactly the requested content
+	h := newHash()
+	_, _ = h.Write(raw)
+	if hex.EncodeToString(h.Sum(nil)) != expectedHex {
+		klog.ErrorS(nil, "mirrored manifest does not match digest", "url", manifestURL)
+		return nil, false
+	}
+	mediaType, ok := c.fetchSmallObject(manifestURL + ".mediatype")
+	if !ok || len(mediaType) == 0 {
+		return nil, false
+	}
+	m := &mirroredManifest{
+		Raw:       raw,
+		MediaType: strings.TrimSpace(string(mediaType)),
+	}
+	c.m.Store(digest, m)
+	return m, true
+}
+
+// fetchSmallObject GETs objectURL, returning false on any error
+func (c *cachedManifestFetcher) fetchSmallObject(objectURL string) ([]byte, bool) {
+	// the signed URL is not logged, as it is a credential until it expires
+	fetchURL := objectURL
+	if c.signer != nil {
+		signed, err := c.signer.SignURL(http.MethodGet, objectURL)
+		if err != nil {
+			klog.ErrorS(err, "failed to sign object URL", "url", objectURL)
+			return nil, false
+		}
+		fetchURL = signed
+	}
+	// NOTE: this client will still share http.DefaultTransport
+	// We do not wish to share the rest of the client state currently
+	client := &http.Client{
+		// ensure sensible timeouts
+		Timeout: time.Second * 5,
+	}
+	r, err := client.Get(fetchURL)
+	if err != nil {
+		klog.V(3).InfoS("failed to fetch object", "url", objectURL, "err", err)
+		return nil, false
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		klog.V(3).InfoS("fetching object returned non-OK status", "url", objectURL, "status", r.StatusCode)
+		return nil, false
+	}
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxManifestSize+1))
+	if err != nil || len(b) > maxManifestSize {
+		klog.V(3).InfoS("failed to read object", "url", objectURL, "err", err)
+		return nil, false
+	}
+	return b, true
+}
+
+// serveManifest writes a mirrored manifest response for digest
+func serveManifest(w http.ResponseWriter, r *http.Request, digest string, m *mirroredManifest) {
+	w.Header().Set("Content-Type", m.MediaType)
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.Header().Set("Content-Length", strconv.Itoa(len(m.Raw)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(m.Raw)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func sha256Digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func sha512Digest(b []byte) string {
+	sum := sha512.Sum512(b)
+	return "sha512:" + hex.EncodeToString(sum[:])
+}
+
+func TestCachedManifestFetcher(t *testing.T) {
+	manifest := []byte(`{"schemaVersion":2}`)
+	good := sha256Digest(manifest)
+	good512 := sha512Digest(manifest)
+	noMediaType := sha256Digest([]byte(`{"schemaVersion":2,"no":"mediatype"}`))
+	tooBig := []byte(strings.Repeat("a", maxManifestSize+1))
+	tooBigDigest := sha256Digest(tooBig)
+	mismatched := sha256Digest([]byte("something else"))
+	objects := map[string][]byte{
+		manifestKeyPrefix + good:                   manifest,
+		manifestKeyPrefix + good + ".mediatype":    []byte("application/vnd.oci.image.manifest.v1+json\n"),
+		manifestKeyPrefix + good512:                manifest,
+		manifestKeyPrefix + good512 + ".mediatype": []byte("application/vnd.oci.image.manifest.v1+json"),
+		manifestKeyPrefix + noMediaType:            []byte(`{"schemaVersion":2,"no":"mediatype"}`),
+		manifestKeyPrefix + tooBigDigest:           tooBig,
+		manifestKeyPrefix + mismatched:             manifest,
+	}
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		b, ok := objects[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(b)
+	}))
+	defer server.Close()
+
+	fetcher := newCachedManifestFetcher(nil)
+	testCases := []struct {
+		Name     string
+		Digest   string
+		Expected bool
+	}{
+		{Name: "mirrored manifest", Digest: good, Expected: true},
+		{Name: "missing manifest", Digest: sha256Digest([]byte("missing")), Expected: false},
+		{Name: "missing media type", Digest: noMediaType, Expected: false},
+		{Name: "manifest too large", Digest: tooBigDigest, Expected: false},
+		{Name: "content does not match digest", Digest: mismatched, Expected: false},
+		{Name: "mirrored sha512 manifest", Digest: good512, Expected: true},
+		{Name: "sha512 content does not match digest", Digest: "sha512:" + strings.Repeat("a", 128), Expected: false},
+		{Name: "unsupported digest algorithm", Digest: "md5:" + strings.Repeat("a", 32), Expected: false},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			m, ok := fetcher.FetchManifest(server.URL, tc.Digest)
+			if ok != tc.Expected {
+				t.Fatalf("expected FetchManifest to return %v, got %v", tc.Expected, ok)
+			}
+			if !ok {
+				return
+			}
+			if string(m.Raw) != string(manifest) {
+				t.Fatalf("unexpected manifest: %q", m.Raw)
+			}
+			if m.MediaType != "application/vnd.oci.image.manifest.v1+json" {
+				t.Fatalf("unexpected media type: %q", m.MediaType)
+			}
+		})
+	}
+
+	// cached manifests should not be fetched again, even from another bucket
+	before := requests.Load()
+	if _, ok := fetcher.FetchManifest("http://127.0.0.1:0", good); !ok {
+		t.Fatal("expected cached manifest to be found")
+	}
+	if after := requests.Load(); after != before {
+		t.Fatalf("expected no requests for cached manifest, got %d", after-before)
+	}
+
+	// and unreachable buckets should just be a miss
+	if _, ok := fetcher.FetchManifest("http://127.0.0.1:0", noMediaType); ok {
+		t.Fatal("expected unreachable bucket to be a miss")
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// pinnedTagCheckInterval is how often we check each pinned tag upstream
+const pinnedTagCheckInterval = 10 * time.Minute
+
+// reTag matches valid tags
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pulling-manifests
+var reTag = regexp.MustCompile("^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$")
+
+// manifestMediaTypes are the manifest media types we accept when resolving
+// tags upstream, these are the types a pinned tag could reasonably point to
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// tagPinner is used to serve some tags as a fixed digest
+type tagPinner interface {
+	// PinnedDigest returns the digest repository:tag is pinned to, or false,
+	// upstreamURL is the upstream manifest URL for the tag, which may be
+	// checked against the pinned digest
+	PinnedDigest(repository, tag, upstreamURL string) (string, bool)
+}
+
+// pinnedTags is the pinned tags file format, mapping repository to tag to digest
+// e.g. {"pause": {"3.9": "sha256:..."}}
+type pinnedTags map[string]map[string]string
+
+// parsePinnedTags parses and validates a pinned tags file
+func parsePinnedTags(b []byte) (*pinnedTags, error) {
+	pins := pinnedTags{}
+	if err := json.Unmarshal(b, &pins); err != nil {
+		return nil, err
+	}
+	for repository, tags := range pins {
+		if !reName.MatchString(repository) {
+			return nil, fmt.Errorf("invalid repository name: %q", repository)
+		}
+		for tag, digest := range tags {
+			if !reTag.MatchString(tag) {
+				return nil, fmt.Errorf("invalid tag for repository %q: %q", repository, tag)
+			}
+			if !reDigest.MatchString(digest) {
+				return nil, fmt.Errorf("invalid digest for %s:%s: %q", repository, tag, digest)
+			}
+		}
+	}
+	return &pins, nil
+}
+
+// filePinnedTags serves tags pinned in a watchedFile
+//
+// Pinned tags are checked against upstream in the background, at most once
+// per pinnedTagCheckInterval, logging an error if upstream disagrees.
+type filePinnedTags struct {
+	file   *watchedFile[pinnedTags]
+	client *http.Client
+	// lastChecked maps upstream URLs to the time.Time they were last checked
+	lastChecked sync.Map
+}
+
+func newFilePinnedTags(ctx context.Context, path string) *filePinnedTags {
+	return &filePinnedTags{
+		file: newWatchedFile(ctx, path, parsePinnedTags, watchedFileReloadInterval),
+		// NOTE: this client will still share http.DefaultTransport
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (p *filePinnedTags) PinnedDigest(repository, tag, upstreamURL string) (string, bool) {
+	pins := p.file.Load()
+	if pins == nil {
+		return "", false
+	}
+	digest, ok := (*pins)[repository][tag]
+	if !ok {
+		return "", false
+	}
+	now := time.Now()
+	last, loaded := p.lastChecked.LoadOrStore(upstreamURL, now)
+	if !loaded || (now.Sub(last.(time.Time)) > pinnedTagCheckInterval && p.lastChecked.CompareAndSwap(upstreamURL, last, now)) {
+		go p.checkUpstream(repository, tag, upstreamURL, digest)
+	}
+	return digest, true
+}
+
+// checkUpstream logs an error if upstreamURL does not resolve to digest
+func (p *filePinnedTags) checkUpstream(repository, tag, upstreamURL, digest string) {
+	upstreamDigest, err := resolveManifestDigest(p.client, upstreamURL)
+	if err != nil {
+		klog.V(2).InfoS("failed to check pinned tag upstream", "url", upstreamURL, "err", err)
+		return
+	}
+	if upstreamDigest != digest {
+		klog.ErrorS(nil, "upstream tag does not match pinned digest",
+			"repository", repository, "tag", tag, "pinned", digest, "upstream", upstreamDigest)
+	}
+}
+
+// errManifestNotFound is returned by resolveManifestDigest when upstream does
+// not have the manifest
+var errManifestNotFound = errors.New("manifest not found upstream")
+
+// resolveManifestDigest returns the digest the upstream manifest URL
+// currently resolves to
+func resolveManifestDigest(client *http.Client, upstreamURL string) (string, error) {
+	req, err := http.NewRequest(http.MethodHead, upstreamURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ","))
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", errManifestNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	upstreamDigest := resp.Header.Get("Docker-Content-Digest")
+	if upstreamDigest == "" {
+		return "", errors.New("upstream did not return a digest")
+	}
+	return upstreamDigest, nil
+}
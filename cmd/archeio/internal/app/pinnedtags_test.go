@@ -0,0 +1,216 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/registry.k8s.io/pkg/net/cloudcidrs"
+)
+
+const (
+	pinnedDigest = "sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e"
+	movedDigest  = "sha256:3b0998121425143be7164ea1555efbdf5b8a02ceedaa26e01910e7d017ff78dd"
+)
+
+func TestParsePinnedTagsInvalid(t *testing.T) {
+	for _, invalid := range []string{
+		`{"Pause": {"3.9": "` + pinnedDigest + `"}}`,
+		`{"pause": {".3.9": "` + pinnedDigest + `"}}`,
+		`{"pause": {"3.9": "latest"}}`,
+		`["pause"]`,
+	} {
+		if _, err := parsePinnedTags([]byte(invalid)); err == nil {
+			t.Fatalf("expected error for pinned tags: %s", invalid)
+		}
+	}
+}
+
+func TestMakeV2HandlerPinnedTags(t *testing.T) {
+	// upstream has moved the 3.9 tag, but not 3.10
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/pause/manifests/3.9":
+			w.Header().Set("Docker-Content-Digest", movedDigest)
+		case "/v2/pause/manifests/3.10":
+			w.Header().Set("Docker-Content-Digest", pinnedDigest)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	pins, err := parsePinnedTags([]byte(`{"pause": {"3.9": "` + pinnedDigest + `", "3.10": "` + pinnedDigest + `"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinner := &filePinnedTags{
+		file:   &watchedFile[pinnedTags]{},
+		client: upstream.Client(),
+	}
+	pinner.file.current.Store(pins)
+	handler := makeV2Handler(RegistryConfig{
+		UpstreamRegistryEndpoint: upstream.URL,
+	}, &fakeBlobsChecker{}, &fakeManifestFetcher{}, nil, nil, nil, pinner, nil, cloudcidrs.NewIPMapper(), nil)
+
+	testCases := []struct {
+		Name        string
+		Method      string
+		Path        string
+		ExpectedURL string
+	}{
+		{
+			Name:        "GET pinned tag",
+			Method:      "GET",
+			Path:        "/v2/pause/manifests/3.9",
+			ExpectedURL: "/v2/pause/manifests/" + pinnedDigest,
+		},
+		{
+			Name:        "HEAD pinned tag",
+			Method:      "HEAD",
+			Path:        "/v2/pause/manifests/3.10",
+			ExpectedURL: "/v2/pause/manifests/" + pinnedDigest,
+		},
+		{
+			Name:        "unpinned tag",
+			Method:      "GET",
+			Path:        "/v2/pause/manifests/latest",
+			ExpectedURL: upstream.URL + "/v2/pause/manifests/latest",
+		},
+		{
+			Name:        "manifest by digest",
+			Method:      "GET",
+			Path:        "/v2/pause/manifests/" + pinnedDigest,
+			ExpectedURL: upstream.URL + "/v2/pause/manifests/" + pinnedDigest,
+		},
+	}
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(tc.Method, "http://localhost:8080"+tc.Path, nil))
+		response := recorder.Result()
+		if response.StatusCode != http.StatusTemporaryRedirect {
+			t.Fatalf("%s: expected status: %v, but got status: %v", tc.Name, http.StatusTemporaryRedirect, response.StatusCode)
+		}
+		if location := response.Header.Get("Location"); location != tc.ExpectedURL {
+			t.Fatalf("%s: expected url: %q, but got: %q", tc.Name, tc.ExpectedURL, location)
+		}
+	}
+
+}
+
+func TestFilePinnedTagsChecks(t *testing.T) {
+	pins, err := parsePinnedTags([]byte(`{"pause": {"3.9": "` + pinnedDigest + `"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checked := make(chan struct{}, 2)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checked <- struct{}{}
+		w.Header().Set("Docker-Content-Digest", pinnedDigest)
+	}))
+	t.Cleanup(upstream.Close)
+	pinner := &filePinnedTags{
+		file:   &watchedFile[pinnedTags]{},
+		client: upstream.Client(),
+	}
+	if _, ok := pinner.PinnedDigest("pause", "3.9", upstream.URL); ok {
+		t.Fatal("expected nothing to be pinned before the file is loaded")
+	}
+	pinner.file.current.Store(pins)
+	waitForCheck := func() {
+		t.Helper()
+		select {
+		case <-checked:
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for the pinned tag to be checked upstream")
+		}
+	}
+	// the first request is checked, and requests after the interval
+	for _, lastChecked := range []time.Time{{}, time.Now().Add(-2 * pinnedTagCheckInterval)} {
+		if !lastChecked.IsZero() {
+			pinner.lastChecked.Store(upstream.URL, lastChecked)
+		}
+		if digest, ok := pinner.PinnedDigest("pause", "3.9", upstream.URL); !ok || digest != pinnedDigest {
+			t.Fatalf("expected pinned digest, got %q, %v", digest, ok)
+		}
+		waitForCheck()
+	}
+	// requests within the interval are not checked again
+	if _, ok := pinner.PinnedDigest("pause", "3.9", upstream.URL); !ok {
+		t.Fatal("expected the tag to be pinned")
+	}
+	select {
+	case <-checked:
+		t.Fatal("expected the pinned tag not to be checked again")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestResolveManifestDigest(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/pause/manifests/3.9":
+			w.Header().Set("Docker-Content-Digest", movedDigest)
+		case "/v2/pause/manifests/3.10":
+			w.Header().Set("Docker-Content-Digest", pinnedDigest)
+		case "/v2/pause/manifests/no-digest":
+		case "/v2/pause/manifests/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(upstream.Close)
+	pinner := &filePinnedTags{client: upstream.Client()}
+	testCases := []struct {
+		Name           string
+		UpstreamURL    string
+		ExpectedDigest string
+		ExpectedError  bool
+		NotFound       bool
+	}{
+		{Name: "moved tag", UpstreamURL: upstream.URL + "/v2/pause/manifests/3.9", ExpectedDigest: movedDigest},
+		{Name: "matching tag", UpstreamURL: upstream.URL + "/v2/pause/manifests/3.10", ExpectedDigest: pinnedDigest},
+		{Name: "missing tag", UpstreamURL: upstream.URL + "/v2/pause/manifests/missing", ExpectedError: true, NotFound: true},
+		{Name: "unavailable", UpstreamURL: upstream.URL + "/v2/pause/manifests/unavailable", ExpectedError: true},
+		{Name: "no digest", UpstreamURL: upstream.URL + "/v2/pause/manifests/no-digest", ExpectedError: true},
+		{Name: "invalid URL", UpstreamURL: "http://\x7f", ExpectedError: true},
+		{Name: "unreachable", UpstreamURL: "http://127.0.0.1:0/v2/pause/manifests/3.9", ExpectedError: true},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			digest, err := resolveManifestDigest(pinner.client, tc.UpstreamURL)
+			if (err != nil) != tc.ExpectedError {
+				t.Fatalf("expected error: %v, but got: %v", tc.ExpectedError, err)
+			}
+			if errors.Is(err, errManifestNotFound) != tc.NotFound {
+				t.Fatalf("expected not found: %v, but got: %v", tc.NotFound, err)
+			}
+			if digest != tc.ExpectedDigest {
+				t.Fatalf("expected digest %q, but got %q", tc.ExpectedDigest, digest)
+			}
+			// mismatches and failures are only logged
+			pinner.checkUpstream("pause", "3.9", tc.UpstreamURL, pinnedDigest)
+		})
+	}
+}
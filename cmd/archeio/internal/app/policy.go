@@ -0,0 +1,130 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// accessPolicy decides which content may be served
+type accessPolicy interface {
+	// Allowed returns false if the repository or digest is blocked,
+	// digest is "" for requests that are not by digest
+	Allowed(repository, digest string) bool
+	// DeniesDigests returns true if any digests are blocked, in which case
+	// manifests requested by tag are checked by the digest they resolve to
+	DeniesDigests() bool
+}
+
+// policyRules is the policy file format
+//
+// Repository patterns are matched with path.Match, so '*' does not match
+// '/', additionally a pattern ending in "/**" matches any repository under
+// that prefix.
+type policyRules struct {
+	// AllowRepositories, if not empty, are the only repositories served
+	AllowRepositories []string `json:"allowRepositories,omitempty"`
+	// DenyRepositories are never served, even if allowed
+	DenyRepositories []string `json:"denyRepositories,omitempty"`
+	// DenyDigests are manifest or blob digests that are never served
+	DenyDigests []string `json:"denyDigests,omitempty"`
+
+	deniedDigests map[string]bool
+}
+
+// parsePolicyRules parses and validates a policy file
+func parsePolicyRules(b []byte) (*policyRules, error) {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	rules := &policyRules{}
+	if err := decoder.Decode(rules); err != nil {
+		return nil, err
+	}
+	for _, patterns := range [][]string{rules.AllowRepositories, rules.DenyRepositories} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(strings.TrimSuffix(pattern, "/**"), ""); err != nil {
+				return nil, fmt.Errorf("invalid repository pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	rules.deniedDigests = make(map[string]bool, len(rules.DenyDigests))
+	for _, digest := range rules.DenyDigests {
+		if !reDigest.MatchString(digest) {
+			return nil, fmt.Errorf("invalid digest: %q", digest)
+		}
+		rules.deniedDigests[digest] = true
+	}
+	return rules, nil
+}
+
+func (p *policyRules) Allowed(repository, digest string) bool {
+	if digest != "" && p.deniedDigests[digest] {
+		return false
+	}
+	if matchesAnyRepository(p.DenyRepositories, repository) {
+		return false
+	}
+	return len(p.AllowRepositories) == 0 || matchesAnyRepository(p.AllowRepositories, repository)
+}
+
+func (p *policyRules) DeniesDigests() bool {
+	return len(p.deniedDigests) != 0
+}
+
+func matchesAnyRepository(patterns []string, repository string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if strings.HasPrefix(repository, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		// patterns were validated when parsing
+		if matched, _ := path.Match(pattern, repository); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// filePolicy is an accessPolicy loaded from a watchedFile, until the first
+// valid policy is loaded everything is denied, so that content meant to be
+// blocked is never served due to a missing or invalid file
+type filePolicy struct {
+	file *watchedFile[policyRules]
+}
+
+func newFilePolicy(ctx context.Context, path string) *filePolicy {
+	return &filePolicy{
+		file: newWatchedFile(ctx, path, parsePolicyRules, watchedFileReloadInterval),
+	}
+}
+
+func (p *filePolicy) Allowed(repository, digest string) bool {
+	rules := p.file.Load()
+	return rules != nil && rules.Allowed(repository, digest)
+}
+
+func (p *filePolicy) DeniesDigests() bool {
+	rules := p.file.Load()
+	return rules != nil && rules.DeniesDigests()
+}
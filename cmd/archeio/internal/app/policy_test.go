@@ -0,0 +1,236 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/registry.k8s.io/pkg/net/cloudcidrs"
+)
+
+const deniedDigest = "sha256:3b0998121425143be7164ea1555efbdf5b8a02ceedaa26e01910e7d017ff78dd"
+
+func TestPolicyRulesAllowed(t *testing.T) {
+	rules, err := parsePolicyRules([]byte(`{
+		"allowRepositories": ["pause", "sig-storage/*", "ingress-nginx/**"],
+		"denyRepositories": ["sig-storage/withdrawn"],
+		"denyDigests": ["` + deniedDigest + `"]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testCases := []struct {
+		Repository string
+		Digest     string
+		Expected   bool
+	}{
+		{Repository: "pause", Expected: true},
+		{Repository: "pause", Digest: deniedDigest, Expected: false},
+		{Repository: "sig-storage/csi-attacher", Expected: true},
+		{Repository: "sig-storage/csi/attacher", Expected: false},
+		{Repository: "sig-storage/withdrawn", Expected: false},
+		{Repository: "ingress-nginx/controller/chroot", Expected: true},
+		{Repository: "ingress-nginx", Expected: false},
+		{Repository: "etcd", Expected: false},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Repository+"@"+tc.Digest, func(t *testing.T) {
+			t.Parallel()
+			if allowed := rules.Allowed(tc.Repository, tc.Digest); allowed != tc.Expected {
+				t.Fatalf("expected allowed: %v, but got: %v", tc.Expected, allowed)
+			}
+		})
+	}
+}
+
+func TestParsePolicyRulesInvalid(t *testing.T) {
+	for _, invalid := range []string{
+		`{"denyRepositories": ["["]}`,
+		`{"denyDigests": ["not-a-digest"]}`,
+		`{"blockRepositories": ["pause"]}`,
+		`not json`,
+	} {
+		if _, err := parsePolicyRules([]byte(invalid)); err == nil {
+			t.Fatalf("expected error for policy: %s", invalid)
+		}
+	}
+}
+
+func TestFilePolicyReload(t *testing.T) {
+	policyPath := filepath.Join(t.TempDir(), "policy.json")
+	p := &filePolicy{file: &watchedFile[policyRules]{path: policyPath, parse: parsePolicyRules}}
+	// a missing file denies everything
+	p.file.reload()
+	if p.Allowed("pause", "") || p.DeniesDigests() {
+		t.Fatal("expected everything to be denied without a policy")
+	}
+	writePolicy := func(content string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(policyPath, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		// ensure the change is detected on filesystems with coarse timestamps
+		if err := os.Chtimes(policyPath, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		p.file.reload()
+	}
+	now := time.Now()
+	writePolicy(`{"denyRepositories": ["pause"]}`, now)
+	if p.Allowed("pause", "") {
+		t.Fatal("expected pause to be denied after loading the policy")
+	}
+	// invalid policies keep the previous policy
+	writePolicy(`{"denyRepositories": [`, now.Add(time.Second))
+	if p.Allowed("pause", "") {
+		t.Fatal("expected pause to still be denied after an invalid policy")
+	}
+	writePolicy(`{"denyRepositories": ["etcd"]}`, now.Add(2*time.Second))
+	if !p.Allowed("pause", "") || p.Allowed("etcd", "") {
+		t.Fatal("expected only etcd to be denied after reloading the policy")
+	}
+	writePolicy(`{"denyDigests": ["`+deniedDigest+`"]}`, now.Add(3*time.Second))
+	if !p.DeniesDigests() || p.Allowed("pause", deniedDigest) {
+		t.Fatal("expected the digest to be denied after reloading the policy")
+	}
+}
+
+func TestMakeV2HandlerPolicy(t *testing.T) {
+	// tags are resolved upstream, the latest tag points to a denied digest
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/k8s-artifacts-prod/images/pause/manifests/3.9":
+			w.Header().Set("Docker-Content-Digest", pinnedDigest)
+		case "/v2/k8s-artifacts-prod/images/pause/manifests/latest":
+			w.Header().Set("Docker-Content-Digest", deniedDigest)
+		case "/v2/k8s-artifacts-prod/images/pause/manifests/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(upstream.Close)
+	signatures := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Docker-Content-Digest", deniedDigest)
+	}))
+	t.Cleanup(signatures.Close)
+	registryConfig := RegistryConfig{
+		UpstreamRegistryEndpoint:  upstream.URL,
+		UpstreamRegistryPath:      "k8s-artifacts-prod/images",
+		SignatureUpstreamEndpoint: signatures.URL,
+	}
+	rules, err := parsePolicyRules([]byte(`{"denyRepositories": ["withdrawn"], "denyDigests": ["` + deniedDigest + `"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := makeV2Handler(registryConfig, &fakeBlobsChecker{}, &fakeManifestFetcher{}, nil, nil, rules, nil, nil, cloudcidrs.NewIPMapper(), nil)
+	testCases := []struct {
+		Name           string
+		Path           string
+		ExpectedStatus int
+		ExpectedCode   errorCode
+	}{
+		{
+			Name:           "allowed manifest",
+			Path:           "/v2/pause/manifests/3.9",
+			ExpectedStatus: http.StatusTemporaryRedirect,
+		},
+		{
+			Name:           "denied repository",
+			Path:           "/v2/withdrawn/manifests/latest",
+			ExpectedStatus: http.StatusForbidden,
+			ExpectedCode:   errorCodeDenied,
+		},
+		{
+			Name:           "denied repository tags list",
+			Path:           "/v2/withdrawn/tags/list",
+			ExpectedStatus: http.StatusForbidden,
+			ExpectedCode:   errorCodeDenied,
+		},
+		{
+			Name:           "missing tag",
+			Path:           "/v2/pause/manifests/missing",
+			ExpectedStatus: http.StatusTemporaryRedirect,
+		},
+		{
+			Name:           "denied tag",
+			Path:           "/v2/pause/manifests/latest",
+			ExpectedStatus: http.StatusForbidden,
+			ExpectedCode:   errorCodeDenied,
+		},
+		{
+			Name:           "denied signature tag",
+			Path:           "/v2/pause/manifests/sha256-" + strings.TrimPrefix(pinnedDigest, "sha256:") + ".sig",
+			ExpectedStatus: http.StatusForbidden,
+			ExpectedCode:   errorCodeDenied,
+		},
+		{
+			Name:           "unresolvable tag",
+			Path:           "/v2/pause/manifests/unavailable",
+			ExpectedStatus: http.StatusBadGateway,
+			ExpectedCode:   errorCodeUnknown,
+		},
+		{
+			Name:           "denied manifest digest",
+			Path:           "/v2/pause/manifests/" + deniedDigest,
+			ExpectedStatus: http.StatusForbidden,
+			ExpectedCode:   errorCodeDenied,
+		},
+		{
+			Name:           "denied blob digest",
+			Path:           "/v2/pause/blobs/" + deniedDigest,
+			ExpectedStatus: http.StatusForbidden,
+			ExpectedCode:   errorCodeDenied,
+		},
+		{
+			Name:           "invalid repository name",
+			Path:           "/v2/Pause/manifests/3.9",
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedCode:   errorCodeNameInvalid,
+		},
+		{
+			Name:           "invalid repository name component",
+			Path:           "/v2/pause/../etcd/manifests/3.9",
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedCode:   errorCodeNameInvalid,
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			recorder := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+			r.URL.Path = tc.Path
+			handler(recorder, r)
+			if tc.ExpectedCode == "" {
+				if recorder.Code != tc.ExpectedStatus {
+					t.Fatalf("expected status: %v, but got status: %v", tc.ExpectedStatus, recorder.Code)
+				}
+				return
+			}
+			checkErrorResponse(t, recorder, tc.ExpectedStatus, tc.ExpectedCode)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// proxiedRequestHeaders are the client request headers we pass upstream
+var proxiedRequestHeaders = []string{"Accept", "Range", "If-None-Match", "If-Range"}
+
+// proxiedResponseHeaders are the upstream response headers we pass to clients
+var proxiedResponseHeaders = []string{
+	"Accept-Ranges",
+	"Content-Length",
+	"Content-Range",
+	"Content-Type",
+	"Docker-Content-Digest",
+	"Docker-Distribution-Api-Version",
+	"Etag",
+}
+
+// upstreamProxy is used to serve requests from upstream instead of redirecting
+type upstreamProxy interface {
+	// Proxy should write the response for r from upstreamURL, digest is the
+	// requested digest for requests of kind "blobs" or "manifests", else ""
+	Proxy(w http.ResponseWriter, r *http.Request, upstreamURL, kind, digest string)
+}
+
+// cachingProxy fetches registry responses from upstream and streams them to
+// clients, for clients that cannot follow redirects to the upstream registry
+// or mirror buckets, e.g. due to egress restrictions
+//
+// Content addressed responses are also written to cache if set, and served
+// from there when possible.
+type cachingProxy struct {
+	client *http.Client
+	cache  *diskCache
+	// forwardAuthorization passes client Authorization headers upstream
+	forwardAuthorization bool
+}
+
+func newCachingProxy(cache *diskCache) *cachingProxy {
+	return &cachingProxy{
+		// NOTE: this client will still share http.DefaultTransport
+		// there is no timeout as we may be streaming large blobs, instead
+		// requests are cancelled with the client request
+		client: &http.Client{},
+		cache:  cache,
+	}
+}
+
+func (p *cachingProxy) Proxy(w http.ResponseWriter, r *http.Request, upstreamURL, kind, digest string) {
+	key, cacheable := "", false
+	if p.cache != nil && digest != "" {
+		key, cacheable = cacheKey(kind, digest)
+	}
+	if cacheable {
+		if f, mediaType, ok := p.cache.Open(key); ok {
+			defer f.Close()
+			klog.V(2).InfoS("serving from cache", "path", r.URL.Path)
+			w.Header().Set("Content-Type", mediaType)
+			w.Header().Set("Docker-Content-Digest", digest)
+			// content addressed, so the digest is a strong ETag
+			w.Header().Set("Etag", `"`+digest+`"`)
+			// ServeContent handles HEAD, Range and Content-Length for us
+			http.ServeContent(w, r, "", time.Time{}, f)
+			return
+		}
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, upstreamURL, nil)
+	if err != nil {
+		klog.ErrorS(err, "failed to create upstream request", "url", upstreamURL)
+		writeError(w, http.StatusInternalServerError, errorCodeUnknown, "failed to create upstream request")
+		return
+	}
+	for _, h := range proxiedRequestHeaders {
+		if v := r.Header.Values(h); len(v) != 0 {
+			req.Header[h] = v
+		}
+	}
+	if p.forwardAuthorization {
+		if v := r.Header.Values("Authorization"); len(v) != 0 {
+			req.Header["Authorization"] = v
+		}
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		klog.ErrorS(err, "failed to fetch from upstream", "url", upstreamURL)
+		writeError(w, http.StatusBadGateway, errorCodeUnknown, "failed to fetch from upstream registry")
+		return
+	}
+	defer resp.Body.Close()
+	for _, h := range proxiedResponseHeaders {
+		if v := resp.Header.Values(h); len(v) != 0 {
+			w.Header()[h] = v
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	// tee complete, successful responses into the cache while streaming them
+	var body io.Reader = resp.Body
+	var cacheWriter *diskCacheWriter
+	if cacheable && resp.StatusCode == http.StatusOK && r.Header.Get("Range") == "" && resp.ContentLength <= p.cache.maxBytes {
+		cacheWriter, err = p.cache.Create(key)
+		if err != nil {
+			klog.ErrorS(err, "failed to create cache entry", "key", key)
+		} else {
+			body = io.TeeReader(resp.Body, cacheWriter)
+		}
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		klog.V(2).InfoS("failed to stream upstream response", "url", upstreamURL, "err", err)
+		if cacheWriter != nil {
+			cacheWriter.Abort()
+		}
+		return
+	}
+	if cacheWriter != nil {
+		if err := cacheWriter.Commit(resp.Header.Get("Content-Type")); err != nil {
+			klog.ErrorS(err, "failed to cache upstream response", "key", key)
+		}
+	}
+}
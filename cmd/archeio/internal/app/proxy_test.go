@@ -0,0 +1,178 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/registry.k8s.io/pkg/net/cloudcidrs"
+)
+
+func TestCachingProxy(t *testing.T) {
+	blob := []byte("some layer content")
+	blobDigest := sha256Digest(blob)
+	manifest := []byte(`{"schemaVersion":2}`)
+	manifestDigest := sha256Digest(manifest)
+	corruptDigest := sha256Digest([]byte("something else"))
+	const manifestType = "application/vnd.oci.image.manifest.v1+json"
+
+	var requests atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/v2/images/pause/blobs/" + blobDigest:
+			w.Header().Set("Docker-Content-Digest", blobDigest)
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(blob))
+		case "/v2/images/pause/blobs/" + corruptDigest:
+			_, _ = w.Write(blob)
+		case "/v2/images/pause/manifests/" + manifestDigest, "/v2/images/pause/manifests/3.9":
+			if r.Header.Get("Accept") == "" {
+				http.Error(w, "missing Accept", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", manifestType)
+			w.Header().Set("Docker-Content-Digest", manifestDigest)
+			_, _ = w.Write(manifest)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	cache, err := newDiskCache(t.TempDir(), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := makeV2Handler(RegistryConfig{
+		UpstreamRegistryEndpoint: upstream.URL,
+		UpstreamRegistryPath:     "images",
+	}, &fakeBlobsChecker{}, &fakeManifestFetcher{}, nil, newCachingProxy(cache), nil, nil, nil, cloudcidrs.NewIPMapper(), nil)
+
+	testCases := []struct {
+		Name             string
+		Method           string
+		Path             string
+		Headers          map[string]string
+		ExpectedStatus   int
+		ExpectedBody     string
+		ExpectedType     string
+		ExpectedUpstream bool
+	}{
+		{Name: "GET blob", Method: "GET", Path: "/v2/pause/blobs/" + blobDigest, ExpectedStatus: http.StatusOK, ExpectedBody: string(blob), ExpectedUpstream: true},
+		{Name: "GET cached blob", Method: "GET", Path: "/v2/pause/blobs/" + blobDigest, ExpectedStatus: http.StatusOK, ExpectedBody: string(blob)},
+		{Name: "HEAD cached blob", Method: "HEAD", Path: "/v2/pause/blobs/" + blobDigest, ExpectedStatus: http.StatusOK},
+		{Name: "GET cached blob range", Method: "GET", Path: "/v2/pause/blobs/" + blobDigest, Headers: map[string]string{"Range": "bytes=5-9"}, ExpectedStatus: http.StatusPartialContent, ExpectedBody: "layer"},
+		{Name: "GET corrupt blob", Method: "GET", Path: "/v2/pause/blobs/" + corruptDigest, ExpectedStatus: http.StatusOK, ExpectedBody: string(blob), ExpectedUpstream: true},
+		{Name: "GET corrupt blob is not cached", Method: "GET", Path: "/v2/pause/blobs/" + corruptDigest, ExpectedStatus: http.StatusOK, ExpectedBody: string(blob), ExpectedUpstream: true},
+		{Name: "GET missing blob", Method: "GET", Path: "/v2/pause/blobs/" + sha256Digest([]byte("missing")), ExpectedStatus: http.StatusNotFound, ExpectedUpstream: true},
+		{Name: "GET manifest by digest", Method: "GET", Path: "/v2/pause/manifests/" + manifestDigest, Headers: map[string]string{"Accept": manifestType}, ExpectedStatus: http.StatusOK, ExpectedBody: string(manifest), ExpectedType: manifestType, ExpectedUpstream: true},
+		{Name: "GET cached manifest by digest", Method: "GET", Path: "/v2/pause/manifests/" + manifestDigest, Headers: map[string]string{"Accept": manifestType}, ExpectedStatus: http.StatusOK, ExpectedBody: string(manifest), ExpectedType: manifestType},
+		{Name: "GET manifest by tag", Method: "GET", Path: "/v2/pause/manifests/3.9", Headers: map[string]string{"Accept": manifestType}, ExpectedStatus: http.StatusOK, ExpectedBody: string(manifest), ExpectedType: manifestType, ExpectedUpstream: true},
+		{Name: "GET manifest by tag is not cached", Method: "GET", Path: "/v2/pause/manifests/3.9", Headers: map[string]string{"Accept": manifestType}, ExpectedStatus: http.StatusOK, ExpectedBody: string(manifest), ExpectedType: manifestType, ExpectedUpstream: true},
+		{Name: "/v2/ is not proxied", Method: "GET", Path: "/v2/", ExpectedStatus: http.StatusOK},
+	}
+	// these are sequential, as later requests depend on earlier requests
+	for _, tc := range testCases {
+		before := requests.Load()
+		r := httptest.NewRequest(tc.Method, "http://localhost:8080"+tc.Path, nil)
+		for k, v := range tc.Headers {
+			r.Header.Set(k, v)
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, r)
+		response := recorder.Result()
+		if response.StatusCode != tc.ExpectedStatus {
+			t.Fatalf("%s: expected status %d but got %d", tc.Name, tc.ExpectedStatus, response.StatusCode)
+		}
+		if tc.ExpectedBody != "" && recorder.Body.String() != tc.ExpectedBody {
+			t.Fatalf("%s: expected body %q but got %q", tc.Name, tc.ExpectedBody, recorder.Body.String())
+		}
+		if tc.ExpectedType != "" && response.Header.Get("Content-Type") != tc.ExpectedType {
+			t.Fatalf("%s: expected Content-Type %q but got %q", tc.Name, tc.ExpectedType, response.Header.Get("Content-Type"))
+		}
+		if upstreamed := requests.Load() != before; upstreamed != tc.ExpectedUpstream {
+			t.Fatalf("%s: expected request to upstream to be %v", tc.Name, tc.ExpectedUpstream)
+		}
+	}
+}
+
+func TestCachingProxyFailures(t *testing.T) {
+	blob := []byte("some layer content")
+	blobDigest := sha256Digest(blob)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/truncated" {
+			// the connection is closed before the promised content
+			w.Header().Set("Content-Length", "100")
+			_, _ = w.Write(blob)
+			return
+		}
+		_, _ = w.Write(blob)
+	}))
+	t.Cleanup(upstream.Close)
+
+	testCases := []struct {
+		Name           string
+		Method         string
+		UpstreamURL    string
+		ExpectedStatus int
+		ExpectedBody   string
+		// BreakCache prevents creating cache entries
+		BreakCache bool
+	}{
+		{Name: "invalid upstream URL", Method: "GET", UpstreamURL: "http://\x7f", ExpectedStatus: http.StatusInternalServerError},
+		{Name: "HEAD blob", Method: "HEAD", UpstreamURL: upstream.URL, ExpectedStatus: http.StatusOK},
+		{Name: "truncated blob", Method: "GET", UpstreamURL: upstream.URL + "/truncated", ExpectedStatus: http.StatusOK},
+		{Name: "creating the cache entry fails", Method: "GET", UpstreamURL: upstream.URL, ExpectedStatus: http.StatusOK, ExpectedBody: string(blob), BreakCache: true},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			cache, err := newDiskCache(t.TempDir(), 1024)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.BreakCache {
+				if err := os.RemoveAll(cache.tmpDir()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			recorder := httptest.NewRecorder()
+			r := httptest.NewRequest(tc.Method, "http://localhost:8080/v2/pause/blobs/"+blobDigest, nil)
+			newCachingProxy(cache).Proxy(recorder, r, tc.UpstreamURL, "blobs", blobDigest)
+			response := recorder.Result()
+			if response.StatusCode != tc.ExpectedStatus {
+				t.Fatalf("expected status %d but got %d", tc.ExpectedStatus, response.StatusCode)
+			}
+			if tc.ExpectedBody != "" && recorder.Body.String() != tc.ExpectedBody {
+				t.Fatalf("expected body %q but got %q", tc.ExpectedBody, recorder.Body.String())
+			}
+			if cache.lru.Len() != 0 {
+				t.Fatalf("expected nothing to be cached, got %d entries", cache.lru.Len())
+			}
+			if entries, err := os.ReadDir(cache.tmpDir()); err == nil && len(entries) != 0 {
+				t.Fatalf("expected incomplete entries to be removed, got %d", len(entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// UpstreamRoute routes requests for repositories under Prefix to a different
+// upstream registry than the default, replacing Prefix with Path
+//
+// This allows serving multiple registries from one front door, and keeping
+// image names stable when a repository moves between registries.
+type UpstreamRoute struct {
+	// Prefix is a repository name prefix, matched on whole path segments,
+	// so "sig-storage" matches "sig-storage/csi" but not "sig-storagex"
+	Prefix string
+	// Endpoint is the upstream registry, e.g. "https://us-docker.pkg.dev"
+	Endpoint string
+	// Path replaces Prefix in the upstream repository name
+	Path string
+}
+
+// reRepository captures the repository name in registry API requests
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#endpoints
+// <name> may contain '/' so we match the last API component
+var reRepository = regexp.MustCompile("^/v2/(.+)/(?:blobs|manifests|tags|referrers)/[^/]+$")
+
+// reName matches valid repository names
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pulling-manifests
+var reName = regexp.MustCompile(`^[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*)*$`)
+
+// ParseUpstreamRoutes parses comma separated prefix=upstream pairs, e.g.
+// "sig-storage=https://us-docker.pkg.dev/k8s-sig-storage/images"
+//
+// A trailing "/*" on the prefix is allowed and ignored.
+func ParseUpstreamRoutes(s string) ([]UpstreamRoute, error) {
+	routes := []UpstreamRoute{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		prefix, upstream, ok := strings.Cut(pair, "=")
+		prefix = strings.Trim(strings.TrimSuffix(prefix, "/*"), "/")
+		if !ok || prefix == "" {
+			return nil, fmt.Errorf("invalid upstream route, expected prefix=upstream: %q", pair)
+		}
+		u, err := url.Parse(upstream)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid upstream for route %q: %q", prefix, upstream)
+		}
+		routes = append(routes, UpstreamRoute{
+			Prefix:   prefix,
+			Endpoint: u.Scheme + "://" + u.Host,
+			Path:     strings.Trim(u.Path, "/"),
+		})
+	}
+	return routes, nil
+}
+
+// upstreamRouteFor returns the route with the longest prefix matching the
+// repository in originalPath, and the rest of the path after the prefix,
+// or nil if no route matches
+func upstreamRouteFor(rc RegistryConfig, originalPath string) (*UpstreamRoute, string) {
+	matches := reRepository.FindStringSubmatch(originalPath)
+	if len(matches) != 2 {
+		return nil, ""
+	}
+	repository := matches[1]
+	var best *UpstreamRoute
+	for i := range rc.UpstreamRoutes {
+		route := &rc.UpstreamRoutes[i]
+		if repository != route.Prefix && !strings.HasPrefix(repository, route.Prefix+"/") {
+			continue
+		}
+		if best == nil || len(route.Prefix) > len(best.Prefix) {
+			best = route
+		}
+	}
+	if best == nil {
+		return nil, ""
+	}
+	return best, strings.TrimPrefix(originalPath, "/v2/"+best.Prefix)
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"k8s.io/registry.k8s.io/pkg/net/cloudcidrs"
+)
+
+func TestParseUpstreamRoutes(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		Value          string
+		ExpectedRoutes []UpstreamRoute
+		ExpectError    bool
+	}{
+		{
+			Name:           "empty",
+			Value:          "",
+			ExpectedRoutes: []UpstreamRoute{},
+		},
+		{
+			Name:  "multiple routes",
+			Value: "sig-storage=https://us-docker.pkg.dev/k8s-sig-storage/images, ingress-nginx/*=https://europe-docker.pkg.dev/",
+			ExpectedRoutes: []UpstreamRoute{
+				{Prefix: "sig-storage", Endpoint: "https://us-docker.pkg.dev", Path: "k8s-sig-storage/images"},
+				{Prefix: "ingress-nginx", Endpoint: "https://europe-docker.pkg.dev", Path: ""},
+			},
+		},
+		{
+			Name:        "missing upstream",
+			Value:       "sig-storage",
+			ExpectError: true,
+		},
+		{
+			Name:        "missing prefix",
+			Value:       "/*=https://us-docker.pkg.dev",
+			ExpectError: true,
+		},
+		{
+			Name:        "upstream without scheme",
+			Value:       "sig-storage=us-docker.pkg.dev/k8s-sig-storage",
+			ExpectError: true,
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			routes, err := ParseUpstreamRoutes(tc.Value)
+			if tc.ExpectError {
+				if err == nil {
+					t.Fatalf("expected error but got routes: %v", routes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(routes, tc.ExpectedRoutes) {
+				t.Fatalf("expected routes: %v, but got: %v", tc.ExpectedRoutes, routes)
+			}
+		})
+	}
+}
+
+func TestMakeV2HandlerUpstreamRoutes(t *testing.T) {
+	registryConfig := RegistryConfig{
+		UpstreamRegistryEndpoint:  "https://us-central1-docker.pkg.dev",
+		UpstreamRegistryPath:      "k8s-artifacts-prod/images",
+		SignatureUpstreamEndpoint: "https://us-docker.pkg.dev",
+		UpstreamRoutes: []UpstreamRoute{
+			{Prefix: "sig-storage", Endpoint: "https://us-docker.pkg.dev", Path: "k8s-sig-storage/images"},
+			{Prefix: "sig-storage/legacy", Endpoint: "https://k8s.gcr.io", Path: ""},
+			{Prefix: "ingress-nginx", Endpoint: "https://europe-docker.pkg.dev", Path: "ingress/images/ingress-nginx"},
+		},
+	}
+	handler := makeV2Handler(registryConfig, &fakeBlobsChecker{}, &fakeManifestFetcher{}, nil, nil, nil, nil, nil, cloudcidrs.NewIPMapper(), nil)
+	testCases := []struct {
+		Name        string
+		Path        string
+		ExpectedURL string
+	}{
+		{
+			Name:        "routed manifest",
+			Path:        "/v2/sig-storage/csi-attacher/manifests/v4.0.0",
+			ExpectedURL: "https://us-docker.pkg.dev/v2/k8s-sig-storage/images/csi-attacher/manifests/v4.0.0",
+		},
+		{
+			Name:        "longest prefix wins",
+			Path:        "/v2/sig-storage/legacy/nfs/manifests/v1",
+			ExpectedURL: "https://k8s.gcr.io/v2/nfs/manifests/v1",
+		},
+		{
+			Name:        "routed blob",
+			Path:        "/v2/ingress-nginx/controller/blobs/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+			ExpectedURL: "https://europe-docker.pkg.dev/v2/ingress/images/ingress-nginx/controller/blobs/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+		},
+		{
+			Name:        "routed tags list",
+			Path:        "/v2/ingress-nginx/controller/tags/list",
+			ExpectedURL: "https://europe-docker.pkg.dev/v2/ingress/images/ingress-nginx/controller/tags/list",
+		},
+		{
+			Name:        "routed repository is the prefix",
+			Path:        "/v2/sig-storage/manifests/latest",
+			ExpectedURL: "https://us-docker.pkg.dev/v2/k8s-sig-storage/images/manifests/latest",
+		},
+		{
+			Name:        "prefix only matches whole path segments",
+			Path:        "/v2/sig-storagex/manifests/latest",
+			ExpectedURL: "https://us-central1-docker.pkg.dev/v2/k8s-artifacts-prod/images/sig-storagex/manifests/latest",
+		},
+		{
+			Name:        "not a repository path",
+			Path:        "/v2/sig-storage",
+			ExpectedURL: "https://us-central1-docker.pkg.dev/v2/k8s-artifacts-prod/images/sig-storage",
+		},
+		{
+			Name:        "unrouted manifest",
+			Path:        "/v2/pause/manifests/3.9",
+			ExpectedURL: "https://us-central1-docker.pkg.dev/v2/k8s-artifacts-prod/images/pause/manifests/3.9",
+		},
+		{
+			Name:        "routed signature skips signature upstream",
+			Path:        "/v2/sig-storage/csi-attacher/manifests/sha256-da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e.sig",
+			ExpectedURL: "https://us-docker.pkg.dev/v2/k8s-sig-storage/images/csi-attacher/manifests/sha256-da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e.sig",
+		},
+		{
+			Name:        "unrouted signature uses signature upstream",
+			Path:        "/v2/pause/manifests/sha256-da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e.sig",
+			ExpectedURL: "https://us-docker.pkg.dev/v2/k8s-artifacts-prod/images/pause/manifests/sha256-da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e.sig",
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			recorder := httptest.NewRecorder()
+			handler(recorder, httptest.NewRequest("GET", "http://localhost:8080"+tc.Path, nil))
+			response := recorder.Result()
+			if response.StatusCode != http.StatusTemporaryRedirect {
+				t.Fatalf("expected status: %v, but got status: %v", http.StatusTemporaryRedirect, response.StatusCode)
+			}
+			if location := response.Header.Get("Location"); location != tc.ExpectedURL {
+				t.Fatalf("expected url: %q, but got: %q", tc.ExpectedURL, location)
+			}
+		})
+	}
+}
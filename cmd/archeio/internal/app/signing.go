@@ -0,0 +1,315 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go/encoding/httpbinding"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"k8s.io/klog/v2"
+)
+
+// urlSigner is used to sign bucket URLs, for private mirror buckets
+type urlSigner interface {
+	// SignURL returns objectURL signed for method, or objectURL unchanged
+	// if it is not a bucket URL that can be signed
+	SignURL(method, objectURL string) (string, error)
+}
+
+// reS3Host matches S3 virtual hosted bucket hosts, capturing the region,
+// e.g. prod-registry-k8s-io-us-east-2.s3.dualstack.us-east-2.amazonaws.com
+var reS3Host = regexp.MustCompile(`\.s3(?:[.-]dualstack)?(?:[.-]([a-z0-9-]+))?\.amazonaws\.com$`)
+
+// gcsHost is the host for path style GCS object URLs
+const gcsHost = "storage.googleapis.com"
+
+// bucketURLSigner signs S3 and GCS object URLs, with credentials from the
+// standard AWS SDK and Google application default credentials chains
+//
+// Signed URLs are reused for half of expiry, as signing may call the IAM
+// signBlob API, which is too slow and rate limited to call for every request.
+type bucketURLSigner struct {
+	expiry time.Duration
+	s3     *s3URLSigner
+	gcs    *gcsURLSigner
+	// signed maps method and object URL to a signedURL
+	signed sync.Map
+	// sweptAt is the UnixNano time signed was last swept of expired entries
+	sweptAt atomic.Int64
+}
+
+// signedURL is a signed URL cached by bucketURLSigner
+type signedURL struct {
+	url string
+	// reuseUntil is when the URL is no longer valid for long enough to reuse
+	reuseUntil time.Time
+}
+
+// newBucketURLSigner returns a signer for URLs that expire after expiry,
+// buckets without available credentials are not signed
+func newBucketURLSigner(ctx context.Context, expiry time.Duration) *bucketURLSigner {
+	b := &bucketURLSigner{expiry: expiry}
+	if cfg, err := config.LoadDefaultConfig(ctx); err != nil {
+		klog.ErrorS(err, "failed to load AWS config, S3 URLs will not be signed")
+	} else {
+		b.s3 = &s3URLSigner{credentials: cfg.Credentials, signer: v4.NewSigner()}
+	}
+	if gcs, err := newGCSURLSigner(ctx); err != nil {
+		klog.ErrorS(err, "failed to load Google credentials, GCS URLs will not be signed")
+	} else {
+		b.gcs = gcs
+	}
+	return b
+}
+
+func (b *bucketURLSigner) SignURL(method, objectURL string) (string, error) {
+	key := method + " " + objectURL
+	now := time.Now()
+	if cached, ok := b.signed.Load(key); ok && now.Before(cached.(signedURL).reuseUntil) {
+		return cached.(signedURL).url, nil
+	}
+	signed, err := b.sign(method, objectURL, now)
+	if err != nil {
+		return "", err
+	}
+	b.signed.Store(key, signedURL{url: signed, reuseUntil: now.Add(b.expiry / 2)})
+	b.sweep(now)
+	return signed, nil
+}
+
+// sweep deletes signed URLs that can no longer be reused, at most once per
+// half of expiry, so that URLs that are not requested again are not kept
+func (b *bucketURLSigner) sweep(now time.Time) {
+	last := b.sweptAt.Load()
+	if now.Sub(time.Unix(0, last)) < b.expiry/2 || !b.sweptAt.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	b.signed.Range(func(key, value any) bool {
+		if !now.Before(value.(signedURL).reuseUntil) {
+			b.signed.CompareAndDelete(key, value)
+		}
+		return true
+	})
+}
+
+// sign returns objectURL signed for method at now, or objectURL unchanged
+// if it is not a bucket URL that can be signed
+func (b *bucketURLSigner) sign(method, objectURL string, now time.Time) (string, error) {
+	u, err := url.Parse(objectURL)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if matches := reS3Host.FindStringSubmatch(u.Host); matches != nil && b.s3 != nil {
+		region := matches[1]
+		if region == "" {
+			// legacy global endpoint
+			region = "us-east-1"
+		}
+		return b.s3.sign(ctx, method, u, region, b.expiry, now)
+	}
+	if u.Host == gcsHost && b.gcs != nil {
+		return b.gcs.sign(ctx, method, u, b.expiry, now)
+	}
+	return objectURL, nil
+}
+
+// s3URLSigner creates SigV4 query authenticated URLs
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html
+type s3URLSigner struct {
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+}
+
+func (s *s3URLSigner) sign(ctx context.Context, method string, u *url.URL, region string, expiry time.Duration, now time.Time) (string, error) {
+	creds, err := s.credentials.Retrieve(ctx)
+	if err != nil {
+		return "", err
+	}
+	signed := *u
+	// S3 expects each path byte to be escaped exactly once, including ':' in digests
+	signed.RawPath = httpbinding.EscapePath(u.Path, false)
+	query := signed.Query()
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expiry.Seconds())))
+	signed.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, signed.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	signedURL, _, err := s.signer.PresignHTTP(ctx, creds, req, "UNSIGNED-PAYLOAD", "s3", region, now, func(o *v4.SignerOptions) {
+		o.DisableURIPathEscaping = true
+	})
+	return signedURL, err
+}
+
+// gcsURLSigner creates V4 signed URLs
+// https://cloud.google.com/storage/docs/access-control/signing-urls-manually
+type gcsURLSigner struct {
+	email     string
+	signBytes func(ctx context.Context, b []byte) ([]byte, error)
+}
+
+// newGCSURLSigner returns a signer using the private key of service account
+// credentials if available, or else the IAM signBlob API on GCP
+func newGCSURLSigner(ctx context.Context) (*gcsURLSigner, error) {
+	creds, err := google.FindDefaultCredentials(ctx, "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return nil, err
+	}
+	if jwtConfig, err := google.JWTConfigFromJSON(creds.JSON); err == nil {
+		key, err := parseRSAPrivateKey(jwtConfig.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return &gcsURLSigner{
+			email: jwtConfig.Email,
+			signBytes: func(_ context.Context, b []byte) ([]byte, error) {
+				sum := sha256.Sum256(b)
+				return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+			},
+		}, nil
+	}
+	// unlike the package level functions, the client does not memoize OnGCE
+	metadataClient := metadata.NewClient(nil)
+	if !metadataClient.OnGCEWithContext(ctx) {
+		return nil, errors.New("credentials have no private key to sign with, and we are not on GCP")
+	}
+	email, err := metadataClient.EmailWithContext(ctx, "default")
+	if err != nil {
+		return nil, err
+	}
+	client := oauth2.NewClient(ctx, creds.TokenSource)
+	return &gcsURLSigner{
+		email: email,
+		signBytes: func(ctx context.Context, b []byte) ([]byte, error) {
+			return iamSignBlob(ctx, client, iamCredentialsEndpoint, email, b)
+		},
+	}, nil
+}
+
+func parseRSAPrivateKey(pemKey []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, errors.New("invalid private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// iamCredentialsEndpoint is the IAM Service Account Credentials API endpoint
+const iamCredentialsEndpoint = "https://iamcredentials.googleapis.com"
+
+// iamSignBlob signs b as the service account email, with the API at endpoint
+// https://cloud.google.com/iam/docs/reference/credentials/rest/v1/projects.serviceAccounts/signBlob
+func iamSignBlob(ctx context.Context, client *http.Client, endpoint, email string, b []byte) ([]byte, error) {
+	// marshalling a map of strings cannot fail
+	body, _ := json.Marshal(map[string]string{"payload": base64.StdEncoding.EncodeToString(b)})
+	signURL := endpoint + "/v1/projects/-/serviceAccounts/" + url.PathEscape(email) + ":signBlob"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, signURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("signBlob returned status %d", resp.StatusCode)
+	}
+	var signed struct {
+		SignedBlob string `json:"signedBlob"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&signed); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(signed.SignedBlob)
+}
+
+func (g *gcsURLSigner) sign(ctx context.Context, method string, u *url.URL, expiry time.Duration, now time.Time) (string, error) {
+	now = now.UTC()
+	datestamp := now.Format("20060102")
+	timestamp := now.Format("20060102T150405Z")
+	scope := datestamp + "/auto/storage/goog4_request"
+	query := u.Query()
+	query.Set("X-Goog-Algorithm", "GOOG4-RSA-SHA256")
+	query.Set("X-Goog-Credential", g.email+"/"+scope)
+	query.Set("X-Goog-Date", timestamp)
+	query.Set("X-Goog-Expires", strconv.Itoa(int(expiry.Seconds())))
+	query.Set("X-Goog-SignedHeaders", "host")
+	// Encode sorts by key, but encodes spaces as '+' rather than "%20"
+	canonicalQuery := strings.ReplaceAll(query.Encode(), "+", "%20")
+	canonicalPath := httpbinding.EscapePath(u.Path, false)
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalPath,
+		canonicalQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"GOOG4-RSA-SHA256",
+		timestamp,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+	signature, err := g.signBytes(ctx, []byte(stringToSign))
+	if err != nil {
+		return "", err
+	}
+	return u.Scheme + "://" + u.Host + canonicalPath + "?" + canonicalQuery + "&X-Goog-Signature=" + hex.EncodeToString(signature), nil
+}
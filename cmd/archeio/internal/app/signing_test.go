@@ -0,0 +1,635 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"golang.org/x/oauth2"
+
+	"k8s.io/registry.k8s.io/pkg/net/cloudcidrs"
+)
+
+const testBlobPath = "/containers/images/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e"
+
+// fakeURLSigner marks URLs as signed for a method, or fails
+type fakeURLSigner struct {
+	err error
+}
+
+func (f *fakeURLSigner) SignURL(method, objectURL string) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	return objectURL + "?signed=" + method, nil
+}
+
+func testSigningTime() time.Time {
+	return time.Date(2026, time.March, 1, 12, 30, 0, 0, time.UTC)
+}
+
+func TestS3URLSigner(t *testing.T) {
+	s := &s3URLSigner{
+		credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}, nil
+		}),
+		signer: v4.NewSigner(),
+	}
+	u, _ := url.Parse("https://private-mirror-us-east-2.s3.dualstack.us-east-2.amazonaws.com" + testBlobPath)
+	signed, err := s.sign(context.Background(), http.MethodGet, u, "us-east-2", 5*time.Minute, testSigningTime())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	signedURL, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signedURL.EscapedPath() != strings.ReplaceAll(testBlobPath, ":", "%3A") {
+		t.Fatalf("expected the digest to be escaped once, but got path: %q", signedURL.EscapedPath())
+	}
+	query := signedURL.Query()
+	expected := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    "AKIDEXAMPLE/20260301/us-east-2/s3/aws4_request",
+		"X-Amz-Date":          "20260301T123000Z",
+		"X-Amz-Expires":       "300",
+		"X-Amz-SignedHeaders": "host",
+	}
+	for k, v := range expected {
+		if query.Get(k) != v {
+			t.Fatalf("expected %s: %q, but got: %q", k, v, query.Get(k))
+		}
+	}
+	if len(query.Get("X-Amz-Signature")) != 64 {
+		t.Fatalf("expected a signature, but got: %q", query.Get("X-Amz-Signature"))
+	}
+}
+
+func TestGCSURLSigner(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &gcsURLSigner{
+		email: "mirror@example.iam.gserviceaccount.com",
+		signBytes: func(_ context.Context, b []byte) ([]byte, error) {
+			sum := sha256.Sum256(b)
+			return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+		},
+	}
+	u, _ := url.Parse("https://storage.googleapis.com/private-mirror" + testBlobPath)
+	signed, err := g.sign(context.Background(), http.MethodHead, u, 5*time.Minute, testSigningTime())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	unsigned, signatureHex, ok := strings.Cut(signed, "&X-Goog-Signature=")
+	if !ok {
+		t.Fatalf("expected a signature, but got: %q", signed)
+	}
+	signature, err := hex.DecodeString(signatureHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rebuild the string to sign per the GCS V4 signing docs
+	_, canonicalQuery, _ := strings.Cut(unsigned, "?")
+	expectedQuery := "X-Goog-Algorithm=GOOG4-RSA-SHA256" +
+		"&X-Goog-Credential=mirror%40example.iam.gserviceaccount.com%2F20260301%2Fauto%2Fstorage%2Fgoog4_request" +
+		"&X-Goog-Date=20260301T123000Z&X-Goog-Expires=300&X-Goog-SignedHeaders=host"
+	if canonicalQuery != expectedQuery {
+		t.Fatalf("expected query: %q, but got: %q", expectedQuery, canonicalQuery)
+	}
+	canonicalRequest := "HEAD\n/private-mirror" + strings.ReplaceAll(testBlobPath, ":", "%3A") + "\n" +
+		canonicalQuery + "\nhost:storage.googleapis.com\n\nhost\nUNSIGNED-PAYLOAD"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "GOOG4-RSA-SHA256\n20260301T123000Z\n20260301/auto/storage/goog4_request\n" + hex.EncodeToString(requestHash[:])
+	sum := sha256.Sum256([]byte(stringToSign))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], signature); err != nil {
+		t.Fatalf("signature does not verify: %v", err)
+	}
+}
+
+func TestBucketURLSigner(t *testing.T) {
+	b := &bucketURLSigner{
+		expiry: time.Minute,
+		s3: &s3URLSigner{
+			credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}, nil
+			}),
+			signer: v4.NewSigner(),
+		},
+		gcs: &gcsURLSigner{
+			email: "mirror@example.iam.gserviceaccount.com",
+			signBytes: func(context.Context, []byte) ([]byte, error) {
+				return []byte("signature"), nil
+			},
+		},
+	}
+	testCases := []struct {
+		Name             string
+		URL              string
+		ExpectedContains string
+	}{
+		{Name: "S3 dualstack", URL: "https://mirror.s3.dualstack.eu-west-3.amazonaws.com" + testBlobPath, ExpectedContains: "%2Feu-west-3%2Fs3%2F"},
+		{Name: "S3 regional", URL: "https://mirror.s3.ap-south-1.amazonaws.com" + testBlobPath, ExpectedContains: "%2Fap-south-1%2Fs3%2F"},
+		{Name: "S3 global", URL: "https://mirror.s3.amazonaws.com" + testBlobPath, ExpectedContains: "%2Fus-east-1%2Fs3%2F"},
+		{Name: "GCS", URL: "https://storage.googleapis.com/mirror" + testBlobPath, ExpectedContains: "&X-Goog-Signature=" + hex.EncodeToString([]byte("signature"))},
+		{Name: "CloudFront", URL: "https://d1be1w964nk82h.cloudfront.net" + testBlobPath, ExpectedContains: ""},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			signed, err := b.SignURL(http.MethodGet, tc.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.ExpectedContains == "" {
+				if signed != tc.URL {
+					t.Fatalf("expected url: %q, but got: %q", tc.URL, signed)
+				}
+				return
+			}
+			if !strings.Contains(signed, tc.ExpectedContains) {
+				t.Fatalf("expected url containing %q, but got: %q", tc.ExpectedContains, signed)
+			}
+		})
+	}
+}
+
+func TestCachedBlobCheckerSigned(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || r.URL.Query().Get("signed") != http.MethodHead {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	t.Cleanup(server.Close)
+	blobURL := server.URL + testBlobPath
+	if newCachedBlobChecker(nil).BlobExists(blobURL) {
+		t.Fatal("expected unsigned check of private blob to fail")
+	}
+	checker := newCachedBlobChecker(&fakeURLSigner{})
+	if !checker.BlobExists(blobURL) {
+		t.Fatal("expected signed check of private blob to succeed")
+	}
+	// the existence cache should be keyed by the unsigned URL
+	if !checker.Get(blobURL) {
+		t.Fatal("expected the unsigned URL to be cached")
+	}
+	// so cached blobs are not checked or signed again
+	checker.signer = &fakeURLSigner{err: errors.New("no credentials")}
+	if !checker.BlobExists(blobURL) {
+		t.Fatal("expected cached blob to exist")
+	}
+	if newCachedBlobChecker(&fakeURLSigner{err: errors.New("no credentials")}).BlobExists(blobURL) {
+		t.Fatal("expected check to fail when signing fails")
+	}
+}
+
+func TestCachedManifestFetcherSigned(t *testing.T) {
+	manifest := []byte(`{"schemaVersion":2}`)
+	digest := sha256Digest(manifest)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("signed") != http.MethodGet:
+			w.WriteHeader(http.StatusForbidden)
+		case strings.HasSuffix(r.URL.Path, ".mediatype"):
+			_, _ = w.Write([]byte("application/vnd.oci.image.manifest.v1+json"))
+		default:
+			_, _ = w.Write(manifest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	if _, ok := newCachedManifestFetcher(nil).FetchManifest(server.URL, digest); ok {
+		t.Fatal("expected unsigned fetch of private manifest to fail")
+	}
+	if _, ok := newCachedManifestFetcher(&fakeURLSigner{err: errors.New("no credentials")}).FetchManifest(server.URL, digest); ok {
+		t.Fatal("expected fetch to fail when signing fails")
+	}
+	if _, ok := newCachedManifestFetcher(&fakeURLSigner{}).FetchManifest(server.URL, digest); !ok {
+		t.Fatal("expected signed fetch of private manifest to succeed")
+	}
+}
+
+func TestMakeV2HandlerSignedURLs(t *testing.T) {
+	registryConfig := RegistryConfig{
+		UpstreamRegistryEndpoint: "https://us-central1-docker.pkg.dev",
+		UpstreamRegistryPath:     "k8s-artifacts-prod/images",
+	}
+	bucketURL := "https://prod-registry-k8s-io-eu-west-3.s3.dualstack.eu-west-3.amazonaws.com"
+	blobs := &fakeBlobsChecker{knownURLs: map[string]bool{bucketURL + testBlobPath: true}}
+	testCases := []struct {
+		Name        string
+		Method      string
+		Signer      urlSigner
+		ExpectedURL string
+	}{
+		{
+			Name:        "GET signed",
+			Method:      "GET",
+			Signer:      &fakeURLSigner{},
+			ExpectedURL: bucketURL + testBlobPath + "?signed=GET",
+		},
+		{
+			Name:        "HEAD signed",
+			Method:      "HEAD",
+			Signer:      &fakeURLSigner{},
+			ExpectedURL: bucketURL + testBlobPath + "?signed=HEAD",
+		},
+		{
+			Name:        "signing fails",
+			Method:      "GET",
+			Signer:      &fakeURLSigner{err: errors.New("no credentials")},
+			ExpectedURL: "https://us-central1-docker.pkg.dev/v2/k8s-artifacts-prod/images/pause/blobs/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e",
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			handler := makeV2Handler(registryConfig, blobs, &fakeManifestFetcher{}, nil, nil, nil, nil, tc.Signer, cloudcidrs.NewIPMapper(), nil)
+			r := httptest.NewRequest(tc.Method, "http://localhost:8080/v2/pause/blobs/sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e", nil)
+			r.RemoteAddr = "35.180.1.1:888"
+			recorder := httptest.NewRecorder()
+			handler(recorder, r)
+			response := recorder.Result()
+			if response.StatusCode != http.StatusTemporaryRedirect {
+				t.Fatalf("expected status: %v, but got status: %v", http.StatusTemporaryRedirect, response.StatusCode)
+			}
+			if location := response.Header.Get("Location"); location != tc.ExpectedURL {
+				t.Fatalf("expected url: %q, but got: %q", tc.ExpectedURL, location)
+			}
+		})
+	}
+}
+
+func TestBucketURLSignerCache(t *testing.T) {
+	signs := 0
+	b := &bucketURLSigner{
+		expiry: time.Minute,
+		gcs: &gcsURLSigner{
+			email: "mirror@example.iam.gserviceaccount.com",
+			signBytes: func(context.Context, []byte) ([]byte, error) {
+				signs++
+				return []byte("signature"), nil
+			},
+		},
+	}
+	blobURL := "https://storage.googleapis.com/mirror" + testBlobPath
+	first, err := b.SignURL(http.MethodGet, blobURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// signed URLs are reused for the same method and object
+	if signed, err := b.SignURL(http.MethodGet, blobURL); err != nil || signed != first || signs != 1 {
+		t.Fatalf("expected the signed URL to be reused, got %q, %v after %d signs", signed, err, signs)
+	}
+	if _, err := b.SignURL(http.MethodHead, blobURL); err != nil || signs != 2 {
+		t.Fatalf("expected a new signed URL for HEAD, got %v after %d signs", err, signs)
+	}
+	// until they have less than half of expiry left
+	b.signed.Store(http.MethodGet+" "+blobURL, signedURL{url: first, reuseUntil: time.Now().Add(-time.Second)})
+	if _, err := b.SignURL(http.MethodGet, blobURL); err != nil || signs != 3 {
+		t.Fatalf("expected the expired signed URL to be replaced, got %v after %d signs", err, signs)
+	}
+}
+
+func TestBucketURLSignerSweep(t *testing.T) {
+	b := &bucketURLSigner{
+		expiry: time.Minute,
+		gcs: &gcsURLSigner{
+			email: "mirror@example.iam.gserviceaccount.com",
+			signBytes: func(context.Context, []byte) ([]byte, error) {
+				return []byte("signature"), nil
+			},
+		},
+	}
+	cached := func() int {
+		n := 0
+		b.signed.Range(func(any, any) bool {
+			n++
+			return true
+		})
+		return n
+	}
+	blobURL := "https://storage.googleapis.com/mirror" + testBlobPath
+	if _, err := b.SignURL(http.MethodGet, blobURL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// expired URLs are not swept again until half of expiry has passed
+	b.signed.Store(http.MethodGet+" "+blobURL, signedURL{reuseUntil: time.Now().Add(-time.Second)})
+	if _, err := b.SignURL(http.MethodHead, blobURL); err != nil || cached() != 2 {
+		t.Fatalf("expected 2 cached URLs before sweeping, got %d, %v", cached(), err)
+	}
+	// then they are deleted when the next URL is signed
+	b.sweptAt.Store(time.Now().Add(-time.Minute).UnixNano())
+	if _, err := b.SignURL(http.MethodGet, blobURL+"-other"); err != nil || cached() != 2 {
+		t.Fatalf("expected 2 cached URLs after sweeping, got %d, %v", cached(), err)
+	}
+	if _, ok := b.signed.Load(http.MethodGet + " " + blobURL); ok {
+		t.Fatal("expected the expired URL to be swept")
+	}
+}
+
+func TestBucketURLSignerErrors(t *testing.T) {
+	b := &bucketURLSigner{
+		expiry: time.Minute,
+		s3: &s3URLSigner{
+			credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{}, errors.New("no credentials")
+			}),
+			signer: v4.NewSigner(),
+		},
+		gcs: &gcsURLSigner{
+			signBytes: func(context.Context, []byte) ([]byte, error) {
+				return nil, errors.New("permission denied")
+			},
+		},
+	}
+	for _, objectURL := range []string{
+		"http://\x7f",
+		"https://mirror.s3.amazonaws.com" + testBlobPath,
+		"https://storage.googleapis.com/mirror" + testBlobPath,
+	} {
+		if _, err := b.SignURL(http.MethodGet, objectURL); err == nil {
+			t.Errorf("expected error signing %q", objectURL)
+		}
+		if _, ok := b.signed.Load(http.MethodGet + " " + objectURL); ok {
+			t.Errorf("expected failure signing %q not to be cached", objectURL)
+		}
+	}
+	s := &s3URLSigner{
+		credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}, nil
+		}),
+		signer: v4.NewSigner(),
+	}
+	u, _ := url.Parse("https://mirror.s3.amazonaws.com" + testBlobPath)
+	if _, err := s.sign(context.Background(), "INVALID METHOD", u, "us-east-1", time.Minute, testSigningTime()); err == nil {
+		t.Error("expected error for an invalid method")
+	}
+}
+
+// writeServiceAccountKey writes Google service account credentials with key
+func writeServiceAccountKey(t *testing.T, key []byte) string {
+	t.Helper()
+	b, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "mirror@example.iam.gserviceaccount.com",
+		"private_key":  string(key),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testPrivateKeyPEM(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestNewBucketURLSigner(t *testing.T) {
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	_, keyPEM := testPrivateKeyPEM(t)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", writeServiceAccountKey(t, keyPEM))
+	b := newBucketURLSigner(t.Context(), time.Minute)
+	if b.s3 == nil || b.gcs == nil {
+		t.Fatal("expected S3 and GCS URLs to be signed")
+	}
+	// buckets without credentials are not signed
+	t.Setenv("AWS_PROFILE", "missing")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(t.TempDir(), "missing.json"))
+	b = newBucketURLSigner(t.Context(), time.Minute)
+	if b.s3 != nil || b.gcs != nil {
+		t.Fatal("expected S3 and GCS URLs not to be signed")
+	}
+}
+
+func TestNewGCSURLSigner(t *testing.T) {
+	key, keyPEM := testPrivateKeyPEM(t)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", writeServiceAccountKey(t, keyPEM))
+	g, err := newGCSURLSigner(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if g.email != "mirror@example.iam.gserviceaccount.com" {
+		t.Fatalf("expected the service account email, but got: %q", g.email)
+	}
+	// service account keys sign locally
+	signature, err := g.signBytes(t.Context(), []byte("content"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sum := sha256.Sum256([]byte("content"))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], signature); err != nil {
+		t.Fatalf("signature does not verify: %v", err)
+	}
+
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", writeServiceAccountKey(t, []byte("invalid")))
+	if _, err := newGCSURLSigner(t.Context()); err == nil {
+		t.Fatal("expected error for an invalid private key")
+	}
+
+	// other credentials sign with the IAM API on GCP
+	credentials := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(credentials, []byte(`{"type":"authorized_user","client_id":"id","client_secret":"secret","refresh_token":"token","token_uri":"https://oauth2.example.com/token"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", credentials)
+	t.Setenv("GCE_METADATA_HOST", "")
+	// a cancelled context fails checking for the metadata server immediately
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := newGCSURLSigner(ctx); err == nil {
+		t.Fatal("expected error when not on GCP")
+	}
+	// a fake of the metadata server, and of the Google APIs
+	google := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/computeMetadata/v1/instance/service-accounts/default/email":
+			_, _ = w.Write([]byte("default@example.iam.gserviceaccount.com"))
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"token","token_type":"Bearer","expires_in":3600}`))
+		case "/v1/projects/-/serviceAccounts/default@example.iam.gserviceaccount.com:signBlob":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"signedBlob":"c2lnbmF0dXJl"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(google.Close)
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(google.URL, "http://"))
+	ctx = context.WithValue(t.Context(), oauth2.HTTPClient, &http.Client{Transport: redirectTransport{host: google.Listener.Addr().String()}})
+	g, err = newGCSURLSigner(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if g.email != "default@example.iam.gserviceaccount.com" {
+		t.Fatalf("expected the default service account email, but got: %q", g.email)
+	}
+	if signature, err := g.signBytes(t.Context(), []byte("content")); err != nil || string(signature) != "signature" {
+		t.Fatalf("expected signature from the IAM API, but got %q, %v", signature, err)
+	}
+	google.Close()
+	if _, err := newGCSURLSigner(ctx); err == nil {
+		t.Fatal("expected error without a metadata server")
+	}
+}
+
+// redirectTransport sends every request to host instead, over HTTP
+type redirectTransport struct {
+	host string
+}
+
+func (r redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = "http", r.host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestParseRSAPrivateKey(t *testing.T) {
+	key, pkcs8 := testPrivateKeyPEM(t)
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		Name          string
+		PEM           []byte
+		ExpectedError bool
+	}{
+		{Name: "PKCS #8", PEM: pkcs8},
+		{Name: "PKCS #1", PEM: pkcs1},
+		{Name: "not an RSA key", PEM: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecDER}), ExpectedError: true},
+		{Name: "not a key", PEM: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("invalid")}), ExpectedError: true},
+		{Name: "not PEM", PEM: []byte("invalid"), ExpectedError: true},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			parsed, err := parseRSAPrivateKey(tc.PEM)
+			if tc.ExpectedError {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !parsed.Equal(key) {
+				t.Fatal("expected the parsed key to match")
+			}
+		})
+	}
+}
+
+func TestIAMSignBlob(t *testing.T) {
+	const email = "mirror@example.iam.gserviceaccount.com"
+	testCases := []struct {
+		Name              string
+		Status            int
+		Response          string
+		ExpectedSignature string
+	}{
+		{Name: "signed", Status: http.StatusOK, Response: `{"signedBlob":"c2lnbmF0dXJl"}`, ExpectedSignature: "signature"},
+		{Name: "permission denied", Status: http.StatusForbidden},
+		{Name: "invalid response", Status: http.StatusOK, Response: `{`},
+		{Name: "invalid signature", Status: http.StatusOK, Response: `{"signedBlob":"!"}`},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Payload string `json:"payload"`
+				}
+				if r.Method != http.MethodPost || r.URL.Path != "/v1/projects/-/serviceAccounts/"+email+":signBlob" ||
+					json.NewDecoder(r.Body).Decode(&body) != nil || body.Payload != "Y29udGVudA==" {
+					http.Error(w, "unexpected request", http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(tc.Status)
+				_, _ = w.Write([]byte(tc.Response))
+			}))
+			t.Cleanup(server.Close)
+			signature, err := iamSignBlob(t.Context(), server.Client(), server.URL, email, []byte("content"))
+			if tc.ExpectedSignature == "" {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(signature) != tc.ExpectedSignature {
+				t.Fatalf("expected signature %q, but got %q", tc.ExpectedSignature, signature)
+			}
+		})
+	}
+	for _, endpoint := range []string{"http://\x7f", "http://127.0.0.1:0"} {
+		if _, err := iamSignBlob(t.Context(), http.DefaultClient, endpoint, email, []byte("content")); err == nil {
+			t.Errorf("expected error for endpoint %q", endpoint)
+		}
+	}
+}
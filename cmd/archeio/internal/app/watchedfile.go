@@ -0,0 +1,95 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// watchedFileReloadInterval is how often watched files are checked for changes
+const watchedFileReloadInterval = 10 * time.Second
+
+// watchedFile is a config file that is parsed into T and reloaded when it changes
+//
+// If the file cannot be loaded the last valid T is kept,
+// until the first valid T is loaded Load returns nil.
+type watchedFile[T any] struct {
+	path    string
+	parse   func([]byte) (*T, error)
+	current atomic.Pointer[T]
+	modTime time.Time
+	size    int64
+}
+
+// newWatchedFile loads path with parse, and reloads it every interval
+// until ctx is done
+func newWatchedFile[T any](ctx context.Context, path string, parse func([]byte) (*T, error), interval time.Duration) *watchedFile[T] {
+	f := &watchedFile[T]{path: path, parse: parse}
+	f.reload()
+	go f.watch(ctx, interval)
+	return f
+}
+
+// watch reloads the file every interval until ctx is done
+func (f *watchedFile[T]) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			f.reload()
+		}
+	}
+}
+
+// Load returns the last valid contents of the file, or nil
+func (f *watchedFile[T]) Load() *T {
+	return f.current.Load()
+}
+
+// reload loads the file if it has changed
+func (f *watchedFile[T]) reload() {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		klog.ErrorS(err, "failed to check file", "path", f.path)
+		return
+	}
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return
+	}
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		klog.ErrorS(err, "failed to read file", "path", f.path)
+		return
+	}
+	// invalid contents are only parsed and logged again once they change
+	f.modTime, f.size = info.ModTime(), info.Size()
+	parsed, err := f.parse(b)
+	if err != nil {
+		klog.ErrorS(err, "failed to parse file, keeping previous contents", "path", f.path)
+		return
+	}
+	f.current.Store(parsed)
+	klog.InfoS("loaded file", "path", f.path)
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func parseString(b []byte) (*string, error) {
+	s := string(b)
+	return &s, nil
+}
+
+func TestWatchedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	f := newWatchedFile(t.Context(), path, parseString, time.Millisecond)
+	if f.Load() != nil {
+		t.Fatal("expected nothing to be loaded without a file")
+	}
+	if err := os.WriteFile(path, []byte("contents"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// the file should be loaded by the next reload
+	for deadline := time.Now().Add(10 * time.Second); f.Load() == nil; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the file to be loaded")
+		}
+	}
+	if contents := *f.Load(); contents != "contents" {
+		t.Fatalf("expected contents but got %q", contents)
+	}
+}
+
+func TestWatchedFileStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	f := &watchedFile[string]{path: filepath.Join(t.TempDir(), "file"), parse: parseString}
+	stopped := make(chan struct{})
+	go func() {
+		f.watch(ctx, time.Millisecond)
+		close(stopped)
+	}()
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for watching to stop")
+	}
+}
+
+func TestWatchedFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	f := &watchedFile[string]{path: path, parse: parseString}
+	// the file cannot be read
+	if err := os.Mkdir(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f.reload()
+	if f.Load() != nil {
+		t.Fatal("expected nothing to be loaded from a directory")
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("contents"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f.reload()
+	loaded := f.Load()
+	if loaded == nil || *loaded != "contents" {
+		t.Fatal("expected the file to be loaded")
+	}
+	// unchanged files are not parsed again
+	f.reload()
+	if f.Load() != loaded {
+		t.Fatal("expected the unchanged file not to be reloaded")
+	}
+}
+
+func TestWatchedFileReloadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	parsed := 0
+	f := &watchedFile[string]{path: path, parse: func(b []byte) (*string, error) {
+		parsed++
+		if string(b) == "invalid" {
+			return nil, errors.New("invalid contents")
+		}
+		return parseString(b)
+	}}
+	writeFile := func(content string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		// ensure the change is detected on filesystems with coarse timestamps
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	writeFile("invalid", now)
+	f.reload()
+	// unchanged invalid files are not parsed again
+	f.reload()
+	if parsed != 1 || f.Load() != nil {
+		t.Fatalf("expected the invalid file to be parsed once, parsed %d times", parsed)
+	}
+	writeFile("contents", now.Add(time.Second))
+	f.reload()
+	if loaded := f.Load(); parsed != 2 || loaded == nil || *loaded != "contents" {
+		t.Fatal("expected the changed file to be loaded")
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -40,20 +41,70 @@ func main() {
 	// https://cloud.google.com/run/docs/container-contract#port
 	port := getEnv("PORT", "8080")
 
+	// only used with PROXY_UPSTREAM=true and PROXY_CACHE_DIRECTORY set
+	proxyCacheMaxBytes, err := strconv.ParseInt(getEnv("PROXY_CACHE_MAX_BYTES", strconv.Itoa(10*1024*1024*1024)), 10, 64)
+	if err != nil {
+		klog.Fatalf("invalid PROXY_CACHE_MAX_BYTES: %v", err)
+	}
+	upstreamRoutes, err := app.ParseUpstreamRoutes(getEnv("UPSTREAM_ROUTES", ""))
+	if err != nil {
+		klog.Fatalf("invalid UPSTREAM_ROUTES: %v", err)
+	}
+
+	upstreamRegistryLocations, err := app.ParseUpstreamRegistryLocations(getEnv("UPSTREAM_REGISTRY_LOCATIONS", ""))
+	if err != nil {
+		klog.Fatalf("invalid UPSTREAM_REGISTRY_LOCATIONS: %v", err)
+	}
+
+	// only used with SIGN_BUCKET_URLS=true
+	signedURLExpiry, err := time.ParseDuration(getEnv("SIGNED_URL_EXPIRY", "5m"))
+	if err != nil {
+		klog.Fatalf("invalid SIGNED_URL_EXPIRY: %v", err)
+	}
+
 	registryConfig := app.RegistryConfig{
 		UpstreamRegistryEndpoint:  getEnv("UPSTREAM_REGISTRY_ENDPOINT", "https://us-central1-docker.pkg.dev"),
 		UpstreamRegistryPath:      getEnv("UPSTREAM_REGISTRY_PATH", "k8s-artifacts-prod/images"),
+		UpstreamRoutes:            upstreamRoutes,
+		UpstreamRegistryLocations: upstreamRegistryLocations,
 		SignatureUpstreamEndpoint: getEnv("SIGNATURE_UPSTREAM_ENDPOINT", ""),
-		InfoURL:                   "https://github.com/kubernetes/registry.k8s.io",
-		PrivacyURL:                "https://www.linuxfoundation.org/privacy-policy/",
-		DefaultAWSBaseURL:         getEnv("DEFAULT_AWS_BASE_URL", "https://d1be1w964nk82h.cloudfront.net"),
+		// optional IPv6 alternatives, for when the above are IPv4-only
+		UpstreamRegistryEndpointIPv6:  getEnv("UPSTREAM_REGISTRY_ENDPOINT_IPV6", ""),
+		SignatureUpstreamEndpointIPv6: getEnv("SIGNATURE_UPSTREAM_ENDPOINT_IPV6", ""),
+		DefaultAWSBaseURLIPv6:         getEnv("DEFAULT_AWS_BASE_URL_IPV6", ""),
+		InfoURL:                       "https://github.com/kubernetes/registry.k8s.io",
+		PrivacyURL:                    "https://www.linuxfoundation.org/privacy-policy/",
+		DefaultAWSBaseURL:             getEnv("DEFAULT_AWS_BASE_URL", "https://d1be1w964nk82h.cloudfront.net"),
+		ServeManifestsFromBuckets:     getEnv("SERVE_MANIFESTS_FROM_BUCKETS", "") == "true",
+		LocalBlobDirectory:            getEnv("LOCAL_BLOB_DIRECTORY", ""),
+		ProxyUpstream:                 getEnv("PROXY_UPSTREAM", "") == "true",
+		ProxyCacheDirectory:           getEnv("PROXY_CACHE_DIRECTORY", ""),
+		ProxyCacheMaxBytes:            proxyCacheMaxBytes,
+		PolicyFile:                    getEnv("POLICY_FILE", ""),
+		PinnedTagsFile:                getEnv("PINNED_TAGS_FILE", ""),
+		GeoIPDatabaseFile:             getEnv("GEOIP_DATABASE_FILE", ""),
+		SignBucketURLs:                getEnv("SIGN_BUCKET_URLS", "") == "true",
+		SignedURLExpiry:               signedURLExpiry,
+		TokenAuthRealm:                getEnv("TOKEN_AUTH_REALM", ""),
+		TokenAuthService:              getEnv("TOKEN_AUTH_SERVICE", "registry.k8s.io"),
+		TokenAuthIssuer:               getEnv("TOKEN_AUTH_ISSUER", ""),
+		TokenAuthJWKSFile:             getEnv("TOKEN_AUTH_JWKS_FILE", ""),
 	}
+	if registryConfig.TokenAuthRealm != "" && registryConfig.TokenAuthJWKSFile == "" {
+		klog.Fatal("TOKEN_AUTH_JWKS_FILE is required with TOKEN_AUTH_REALM")
+	}
+
+	// config files are watched for changes until we shut down
+	handlerCtx, stopHandler := context.WithCancel(context.Background())
+	defer stopHandler()
 
 	// configure server with reasonable timeout
-	// we only serve redirects, 10s should be sufficient
+	// we mostly serve redirects, 10s should be sufficient to read requests
+	// NOTE: there is no write timeout, as we may stream large blobs from a
+	// local directory or upstream
 	server := &http.Server{
 		Addr:              ":" + port,
-		Handler:           app.MakeHandler(registryConfig),
+		Handler:           app.MakeHandler(handlerCtx, registryConfig),
 		ReadTimeout:       10 * time.Second,
 		ReadHeaderTimeout: 2 * time.Second,
 	}
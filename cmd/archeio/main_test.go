@@ -25,6 +25,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -35,6 +36,13 @@ import (
 	"k8s.io/registry.k8s.io/pkg/net/cloudcidrs"
 )
 
+// IPv6 endpoints the server under test is configured with, these must serve
+// the same content as the defaults so that pulls over IPv6 still succeed
+const (
+	upstreamRegistryEndpointIPv6 = "https://us-east4-docker.pkg.dev"
+	defaultAWSBaseURLIPv6        = "https://prod-registry-k8s-io-us-east-2.s3.dualstack.us-east-2.amazonaws.com"
+)
+
 type integrationTestCase struct {
 	Name   string
 	FakeIP string
@@ -64,7 +72,11 @@ func TestIntegrationMain(t *testing.T) {
 	serverErrChan := make(chan error)
 	serverCmd := exec.Command("./archeio", "-v=9")
 	serverCmd.Dir = filepath.Join(rootDir, "bin")
-	serverCmd.Env = append(serverCmd.Env, "PORT="+testPort)
+	serverCmd.Env = append(serverCmd.Env,
+		"PORT="+testPort,
+		"UPSTREAM_REGISTRY_ENDPOINT_IPV6="+upstreamRegistryEndpointIPv6,
+		"DEFAULT_AWS_BASE_URL_IPV6="+defaultAWSBaseURLIPv6,
+	)
 	serverCmd.Stderr = os.Stderr
 	go func() {
 		serverErrChan <- serverCmd.Start()
@@ -91,6 +103,30 @@ func TestIntegrationMain(t *testing.T) {
 		t.Fatal("timed out waiting for archeio to be ready")
 	}
 
+	// IPv6 clients are redirected to the IPv6 endpoints
+	for _, tc := range []struct {
+		FakeIP         string
+		ExpectedPrefix string
+	}{
+		{FakeIP: "192.168.0.1", ExpectedPrefix: "https://us-central1-docker.pkg.dev/"},
+		{FakeIP: "2001:db8::1", ExpectedPrefix: upstreamRegistryEndpointIPv6 + "/"},
+	} {
+		client := &http.Client{
+			Transport: newFakeIPTransport(tc.FakeIP),
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+		resp, err := client.Get("http://" + testAddr + "/v2/pause/manifests/3.9")
+		if err != nil {
+			t.Fatalf("Failed to request manifest as %q: %v", tc.FakeIP, err)
+		}
+		resp.Body.Close()
+		if location := resp.Header.Get("Location"); !strings.HasPrefix(location, tc.ExpectedPrefix) {
+			t.Errorf("Expected %q to be redirected to %q but got: %q", tc.FakeIP, tc.ExpectedPrefix, location)
+		}
+	}
+
 	// perform many test pulls ...
 	testCases := makeTestCases(t)
 	for i := range testCases {
@@ -164,6 +200,26 @@ func makeTestCases(t *testing.T) []integrationTestCase {
 	}
 	interestingIPs = append(interestingIPs, interestingIP{Name: "External", IP: externalIP})
 
+	// IPv6 equivalents of the above, to cover IPv6-only clients
+	const gcpIPv6 = "2600:1900:4010::1"
+	if info, matches := cidrs.GetIP(netip.MustParseAddr(gcpIPv6)); !matches || info.Cloud != cloudcidrs.GCP {
+		t.Fatalf("Expected %q to be a GCP IP but is not detected as one with current data", gcpIPv6)
+	}
+	interestingIPs = append(interestingIPs, interestingIP{Name: "GCP IPv6", IP: gcpIPv6})
+
+	const awsIPv6 = "2600:1f01:4810::1"
+	if info, matches := cidrs.GetIP(netip.MustParseAddr(awsIPv6)); !matches || info.Cloud != cloudcidrs.AWS {
+		t.Fatalf("Expected %q to be an AWS IP but is not detected as one with current data", awsIPv6)
+	}
+	interestingIPs = append(interestingIPs, interestingIP{Name: "AWS IPv6", IP: awsIPv6})
+
+	// documentation range, see RFC 3849
+	const externalIPv6 = "2001:db8::1"
+	if _, matches := cidrs.GetIP(netip.MustParseAddr(externalIPv6)); matches {
+		t.Fatalf("Expected %q to not match any provider IP range but it does", externalIPv6)
+	}
+	interestingIPs = append(interestingIPs, interestingIP{Name: "External IPv6", IP: externalIPv6})
+
 	// generate testcases from test data, for every interesting IP pull each image
 	testCases := []integrationTestCase{}
 	for _, image := range wellKnownImages {
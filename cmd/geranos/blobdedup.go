@@ -0,0 +1,105 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// blobDeduper ensures each unique blob is copied at most once per run
+//
+// Many images share base layers, and we walk images concurrently, so without
+// this the same digest would be checked and possibly uploaded by many
+// goroutines at once.
+type blobDeduper struct {
+	mu sync.Mutex
+	// calls contains both in-flight and successfully completed copies,
+	// failed copies are removed so a later image may retry them
+	calls map[string]*blobCall
+
+	deduplicated atomic.Int64
+	bytesSaved   atomic.Int64
+}
+
+type blobCall struct {
+	done chan struct{}
+	err  error
+}
+
+func newBlobDeduper() *blobDeduper {
+	return &blobDeduper{
+		calls: map[string]*blobCall{},
+	}
+}
+
+// Do calls copyBlob for key unless another caller has already done so
+// successfully or is currently doing so, in which case it waits for that
+// result instead.
+//
+// deduplicated is true when copyBlob was not called because of another caller
+func (d *blobDeduper) Do(key string, copyBlob func() error) (deduplicated bool, err error) {
+	d.mu.Lock()
+	if c, ok := d.calls[key]; ok {
+		d.mu.Unlock()
+		<-c.done
+		if c.err != nil {
+			return true, c.err
+		}
+		d.deduplicated.Add(1)
+		return true, nil
+	}
+	c := &blobCall{done: make(chan struct{})}
+	d.calls[key] = c
+	d.mu.Unlock()
+
+	c.err = copyBlob()
+	if c.err != nil {
+		d.mu.Lock()
+		delete(d.calls, key)
+		d.mu.Unlock()
+	}
+	close(c.done)
+	return false, c.err
+}
+
+// RecordBytesSaved adds size to the total bytes we avoided re-copying
+func (d *blobDeduper) RecordBytesSaved(size int64) {
+	d.bytesSaved.Add(size)
+}
+
+// blobDedupStats summarizes deduplication over a run
+type blobDedupStats struct {
+	// Unique is the number of unique blobs successfully copied or found
+	Unique int
+	// Deduplicated is the number of copies skipped due to an earlier copy
+	Deduplicated int64
+	// BytesSaved is the total size of the skipped copies
+	BytesSaved int64
+}
+
+// Stats returns a summary of deduplication so far
+func (d *blobDeduper) Stats() blobDedupStats {
+	d.mu.Lock()
+	unique := len(d.calls)
+	d.mu.Unlock()
+	return blobDedupStats{
+		Unique:       unique,
+		Deduplicated: d.deduplicated.Load(),
+		BytesSaved:   d.bytesSaved.Load(),
+	}
+}
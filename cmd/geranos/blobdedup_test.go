@@ -0,0 +1,101 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBlobDeduperConcurrent(t *testing.T) {
+	d := newBlobDeduper()
+	var calls atomic.Int64
+	release := make(chan struct{})
+	const callers = 50
+	var wg sync.WaitGroup
+	var deduplicated atomic.Int64
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dup, err := d.Do("sha256:aaaa", func() error {
+				calls.Add(1)
+				<-release
+				return nil
+			})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if dup {
+				deduplicated.Add(1)
+				d.RecordBytesSaved(10)
+			}
+		}()
+	}
+	close(release)
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected copy to be called once, got %d", n)
+	}
+	stats := d.Stats()
+	expected := blobDedupStats{Unique: 1, Deduplicated: callers - 1, BytesSaved: 10 * (callers - 1)}
+	if stats != expected || deduplicated.Load() != callers-1 {
+		t.Fatalf("expected stats %+v but got %+v", expected, stats)
+	}
+}
+
+func TestBlobDeduperRetriesFailures(t *testing.T) {
+	d := newBlobDeduper()
+	errCopy := errors.New("copy failed")
+	dup, err := d.Do("key", func() error {
+		return errCopy
+	})
+	if !errors.Is(err, errCopy) || dup {
+		t.Fatalf("expected copy error, got deduplicated=%t err=%v", dup, err)
+	}
+
+	// failures are forgotten, so the next caller should copy again
+	called := false
+	dup, err = d.Do("key", func() error {
+		called = true
+		return nil
+	})
+	if err != nil || dup || !called {
+		t.Fatalf("expected blob to be copied again, got called=%t deduplicated=%t err=%v", called, dup, err)
+	}
+	if stats := d.Stats(); stats != (blobDedupStats{Unique: 1}) {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestBlobDeduperSharesInFlightFailure(t *testing.T) {
+	d := newBlobDeduper()
+	errCopy := errors.New("copy failed")
+	// simulate a copy that failed while we were waiting on it
+	c := &blobCall{done: make(chan struct{}), err: errCopy}
+	close(c.done)
+	d.calls["key"] = c
+	dup, err := d.Do("key", func() error {
+		t.Error("copy should not be called while another copy is in flight")
+		return nil
+	})
+	if !errors.Is(err, errCopy) || !dup {
+		t.Fatalf("expected shared copy error, got deduplicated=%t err=%v", dup, err)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// rangedBlob is an imageBlob which can also be read from an offset,
+// so that a resumed upload does not need to download the whole blob again
+type rangedBlob interface {
+	imageBlob
+	CompressedFrom(offset int64) (io.ReadCloser, error)
+}
+
+// remoteBlob is a blob in repo, read from an offset with a range request
+type remoteBlob struct {
+	imageBlob
+	repo      name.Repository
+	keychain  authn.Keychain
+	transport http.RoundTripper
+}
+
+// CompressedFrom returns the content of the blob from offset
+//
+// If the registry does not support range requests the content before offset
+// is read and discarded.
+func (b *remoteBlob) CompressedFrom(offset int64) (io.ReadCloser, error) {
+	digest, err := b.Digest()
+	if err != nil {
+		return nil, err
+	}
+	size, err := b.Size()
+	if err != nil {
+		return nil, err
+	}
+	auth, err := b.keychain.Resolve(b.repo)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.TODO()
+	rt, err := transport.NewWithContext(ctx, b.repo.Registry, auth, b.transport, []string{b.repo.Scope(transport.PullScope)})
+	if err != nil {
+		return nil, err
+	}
+	u := url.URL{
+		Scheme: b.repo.Scheme(),
+		Host:   b.repo.RegistryStr(),
+		Path:   fmt.Sprintf("/v2/%s/blobs/%s", b.repo.RepositoryStr(), digest),
+	}
+	req := (&http.Request{Method: http.MethodGet, URL: &u, Header: http.Header{}}).WithContext(ctx)
+	// the end of the range is not optional for some registries
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, size-1))
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			resp.Body.Close()
+			return nil, errors.New("unexpected Content-Range: " + resp.Header.Get("Content-Range"))
+		}
+		return resp.Body, nil
+	case http.StatusOK:
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		return resp.Body, nil
+	default:
+		defer resp.Body.Close()
+		return nil, httpStatusError(resp)
+	}
+}
+
+// openBlob returns a function opening blob at an offset,
+// for newResumableVerifyingReader
+func openBlob(blob imageBlob) func(offset int64) (io.ReadCloser, error) {
+	return func(offset int64) (io.ReadCloser, error) {
+		if offset == 0 {
+			return blob.Compressed()
+		}
+		if ranged, ok := blob.(rangedBlob); ok {
+			return ranged.CompressedFrom(offset)
+		}
+		return nil, errors.New("blob cannot be read from an offset")
+	}
+}
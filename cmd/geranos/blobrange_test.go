@@ -0,0 +1,251 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// failingKeychain is an authn.Keychain which always fails
+type failingKeychain struct{}
+
+func (failingKeychain) Resolve(authn.Resource) (authn.Authenticator, error) {
+	return nil, errors.New("no credentials")
+}
+
+func TestRemoteBlobCompressedFrom(t *testing.T) {
+	content := []byte("0123456789")
+	sum := sha256.Sum256(content)
+	digest := v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(sum[:])}
+	const offset = 4
+	failed := errors.New("failed")
+	testCases := []struct {
+		Name string
+		// Handler serves the blob, after the registry ping
+		Handler         http.HandlerFunc
+		PingFails       bool
+		Blob            *fakeBlob
+		Keychain        authn.Keychain
+		ExpectedContent string
+		ExpectError     bool
+	}{
+		{
+			Name: "partial content",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Range") != "bytes=4-9" {
+					http.Error(w, "unexpected range: "+r.Header.Get("Range"), http.StatusBadRequest)
+					return
+				}
+				w.Header().Set("Content-Range", "bytes 4-9/10")
+				w.WriteHeader(http.StatusPartialContent)
+				_, _ = w.Write(content[offset:])
+			},
+			ExpectedContent: "456789",
+		},
+		{
+			Name: "range ignored",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write(content)
+			},
+			ExpectedContent: "456789",
+		},
+		{
+			Name: "range ignored and content too short",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write(content[:2])
+			},
+			ExpectError: true,
+		},
+		{
+			Name: "unexpected content range",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Range", "bytes 0-9/10")
+				w.WriteHeader(http.StatusPartialContent)
+				_, _ = w.Write(content)
+			},
+			ExpectError: true,
+		},
+		{
+			Name: "not found",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				http.NotFound(w, r)
+			},
+			ExpectError: true,
+		},
+		{
+			Name: "connection fails",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				conn, _, _ := http.NewResponseController(w).Hijack()
+				conn.Close()
+			},
+			ExpectError: true,
+		},
+		{
+			Name:        "ping fails",
+			PingFails:   true,
+			ExpectError: true,
+		},
+		{
+			Name:        "digest fails",
+			Blob:        &fakeBlob{digestErr: failed},
+			ExpectError: true,
+		},
+		{
+			Name:        "size fails",
+			Blob:        &fakeBlob{digest: digest, sizeErr: failed},
+			ExpectError: true,
+		},
+		{
+			Name:        "credentials fail",
+			Keychain:    failingKeychain{},
+			ExpectError: true,
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			blobPath := fmt.Sprintf("/v2/images/pause/blobs/%s", digest)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/v2/" && !tc.PingFails:
+					w.WriteHeader(http.StatusOK)
+				case r.URL.Path == blobPath && tc.Handler != nil:
+					tc.Handler(w, r)
+				default:
+					http.Error(w, "unexpected request", http.StatusInternalServerError)
+				}
+			}))
+			t.Cleanup(server.Close)
+			u, err := url.Parse(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			repo, err := name.NewRepository(u.Host + "/images/pause")
+			if err != nil {
+				t.Fatal(err)
+			}
+			blob := &remoteBlob{
+				imageBlob: &fakeBlob{digest: digest, content: content},
+				repo:      repo,
+				keychain:  authn.DefaultKeychain,
+				transport: http.DefaultTransport,
+			}
+			if tc.Blob != nil {
+				blob.imageBlob = tc.Blob
+			}
+			if tc.Keychain != nil {
+				blob.keychain = tc.Keychain
+			}
+			r, err := blob.CompressedFrom(offset)
+			if tc.ExpectError {
+				if err == nil {
+					r.Close()
+					t.Fatal("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer r.Close()
+			b, err := io.ReadAll(r)
+			if err != nil || string(b) != tc.ExpectedContent {
+				t.Fatalf("expected %q, got %q, %v", tc.ExpectedContent, b, err)
+			}
+		})
+	}
+}
+
+func TestRemoteBlobCompressedFromRegistry(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(u.Host + "/images/pause")
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteLayer(repo, layer); err != nil {
+		t.Fatal(err)
+	}
+	blob := &remoteBlob{imageBlob: layer, repo: repo, keychain: authn.DefaultKeychain, transport: http.DefaultTransport}
+	r, err := blob.CompressedFrom(100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	expected, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, expected[100:]) {
+		t.Fatalf("expected content from offset 100, got %d bytes", len(b))
+	}
+}
+
+func TestOpenBlob(t *testing.T) {
+	blob := &fakeBlob{content: []byte("content")}
+	r, err := openBlob(blob)(0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b, _ := io.ReadAll(r); !bytes.Equal(b, blob.content) {
+		t.Fatalf("expected %q but got %q", blob.content, b)
+	}
+	// only rangedBlob can be opened at an offset
+	if _, err := openBlob(blob)(1); err == nil || !strings.Contains(err.Error(), "offset") {
+		t.Fatalf("expected error opening blob at an offset but got: %v", err)
+	}
+	ranged := &remoteBlob{imageBlob: blob, keychain: failingKeychain{}}
+	if _, err := openBlob(ranged)(1); err == nil || !strings.Contains(err.Error(), "no credentials") {
+		t.Fatalf("expected ranged blob to be opened with CompressedFrom, but got: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// BlobStore is a destination for mirrored blobs, such as an object storage
+// bucket, all keys are relative to the root of the store
+//
+// Blobs are stored under keyForLayer and manifests under keyForImageRecord,
+// matching the layout cmd/archeio expects.
+type BlobStore interface {
+	// Exists returns true if key exists
+	Exists(ctx context.Context, key string) (bool, error)
+	// Put stores size bytes read from r at key, replacing any existing blob
+	//
+	// digest is the expected digest of the content, stores may use it to have
+	// the backend verify the content, the caller must still verify it while
+	// reading r, and Put must not store the blob if reading r fails
+	Put(ctx context.Context, key string, r io.Reader, size int64, digest v1.Hash) error
+	// Get returns the content at key, or errBlobNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns all keys starting with prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete deletes key, it is not an error if key does not exist
+	Delete(ctx context.Context, key string) error
+}
+
+// errBlobNotFound is returned by BlobStore.Get when the key does not exist
+var errBlobNotFound = errors.New("blob not found")
+
+// blobStoreOptions are options for newBlobStore
+type blobStoreOptions struct {
+	// dryRun avoids requiring credentials for writing where possible
+	dryRun bool
+	// partSize and concurrency configure S3 multipart uploads,
+	// partSize is also the size of Azure blocks
+	partSize    int64
+	concurrency int
+	// azureSASToken authenticates to Azure Blob Storage
+	azureSASToken string
+}
+
+// newBlobStore returns the BlobStore for destination, one of:
+// - s3://bucket
+// - gs://bucket
+// - azblob://account/container
+// - file:///path/to/directory
+func newBlobStore(ctx context.Context, destination string, opts blobStoreOptions) (BlobStore, error) {
+	scheme, location, ok := strings.Cut(destination, "://")
+	if !ok || location == "" {
+		return nil, fmt.Errorf("invalid destination: %q", destination)
+	}
+	switch scheme {
+	case "s3":
+		return newS3BlobStore(ctx, location, opts.dryRun, opts.partSize, opts.concurrency)
+	case "gs":
+		return newGCSBlobStore(ctx, location, opts.dryRun)
+	case "azblob":
+		account, container, ok := strings.Cut(location, "/")
+		if !ok || account == "" || container == "" {
+			return nil, fmt.Errorf("invalid azblob destination, expected azblob://account/container: %q", destination)
+		}
+		return newAzureBlobStore(account, container, opts.azureSASToken, opts.partSize), nil
+	case "file":
+		return newFSBlobStore(location), nil
+	default:
+		return nil, fmt.Errorf("unsupported destination scheme: %q", scheme)
+	}
+}
+
+// httpStatusError returns an error for an unexpected response status,
+// including the start of the response body which usually describes why
+func httpStatusError(resp *http.Response) error {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	// the query may contain credentials, e.g. an Azure SAS
+	u := *resp.Request.URL
+	u.RawQuery = ""
+	return fmt.Errorf("unexpected status %s for %s %s: %s", resp.Status, resp.Request.Method, u.String(), b)
+}
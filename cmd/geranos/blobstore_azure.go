@@ -0,0 +1,243 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// azureAPIVersion is the Azure Blob Storage REST API version we use,
+// this version allows blocks of up to 4000 MiB
+const azureAPIVersion = "2021-08-06"
+
+// azureBlobStore is a BlobStore backed by an Azure Blob Storage container,
+// using the REST API authenticated with a shared access signature (SAS)
+type azureBlobStore struct {
+	// containerURL is the URL of the container, without the SAS
+	containerURL string
+	// sas is the SAS token query string, may be empty for public containers
+	sas    url.Values
+	client *http.Client
+	// blockSize is the size of each block blobs are uploaded in,
+	// each block is buffered in memory while it is uploaded
+	blockSize int64
+}
+
+func newAzureBlobStore(account, container, sasToken string, blockSize int64) *azureBlobStore {
+	// an invalid SAS will fail on the first request instead
+	sas, _ := url.ParseQuery(strings.TrimPrefix(sasToken, "?"))
+	return &azureBlobStore{
+		containerURL: "https://" + account + ".blob.core.windows.net/" + container,
+		sas:          sas,
+		client:       http.DefaultClient,
+		blockSize:    blockSize,
+	}
+}
+
+func (a *azureBlobStore) url(path string, query url.Values) string {
+	q := url.Values{}
+	for k, v := range a.sas {
+		q[k] = v
+	}
+	for k, v := range query {
+		q[k] = v
+	}
+	u := a.containerURL + path
+	if len(q) != 0 {
+		u += "?" + q.Encode()
+	}
+	return u
+}
+
+func (a *azureBlobStore) blobURL(key string, query url.Values) string {
+	// keys may contain "/", which Azure treats as virtual directories
+	parts := strings.Split(key, "/")
+	for i := range parts {
+		parts[i] = url.PathEscape(parts[i])
+	}
+	return a.url("/"+strings.Join(parts, "/"), query)
+}
+
+func (a *azureBlobStore) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	if body != nil {
+		// Azure rejects the request if the body does not match
+		// #nosec G401 -- MD5 is only used to detect corruption in transit
+		sum := md5.Sum(body)
+		req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	return a.client.Do(req)
+}
+
+func (a *azureBlobStore) Exists(ctx context.Context, key string) (bool, error) {
+	resp, err := a.do(ctx, http.MethodHead, a.blobURL(key, nil), nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, httpStatusError(resp)
+	}
+}
+
+// Put uploads r in blocks of blockSize, and only commits them to the blob
+// once all of r was read, if reading r fails no blob is created, and Azure
+// discards the uncommitted blocks after a week
+func (a *azureBlobStore) Put(ctx context.Context, key string, r io.Reader, _ int64, _ v1.Hash) error {
+	blockIDs := []string{}
+	buf := make([]byte, a.blockSize)
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr != nil && !errors.Is(readErr, io.EOF) && !errors.Is(readErr, io.ErrUnexpectedEOF) {
+			return readErr
+		}
+		if n > 0 {
+			// block IDs must all have the same length
+			id := base64.StdEncoding.EncodeToString(fmt.Appendf(nil, "%08d", len(blockIDs)))
+			q := url.Values{"comp": {"block"}, "blockid": {id}}
+			if err := a.put(ctx, a.blobURL(key, q), buf[:n]); err != nil {
+				return err
+			}
+			blockIDs = append(blockIDs, id)
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	// xml.Marshal cannot fail for azureBlockList
+	blockList, _ := xml.Marshal(&azureBlockList{Latest: blockIDs})
+	return a.put(ctx, a.blobURL(key, url.Values{"comp": {"blocklist"}}), blockList)
+}
+
+// azureBlockList is the Put Block List request body
+type azureBlockList struct {
+	XMLName xml.Name `xml:"BlockList"`
+	Latest  []string `xml:"Latest"`
+}
+
+func (a *azureBlobStore) put(ctx context.Context, u string, body []byte) error {
+	resp, err := a.do(ctx, http.MethodPut, u, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return httpStatusError(resp)
+	}
+	return nil
+}
+
+func (a *azureBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := a.do(ctx, http.MethodGet, a.blobURL(key, nil), nil)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, errBlobNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, httpStatusError(resp)
+	}
+}
+
+// azureListPage is the subset of the List Blobs response we use
+type azureListPage struct {
+	Blobs struct {
+		Blob []struct {
+			Name string `xml:"Name"`
+		} `xml:"Blob"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func (a *azureBlobStore) List(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	marker := ""
+	for {
+		q := url.Values{}
+		q.Set("restype", "container")
+		q.Set("comp", "list")
+		q.Set("prefix", prefix)
+		if marker != "" {
+			q.Set("marker", marker)
+		}
+		page, err := a.listPage(ctx, a.url("", q))
+		if err != nil {
+			return nil, err
+		}
+		for _, blob := range page.Blobs.Blob {
+			keys = append(keys, blob.Name)
+		}
+		if page.NextMarker == "" {
+			return keys, nil
+		}
+		marker = page.NextMarker
+	}
+}
+
+func (a *azureBlobStore) listPage(ctx context.Context, u string) (*azureListPage, error) {
+	resp, err := a.do(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, httpStatusError(resp)
+	}
+	page := &azureListPage{}
+	if err := xml.NewDecoder(resp.Body).Decode(page); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (a *azureBlobStore) Delete(ctx context.Context, key string) error {
+	resp, err := a.do(ctx, http.MethodDelete, a.blobURL(key, nil), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNotFound {
+		return httpStatusError(resp)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// fakeAzure is a minimal fake of the Azure Blob Storage REST API for a
+// single container, requiring the SAS "sig=secret"
+type fakeAzure struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+	// blocks are the uncommitted blocks of each blob by block ID
+	blocks map[string]map[string][]byte
+	// status, if set, is returned for every request
+	status int
+	// listResponse, if set, is returned for listings
+	listResponse string
+}
+
+func newFakeAzure(t *testing.T, blockSize int64) (*fakeAzure, *azureBlobStore) {
+	t.Helper()
+	fake := &fakeAzure{blobs: map[string][]byte{}, blocks: map[string]map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	store := newAzureBlobStore("account", "container", "?sig=secret", blockSize)
+	store.containerURL = server.URL + "/container"
+	store.client = server.Client()
+	return fake, store
+}
+
+func (f *fakeAzure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case f.status != 0:
+		http.Error(w, "injected failure", f.status)
+		return
+	case r.URL.Query().Get("sig") != "secret":
+		http.Error(w, "invalid SAS", http.StatusForbidden)
+		return
+	case r.Header.Get("x-ms-version") != azureAPIVersion:
+		http.Error(w, "invalid version", http.StatusBadRequest)
+		return
+	}
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	if r.Method == http.MethodPut {
+		sum := md5.Sum(b)
+		if r.Header.Get("Content-MD5") != base64.StdEncoding.EncodeToString(sum[:]) {
+			http.Error(w, "Md5Mismatch", http.StatusBadRequest)
+			return
+		}
+	}
+	query := r.URL.Query()
+	if r.URL.Path == "/container" {
+		f.list(w, query.Get("prefix"), query.Get("marker"))
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/container/")
+	blob, exists := f.blobs[key]
+	switch {
+	case r.Method == http.MethodPut && query.Get("comp") == "block":
+		if f.blocks[key] == nil {
+			f.blocks[key] = map[string][]byte{}
+		}
+		f.blocks[key][query.Get("blockid")] = b
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+		blockList := &azureBlockList{}
+		if err := xml.Unmarshal(b, blockList); err != nil {
+			http.Error(w, "InvalidXmlDocument", http.StatusBadRequest)
+			return
+		}
+		blob := []byte{}
+		for _, id := range blockList.Latest {
+			block, ok := f.blocks[key][id]
+			if !ok {
+				http.Error(w, "InvalidBlockList", http.StatusBadRequest)
+				return
+			}
+			blob = append(blob, block...)
+		}
+		delete(f.blocks, key)
+		f.blobs[key] = blob
+		w.WriteHeader(http.StatusCreated)
+	case !exists:
+		http.NotFound(w, r)
+	case r.Method == http.MethodDelete:
+		delete(f.blobs, key)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		_, _ = w.Write(blob)
+	}
+}
+
+// list lists one blob per page to exercise paging
+func (f *fakeAzure) list(w http.ResponseWriter, prefix, marker string) {
+	if f.listResponse != "" {
+		_, _ = w.Write([]byte(f.listResponse))
+		return
+	}
+	keys := []string{}
+	for key := range f.blobs {
+		if strings.HasPrefix(key, prefix) && key > marker {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	page := azureListPage{}
+	if len(keys) != 0 {
+		page.Blobs.Blob = append(page.Blobs.Blob, struct {
+			Name string `xml:"Name"`
+		}{Name: keys[0]})
+	}
+	if len(keys) > 1 {
+		page.NextMarker = keys[0]
+	}
+	_ = xml.NewEncoder(w).Encode(page)
+}
+
+func TestAzureBlobStore(t *testing.T) {
+	ctx := context.Background()
+	fake, store := newFakeAzure(t, 4)
+	const key = "containers/images/sha256:aaaa"
+
+	if exists, err := store.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("expected key not to exist, got %v, %v", exists, err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, errBlobNotFound) {
+		t.Fatalf("expected errBlobNotFound but got: %v", err)
+	}
+	// uploaded in 3 blocks
+	if err := store.Put(ctx, key, strings.NewReader("0123456789"), 10, v1.Hash{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exists, err := store.Exists(ctx, key); err != nil || !exists {
+		t.Fatalf("expected key to exist, got %v, %v", exists, err)
+	}
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(b) != "0123456789" {
+		t.Fatalf("expected content but got %q, %v", b, err)
+	}
+	// empty blobs have no blocks
+	if err := store.Put(ctx, "geranos/uploaded-images/empty", strings.NewReader(""), 0, v1.Hash{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b, exists := fake.blobs["geranos/uploaded-images/empty"]; !exists || len(b) != 0 {
+		t.Fatalf("expected empty blob, got %q, %v", b, exists)
+	}
+
+	// failing to read the content must not create a blob
+	failed := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("connection reset")))
+	if err := store.Put(ctx, "containers/images/sha256:bbbb", failed, 14, v1.Hash{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, exists := fake.blobs["containers/images/sha256:bbbb"]; exists {
+		t.Fatal("expected no blob to be created")
+	}
+
+	if err := store.Put(ctx, "containers/images/sha256:cccc", strings.NewReader("content"), 7, v1.Hash{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keys, err := store.List(ctx, blobKeyPrefix)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{key, "containers/images/sha256:cccc"}; !slices.Equal(keys, expected) {
+		t.Fatalf("expected keys %v but got %v", expected, keys)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("expected deleting a missing key to succeed, got: %v", err)
+	}
+	if exists, err := store.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("expected key not to exist, got %v, %v", exists, err)
+	}
+}
+
+func TestAzureBlobStoreErrors(t *testing.T) {
+	ctx := context.Background()
+	fake, failing := newFakeAzure(t, 4)
+	fake.status = http.StatusInternalServerError
+	_, unreachable := newFakeAzure(t, 4)
+	unreachable.client = &http.Client{Transport: failingRoundTripper{}}
+	invalid := newAzureBlobStore("invalid account", "container", "", 4)
+	for _, store := range []*azureBlobStore{failing, unreachable, invalid} {
+		if _, err := store.Exists(ctx, "key"); err == nil {
+			t.Errorf("%s: expected Exists to fail", store.containerURL)
+		}
+		if err := store.Put(ctx, "key", strings.NewReader("content"), 7, v1.Hash{}); err == nil {
+			t.Errorf("%s: expected Put to fail", store.containerURL)
+		}
+		if _, err := store.Get(ctx, "key"); err == nil {
+			t.Errorf("%s: expected Get to fail", store.containerURL)
+		}
+		if _, err := store.List(ctx, "prefix"); err == nil {
+			t.Errorf("%s: expected List to fail", store.containerURL)
+		}
+		if err := store.Delete(ctx, "key"); err == nil {
+			t.Errorf("%s: expected Delete to fail", store.containerURL)
+		}
+	}
+	// an empty blob only fails to commit
+	if err := failing.Put(ctx, "key", strings.NewReader(""), 0, v1.Hash{}); err == nil {
+		t.Error("expected Put to fail")
+	}
+	fake, store := newFakeAzure(t, 4)
+	fake.listResponse = "<"
+	if _, err := store.List(ctx, "prefix"); err == nil {
+		t.Error("expected List to fail with an invalid response")
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// fsBlobStore is a BlobStore backed by a local directory, keys are stored as
+// paths relative to the directory, e.g. containers/images/sha256:$digest
+//
+// This can be used to seed air-gapped mirrors.
+type fsBlobStore struct {
+	root string
+}
+
+func newFSBlobStore(root string) *fsBlobStore {
+	return &fsBlobStore{root: root}
+}
+
+func (f *fsBlobStore) path(key string) string {
+	return filepath.Join(f.root, filepath.FromSlash(key))
+}
+
+func (f *fsBlobStore) Exists(_ context.Context, key string) (bool, error) {
+	_, err := os.Stat(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (f *fsBlobStore) Put(_ context.Context, key string, r io.Reader, _ int64, _ v1.Hash) error {
+	path := f.path(key)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	// write to a temporary file and rename it into place, so that partial
+	// blobs are never visible at key
+	tmp, err := os.CreateTemp(dir, ".tmp-"+filepath.Base(path)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, r)
+	// CreateTemp creates files only readable by the owner
+	if err := errors.Join(err, tmp.Chmod(0o644), tmp.Close()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (f *fsBlobStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	// #nosec G304 -- keys are generated by geranos
+	file, err := os.Open(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errBlobNotFound
+	}
+	return file, err
+}
+
+func (f *fsBlobStore) List(_ context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	// only walk the deepest directory containing every key with prefix,
+	// paths relative to the root are keys
+	dir := "."
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = prefix[:i]
+	}
+	err := fs.WalkDir(os.DirFS(f.root), dir, func(key string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() && !strings.HasPrefix(d.Name(), ".tmp-") && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (f *fsBlobStore) Delete(_ context.Context, key string) error {
+	err := os.Remove(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
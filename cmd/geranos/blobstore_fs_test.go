@@ -0,0 +1,191 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestFSBlobStore(t *testing.T) {
+	ctx := context.Background()
+	store := newFSBlobStore(t.TempDir())
+	const key = "containers/images/sha256:aaaa"
+
+	if exists, err := store.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("expected key not to exist, got %v, %v", exists, err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, errBlobNotFound) {
+		t.Fatalf("expected errBlobNotFound but got: %v", err)
+	}
+	if err := store.Put(ctx, key, strings.NewReader("content"), 7, v1.Hash{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exists, err := store.Exists(ctx, key); err != nil || !exists {
+		t.Fatalf("expected key to exist, got %v, %v", exists, err)
+	}
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(b) != "content" {
+		t.Fatalf("expected content but got %q, %v", b, err)
+	}
+
+	// failing to read the content must not leave anything at the key
+	failed := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("connection reset")))
+	if err := store.Put(ctx, "containers/images/sha256:bbbb", failed, 7, v1.Hash{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := store.Put(ctx, "geranos/uploaded-images/sha256:cccc", strings.NewReader("manifest"), 8, v1.Hash{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keys, err := store.List(ctx, blobKeyPrefix)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{key}; !slices.Equal(keys, expected) {
+		t.Fatalf("expected keys %v but got %v", expected, keys)
+	}
+	if keys, err := store.List(ctx, "missing/"); err != nil || len(keys) != 0 {
+		t.Fatalf("expected no keys but got %v, %v", keys, err)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("expected deleting a missing key to succeed, got: %v", err)
+	}
+	if exists, err := store.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("expected key not to exist, got %v, %v", exists, err)
+	}
+}
+
+func TestFSBlobStoreErrors(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	store := newFSBlobStore(root)
+	if err := os.WriteFile(filepath.Join(root, "file"), []byte("file"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "dir", "key", "child"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		Name string
+		Key  string
+	}{
+		{Name: "parent is a file", Key: "file/key"},
+		{Name: "name is too long", Key: "dir/" + strings.Repeat("a", 255)},
+		{Name: "key is a directory", Key: "dir/key"},
+	}
+	for _, tc := range testCases {
+		if err := store.Put(ctx, tc.Key, strings.NewReader("content"), 7, v1.Hash{}); err == nil {
+			t.Errorf("%s: expected error", tc.Name)
+		}
+	}
+	if _, err := store.List(ctx, "file/key/"); err == nil {
+		t.Error("expected error listing under a file")
+	}
+	// the root may be listed, temporary files are never listed
+	if err := os.WriteFile(filepath.Join(root, "dir", ".tmp-key"), []byte("partial"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := store.List(ctx, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"file"}; !slices.Equal(keys, expected) {
+		t.Fatalf("expected keys %v but got %v", expected, keys)
+	}
+}
+
+func TestBlobUploaderFS(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgDigest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewDigest(u.Host + "/images/pause@" + imgDigest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	uploader := newBlobUploader(newFSBlobStore(root), false, nil)
+	if uploaded, err := uploader.ImageAlreadyUploaded(imgDigest.String()); err != nil || uploaded {
+		t.Fatalf("expected image not to be uploaded, got %v, %v", uploaded, err)
+	}
+	if err := uploader.UploadImage(ref, layers); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if uploaded, err := uploader.ImageAlreadyUploaded(imgDigest.String()); err != nil || !uploaded {
+		t.Fatalf("expected image to be uploaded, got %v, %v", uploaded, err)
+	}
+	// blobs are laid out as archeio expects
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(root, "containers", "images", digest.String())); err != nil {
+			t.Fatalf("expected layer to be uploaded: %v", err)
+		}
+	}
+	manifest, err := img.RawManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(root, "geranos", "uploaded-images", imgDigest.String()))
+	if err != nil || string(b) != string(manifest) {
+		t.Fatalf("expected manifest to be uploaded, got %q, %v", b, err)
+	}
+}
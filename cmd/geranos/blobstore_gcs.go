@@ -0,0 +1,238 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/url"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"golang.org/x/oauth2/google"
+
+	"k8s.io/klog/v2"
+)
+
+// gcsBlobStore is a BlobStore backed by a Google Cloud Storage bucket,
+// using the JSON API with application default credentials
+type gcsBlobStore struct {
+	bucket string
+	client *http.Client
+	// endpoint is the JSON API endpoint
+	endpoint string
+}
+
+func newGCSBlobStore(ctx context.Context, bucket string, dryRun bool) (*gcsBlobStore, error) {
+	client := http.DefaultClient
+	if !dryRun {
+		var err error
+		client, err = google.DefaultClient(ctx, "https://www.googleapis.com/auth/devstorage.read_write")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &gcsBlobStore{
+		bucket:   bucket,
+		client:   client,
+		endpoint: "https://storage.googleapis.com",
+	}, nil
+}
+
+func (g *gcsBlobStore) objectURL(key string) string {
+	return g.endpoint + "/storage/v1/b/" + url.PathEscape(g.bucket) + "/o/" + url.PathEscape(key)
+}
+
+func (g *gcsBlobStore) do(ctx context.Context, method, url string, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	return g.client.Do(req)
+}
+
+func (g *gcsBlobStore) Exists(ctx context.Context, key string) (bool, error) {
+	resp, err := g.do(ctx, http.MethodGet, g.objectURL(key), nil, 0)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, httpStatusError(resp)
+	}
+}
+
+func (g *gcsBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, _ v1.Hash) error {
+	// if reading r fails the request is aborted, and no object is created
+	//
+	// GCS creates the object as soon as it has received size bytes, but a
+	// verifyingReader only fails at the end of the content, so the last byte
+	// is withheld until r has been read to the end, see withholdingReader
+	//
+	// GCS does not support our digests, but returns the CRC32C of the object,
+	// which is compared with the content we sent to detect corruption in transit
+	crc := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	body := &withholdingReader{r: io.TeeReader(r, crc), remaining: size}
+	u := g.endpoint + "/upload/storage/v1/b/" + url.PathEscape(g.bucket) + "/o?uploadType=media&name=" + url.QueryEscape(key)
+	resp, err := g.do(ctx, http.MethodPost, u, body, size)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return httpStatusError(resp)
+	}
+	object := struct {
+		CRC32C string `json:"crc32c"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&object); err != nil {
+		return err
+	}
+	if expected := base64.StdEncoding.EncodeToString(crc.Sum(nil)); object.CRC32C != expected {
+		// the corrupted object must not be served
+		if err := g.Delete(ctx, key); err != nil {
+			klog.Errorf("Failed to delete corrupted object %s: %v", key, err)
+		}
+		return fmt.Errorf("CRC32C mismatch uploading %s: expected %s, got %s", key, expected, object.CRC32C)
+	}
+	return nil
+}
+
+// withholdingReader reads remaining bytes from r, but only returns the last
+// byte once r has been read to the end without error
+type withholdingReader struct {
+	r io.Reader
+	// remaining is the number of bytes not returned yet
+	remaining int64
+	done      bool
+}
+
+func (w *withholdingReader) Read(p []byte) (int, error) {
+	if w.done {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if w.remaining > 1 {
+		n, err := w.r.Read(p[:min(int64(len(p)), w.remaining-1)])
+		w.remaining -= int64(n)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, err
+	}
+	// read the rest of r, which fails here if the content does not match
+	rest, err := io.ReadAll(io.LimitReader(w.r, w.remaining+1))
+	if err != nil {
+		return 0, err
+	}
+	if int64(len(rest)) != w.remaining {
+		return 0, fmt.Errorf("content does not match its size, expected %d more bytes", w.remaining)
+	}
+	w.done = true
+	return copy(p, rest), nil
+}
+
+func (g *gcsBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := g.do(ctx, http.MethodGet, g.objectURL(key)+"?alt=media", nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, errBlobNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, httpStatusError(resp)
+	}
+}
+
+func (g *gcsBlobStore) List(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	pageToken := ""
+	for {
+		q := url.Values{}
+		q.Set("prefix", prefix)
+		q.Set("fields", "items(name),nextPageToken")
+		if pageToken != "" {
+			q.Set("pageToken", pageToken)
+		}
+		u := g.endpoint + "/storage/v1/b/" + url.PathEscape(g.bucket) + "/o?" + q.Encode()
+		page, err := g.listPage(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			keys = append(keys, item.Name)
+		}
+		if page.NextPageToken == "" {
+			return keys, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+type gcsListPage struct {
+	Items []struct {
+		Name string `json:"name"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func (g *gcsBlobStore) listPage(ctx context.Context, u string) (*gcsListPage, error) {
+	resp, err := g.do(ctx, http.MethodGet, u, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, httpStatusError(resp)
+	}
+	page := &gcsListPage{}
+	if err := json.NewDecoder(resp.Body).Decode(page); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (g *gcsBlobStore) Delete(ctx context.Context, key string) error {
+	resp, err := g.do(ctx, http.MethodDelete, g.objectURL(key), nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return httpStatusError(resp)
+	}
+	return nil
+}
@@ -0,0 +1,358 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// fakeGCS is a minimal fake of the GCS JSON API for a single bucket
+type fakeGCS struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	// status, if set, is returned for every request
+	status int
+	// badResponse is returned instead of uploaded objects and listings
+	badResponse string
+	// deleteStatus, if set, is returned for deletes
+	deleteStatus int
+}
+
+func newFakeGCS(t *testing.T) (*fakeGCS, *gcsBlobStore) {
+	t.Helper()
+	fake := &fakeGCS{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, &gcsBlobStore{bucket: "bucket", client: server.Client(), endpoint: server.URL}
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status != 0 {
+		http.Error(w, "injected failure", f.status)
+		return
+	}
+	const objects = "/storage/v1/b/bucket/o"
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/upload"+objects:
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+		key := r.URL.Query().Get("name")
+		f.objects[key] = b
+		if f.badResponse != "" {
+			_, _ = w.Write([]byte(f.badResponse))
+			return
+		}
+		crc := crc32.Checksum(b, crc32.MakeTable(crc32.Castagnoli))
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"name":   key,
+			"crc32c": base64.StdEncoding.EncodeToString(binary.BigEndian.AppendUint32(nil, crc)),
+		})
+	case r.Method == http.MethodGet && r.URL.Path == objects:
+		if f.badResponse != "" {
+			_, _ = w.Write([]byte(f.badResponse))
+			return
+		}
+		// list one key per page to exercise paging
+		keys := []string{}
+		for key := range f.objects {
+			if strings.HasPrefix(key, r.URL.Query().Get("prefix")) && key > r.URL.Query().Get("pageToken") {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		page := gcsListPage{}
+		if len(keys) != 0 {
+			page.Items = append(page.Items, struct {
+				Name string `json:"name"`
+			}{Name: keys[0]})
+		}
+		if len(keys) > 1 {
+			page.NextPageToken = keys[0]
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	case strings.HasPrefix(r.URL.Path, objects+"/"):
+		key := strings.TrimPrefix(r.URL.Path, objects+"/")
+		b, exists := f.objects[key]
+		switch {
+		case r.Method == http.MethodDelete && f.deleteStatus != 0:
+			http.Error(w, "injected failure", f.deleteStatus)
+		case !exists:
+			http.NotFound(w, r)
+		case r.Method == http.MethodDelete:
+			delete(f.objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Query().Get("alt") == "media":
+			_, _ = w.Write(b)
+		default:
+			_ = json.NewEncoder(w).Encode(map[string]string{"name": key})
+		}
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func TestGCSBlobStore(t *testing.T) {
+	ctx := context.Background()
+	fake, store := newFakeGCS(t)
+	const key = "containers/images/sha256:aaaa"
+
+	if exists, err := store.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("expected key not to exist, got %v, %v", exists, err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, errBlobNotFound) {
+		t.Fatalf("expected errBlobNotFound but got: %v", err)
+	}
+	if err := store.Put(ctx, key, strings.NewReader("content"), 7, v1.Hash{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exists, err := store.Exists(ctx, key); err != nil || !exists {
+		t.Fatalf("expected key to exist, got %v, %v", exists, err)
+	}
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(b) != "content" {
+		t.Fatalf("expected content but got %q, %v", b, err)
+	}
+
+	// failing to read the content must not create an object
+	failed := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("connection reset")))
+	if err := store.Put(ctx, "containers/images/sha256:bbbb", failed, 14, v1.Hash{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, exists := fake.objects["containers/images/sha256:bbbb"]; exists {
+		t.Fatal("expected no object to be created")
+	}
+
+	for _, k := range []string{"containers/images/sha256:cccc", "geranos/uploaded-images/sha256:dddd"} {
+		if err := store.Put(ctx, k, strings.NewReader(k), int64(len(k)), v1.Hash{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	keys, err := store.List(ctx, blobKeyPrefix)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{key, "containers/images/sha256:cccc"}; !slices.Equal(keys, expected) {
+		t.Fatalf("expected keys %v but got %v", expected, keys)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("expected deleting a missing key to succeed, got: %v", err)
+	}
+	if exists, err := store.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("expected key not to exist, got %v, %v", exists, err)
+	}
+}
+
+func TestGCSBlobStoreCorruptUpload(t *testing.T) {
+	ctx := context.Background()
+	const key = "containers/images/sha256:aaaa"
+	testCases := []struct {
+		Name         string
+		Response     string
+		DeleteStatus int
+		// ExpectedDeleted is true if the corrupted object must be deleted
+		ExpectedDeleted bool
+	}{
+		{
+			Name:            "checksum mismatch",
+			Response:        `{"crc32c":"AAAAAA=="}`,
+			ExpectedDeleted: true,
+		},
+		{
+			Name:         "checksum mismatch and delete fails",
+			Response:     `{"crc32c":"AAAAAA=="}`,
+			DeleteStatus: http.StatusForbidden,
+		},
+		{
+			Name:     "invalid response",
+			Response: `{`,
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			fake, store := newFakeGCS(t)
+			fake.badResponse = tc.Response
+			fake.deleteStatus = tc.DeleteStatus
+			if err := store.Put(ctx, key, strings.NewReader("content"), 7, v1.Hash{}); err == nil {
+				t.Fatal("expected error")
+			}
+			if _, exists := fake.objects[key]; exists == tc.ExpectedDeleted {
+				t.Fatalf("expected object deleted: %v, but got exists: %v", tc.ExpectedDeleted, exists)
+			}
+		})
+	}
+}
+
+func TestGCSBlobStoreUnverifiedContent(t *testing.T) {
+	ctx := context.Background()
+	const key = "containers/images/sha256:aaaa"
+	content := []byte(strings.Repeat("a", 100000))
+	testCases := []struct {
+		Name    string
+		Content io.Reader
+		Size    int64
+	}{
+		{
+			// a verifyingReader only fails after reading all of the content
+			Name:    "digest mismatch",
+			Content: verifiedContent(t, content, []byte("something else")),
+			Size:    int64(len(content)),
+		},
+		{
+			Name:    "content shorter than size",
+			Content: bytes.NewReader(content),
+			Size:    int64(len(content)) + 10,
+		},
+		{
+			Name:    "content one byte shorter than size",
+			Content: bytes.NewReader(content),
+			Size:    int64(len(content)) + 1,
+		},
+		{
+			Name:    "content longer than size",
+			Content: bytes.NewReader(content),
+			Size:    int64(len(content)) - 1,
+		},
+		{
+			Name:    "empty content with digest mismatch",
+			Content: verifiedContent(t, nil, []byte("something else")),
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			fake, store := newFakeGCS(t)
+			if err := store.Put(ctx, key, tc.Content, tc.Size, v1.Hash{}); err == nil {
+				t.Fatal("expected error")
+			}
+			if exists, err := store.Exists(ctx, key); err != nil || exists {
+				t.Fatalf("expected no object to be created, got %v, %v", exists, err)
+			}
+			if _, exists := fake.objects[key]; exists {
+				t.Fatal("expected no object to be created")
+			}
+		})
+	}
+}
+
+func TestWithholdingReader(t *testing.T) {
+	r := &withholdingReader{r: strings.NewReader("content"), remaining: 7}
+	b := make([]byte, 7)
+	if n, err := io.ReadFull(r, b[:6]); err != nil || string(b[:n]) != "conten" {
+		t.Fatalf("expected all but the last byte, got %q, %v", b[:n], err)
+	}
+	// empty reads do not consume the last byte
+	if n, err := r.Read(nil); n != 0 || err != nil {
+		t.Fatalf("expected empty read, got %d, %v", n, err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil || string(b) != "t" {
+		t.Fatalf("expected the last byte, got %q, %v", b, err)
+	}
+}
+
+func TestGCSBlobStoreErrors(t *testing.T) {
+	ctx := context.Background()
+	fake, failing := newFakeGCS(t)
+	fake.status = http.StatusInternalServerError
+	_, unreachable := newFakeGCS(t)
+	unreachable.client = &http.Client{Transport: failingRoundTripper{}}
+	invalid := &gcsBlobStore{bucket: "bucket", client: http.DefaultClient, endpoint: "http://\x7f"}
+	for _, store := range []*gcsBlobStore{failing, unreachable, invalid} {
+		if _, err := store.Exists(ctx, "key"); err == nil {
+			t.Errorf("%s: expected Exists to fail", store.endpoint)
+		}
+		if err := store.Put(ctx, "key", strings.NewReader("content"), 7, v1.Hash{}); err == nil {
+			t.Errorf("%s: expected Put to fail", store.endpoint)
+		}
+		if _, err := store.Get(ctx, "key"); err == nil {
+			t.Errorf("%s: expected Get to fail", store.endpoint)
+		}
+		if _, err := store.List(ctx, "prefix"); err == nil {
+			t.Errorf("%s: expected List to fail", store.endpoint)
+		}
+		if err := store.Delete(ctx, "key"); err == nil {
+			t.Errorf("%s: expected Delete to fail", store.endpoint)
+		}
+	}
+	fake, store := newFakeGCS(t)
+	fake.badResponse = "{"
+	if _, err := store.List(ctx, "prefix"); err == nil {
+		t.Error("expected List to fail with an invalid response")
+	}
+}
+
+// failingRoundTripper fails every request
+type failingRoundTripper struct{}
+
+func (failingRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestNewGCSBlobStore(t *testing.T) {
+	ctx := context.Background()
+	// dry runs do not require credentials
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := newGCSBlobStore(ctx, "bucket", true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := newGCSBlobStore(ctx, "bucket", false); err == nil {
+		t.Fatal("expected error without credentials")
+	}
+	credentials := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(credentials, []byte(`{"type":"authorized_user","client_id":"id","client_secret":"secret","refresh_token":"token"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", credentials)
+	if _, err := newGCSBlobStore(ctx, "bucket", false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
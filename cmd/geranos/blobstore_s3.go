@@ -0,0 +1,166 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// s3BlobStore is a BlobStore backed by an S3 bucket
+type s3BlobStore struct {
+	bucket    string
+	svc       *s3.Client
+	uploader  *manager.Uploader
+	multipart *multipartUploader
+}
+
+// newS3BlobStore creates an s3BlobStore, blobs larger than partSize are
+// uploaded in resumable multipart uploads with up to concurrency parts at once
+func newS3BlobStore(ctx context.Context, bucket string, dryRun bool, partSize int64, concurrency int) (*s3BlobStore, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		// Use anonymous credentials for dry run
+		cfg.Credentials = aws.AnonymousCredentials{}
+	}
+	return newS3BlobStoreWithClient(s3.NewFromConfig(cfg), bucket, partSize, concurrency), nil
+}
+
+func newS3BlobStoreWithClient(client *s3.Client, bucket string, partSize int64, concurrency int) *s3BlobStore {
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		u.PartSize = partSize
+		u.Concurrency = concurrency
+	})
+	return &s3BlobStore{
+		bucket:   bucket,
+		svc:      client,
+		uploader: uploader,
+		multipart: &multipartUploader{
+			client:      client,
+			partSize:    partSize,
+			concurrency: concurrency,
+		},
+	}
+}
+
+// AbortAbandonedUploads aborts incomplete blob uploads which were initiated
+// more than olderThan ago, see multipartUploader
+func (s *s3BlobStore) AbortAbandonedUploads(ctx context.Context, olderThan time.Duration) error {
+	return s.multipart.AbortAbandoned(ctx, s.bucket, blobKeyPrefix, olderThan)
+}
+
+func (s *s3BlobStore) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.svc.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *s3BlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, digest v1.Hash) error {
+	if size > s.multipart.partSize {
+		// large blobs are uploaded in parts, which can be resumed if this
+		// or a later run retries the upload
+		return s.multipart.Upload(ctx, s.bucket, key, r)
+	}
+	// S3 will also verify the checksum, but only supports sha256
+	checksum, err := s3ChecksumSHA256(digest)
+	if err != nil {
+		return err
+	}
+	_, err = s.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:         aws.String(s.bucket),
+		Key:            aws.String(key),
+		Body:           r,
+		ChecksumSHA256: checksum,
+	})
+	return err
+}
+
+func (s *s3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.svc.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) || isS3NotFound(err) {
+			return nil, errBlobNotFound
+		}
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *s3BlobStore) List(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	paginator := s3.NewListObjectsV2Paginator(s.svc, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+	return keys, nil
+}
+
+func (s *s3BlobStore) Delete(ctx context.Context, key string) error {
+	// S3 does not return an error deleting keys that do not exist
+	_, err := s.svc.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func isS3NotFound(err error) bool {
+	var notFound *types.NotFound
+	var apiErr smithy.APIError
+	if errors.As(err, &notFound) {
+		return true
+	} else if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode() == "NotFound"
+	}
+	return false
+}
@@ -0,0 +1,275 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// fakeS3 is a minimal fake of the S3 REST API for a single bucket
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	// status, if set, is returned for every request
+	status int
+	// notFoundCode is the error code for missing objects
+	notFoundCode string
+}
+
+// newFakeS3 returns a fake and an s3BlobStore using it, blobs larger than
+// 100 bytes are uploaded with the multipart uploader
+func newFakeS3(t *testing.T) (*fakeS3, *s3BlobStore) {
+	t.Helper()
+	fake := &fakeS3{objects: map[string][]byte{}, notFoundCode: "NoSuchKey"}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	client := s3.New(s3.Options{
+		BaseEndpoint:     aws.String(server.URL),
+		UsePathStyle:     true,
+		Region:           "us-east-1",
+		Credentials:      aws.AnonymousCredentials{},
+		HTTPClient:       server.Client(),
+		RetryMaxAttempts: 1,
+	})
+	// manager.Uploader requires parts of at least 5 MiB
+	store := newS3BlobStoreWithClient(client, "bucket", 5<<20, 2)
+	store.multipart.partSize = 100
+	return fake, store
+}
+
+func (f *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status != 0 {
+		f.error(w, f.status, "InternalError")
+		return
+	}
+	if r.URL.Path == "/bucket" || r.URL.Path == "/bucket/" {
+		f.list(w, r)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	b, exists := f.objects[key]
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+		sum := sha256.Sum256(body)
+		if checksum := r.Header.Get("x-amz-checksum-sha256"); checksum != "" && checksum != base64.StdEncoding.EncodeToString(sum[:]) {
+			f.error(w, http.StatusBadRequest, "BadDigest")
+			return
+		}
+		f.objects[key] = body
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodHead:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(b)))
+	default:
+		if !exists {
+			f.error(w, http.StatusNotFound, f.notFoundCode)
+			return
+		}
+		_, _ = w.Write(b)
+	}
+}
+
+// fakeS3ListResult is the subset of the ListObjectsV2 response we use
+type fakeS3ListResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Contents              []struct{ Key string }
+	IsTruncated           bool
+	NextContinuationToken string `xml:",omitempty"`
+}
+
+// list lists one object per page to exercise paging
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	keys := []string{}
+	for key := range f.objects {
+		if strings.HasPrefix(key, r.URL.Query().Get("prefix")) && key > r.URL.Query().Get("continuation-token") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	result := fakeS3ListResult{}
+	if len(keys) != 0 {
+		result.Contents = append(result.Contents, struct{ Key string }{Key: keys[0]})
+	}
+	if len(keys) > 1 {
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[0]
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(result)
+}
+
+func TestS3BlobStore(t *testing.T) {
+	ctx := context.Background()
+	fake, store := newFakeS3(t)
+	const key = "containers/images/sha256:aaaa"
+	content := "content"
+	sum := sha256.Sum256([]byte(content))
+	digest := v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(sum[:])}
+
+	if exists, err := store.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("expected key not to exist, got %v, %v", exists, err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, errBlobNotFound) {
+		t.Fatalf("expected errBlobNotFound but got: %v", err)
+	}
+	if err := store.Put(ctx, key, strings.NewReader(content), int64(len(content)), digest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exists, err := store.Exists(ctx, key); err != nil || !exists {
+		t.Fatalf("expected key to exist, got %v, %v", exists, err)
+	}
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(b) != content {
+		t.Fatalf("expected content but got %q, %v", b, err)
+	}
+
+	// S3 verifies the content against the digest
+	if err := store.Put(ctx, "containers/images/sha256:bbbb", strings.NewReader("corrupted"), 9, digest); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, exists := fake.objects["containers/images/sha256:bbbb"]; exists {
+		t.Fatal("expected no object to be created")
+	}
+	if err := store.Put(ctx, "containers/images/sha256:bbbb", strings.NewReader(content), 7, v1.Hash{Algorithm: "sha256", Hex: "invalid"}); err == nil {
+		t.Fatal("expected error for invalid digest")
+	}
+
+	// large blobs are uploaded in parts
+	multipart := newFakeMultipartS3()
+	store.multipart.client = multipart
+	large := strings.Repeat("0123456789", 15)
+	if err := store.Put(ctx, "containers/images/sha256:cccc", verifiedContent(t, []byte(large), []byte(large)), int64(len(large)), v1.Hash{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(multipart.objects["containers/images/sha256:cccc"]) != large || multipart.partUploads != 2 {
+		t.Fatalf("expected large blob to be uploaded in 2 parts, got %d", multipart.partUploads)
+	}
+	multipart.uploads["old"] = &fakeUpload{key: "containers/images/sha256:old", initiated: time.Now().Add(-48 * time.Hour)}
+	if err := store.AbortAbandonedUploads(ctx, 24*time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(multipart.uploads) != 0 {
+		t.Fatalf("expected abandoned upload to be aborted")
+	}
+
+	if err := store.Put(ctx, "geranos/uploaded-images/sha256:dddd", strings.NewReader(content), 7, v1.Hash{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Put(ctx, "containers/images/sha256:eeee", strings.NewReader(content), 7, v1.Hash{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keys, err := store.List(ctx, blobKeyPrefix)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{key, "containers/images/sha256:eeee"}; !slices.Equal(keys, expected) {
+		t.Fatalf("expected keys %v but got %v", expected, keys)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exists, err := store.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("expected key not to exist, got %v, %v", exists, err)
+	}
+	// some S3 compatible stores return NotFound instead of NoSuchKey
+	fake.notFoundCode = "NotFound"
+	if _, err := store.Get(ctx, key); !errors.Is(err, errBlobNotFound) {
+		t.Fatalf("expected errBlobNotFound but got: %v", err)
+	}
+}
+
+func TestS3BlobStoreErrors(t *testing.T) {
+	ctx := context.Background()
+	fake, store := newFakeS3(t)
+	fake.status = http.StatusForbidden
+	if _, err := store.Exists(ctx, "key"); err == nil {
+		t.Error("expected Exists to fail")
+	}
+	if err := store.Put(ctx, "key", strings.NewReader("content"), 7, v1.Hash{}); err == nil {
+		t.Error("expected Put to fail")
+	}
+	if _, err := store.Get(ctx, "key"); err == nil {
+		t.Error("expected Get to fail")
+	}
+	if _, err := store.List(ctx, "prefix"); err == nil {
+		t.Error("expected List to fail")
+	}
+	if err := store.Delete(ctx, "key"); err == nil {
+		t.Error("expected Delete to fail")
+	}
+	if isS3NotFound(errors.New("connection refused")) {
+		t.Error("expected other errors not to be not found")
+	}
+}
+
+func TestNewS3BlobStore(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_REGION", "us-east-1")
+	if _, err := newS3BlobStore(ctx, "bucket", true, 100, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Setenv("AWS_PROFILE", "missing")
+	if _, err := newS3BlobStore(ctx, "bucket", false, 100, 2); err == nil {
+		t.Fatal("expected error for missing profile")
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewBlobStore(t *testing.T) {
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_REGION", "us-east-1")
+	opts := blobStoreOptions{dryRun: true, partSize: 5 << 20, concurrency: 2}
+	testCases := []struct {
+		Destination  string
+		ExpectedType reflect.Type
+		ExpectError  bool
+	}{
+		{Destination: "s3://bucket", ExpectedType: reflect.TypeFor[*s3BlobStore]()},
+		{Destination: "gs://bucket", ExpectedType: reflect.TypeFor[*gcsBlobStore]()},
+		{Destination: "azblob://account/container", ExpectedType: reflect.TypeFor[*azureBlobStore]()},
+		{Destination: "file:///tmp/mirror", ExpectedType: reflect.TypeFor[*fsBlobStore]()},
+		{Destination: "azblob://account", ExpectError: true},
+		{Destination: "azblob://account/", ExpectError: true},
+		{Destination: "ftp://host", ExpectError: true},
+		{Destination: "bucket", ExpectError: true},
+		{Destination: "s3://", ExpectError: true},
+	}
+	for _, tc := range testCases {
+		store, err := newBlobStore(context.Background(), tc.Destination, opts)
+		if tc.ExpectError {
+			if err == nil {
+				t.Errorf("%s: expected error but got none", tc.Destination)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.Destination, err)
+		} else if reflect.TypeOf(store) != tc.ExpectedType {
+			t.Errorf("%s: expected %v but got %T", tc.Destination, tc.ExpectedType, store)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto"
+	_ "crypto/sha256" // register digest algorithms
+	_ "crypto/sha512"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// digestAlgorithms are the digest algorithms registered in the OCI image spec
+//
+// NOTE: go-containerregistry only parses sha256 digests (v1.NewHash and
+// name.NewDigest), and cannot fetch content addressed by any other
+// algorithm, so only sha256 manifests and blobs reach the uploader today.
+// Other manifests fail with errUnsupportedDigest, see isFetchableDigest.
+var digestAlgorithms = map[string]crypto.Hash{
+	"sha256": crypto.SHA256,
+	"sha512": crypto.SHA512,
+}
+
+// isFetchableDigest returns true if go-containerregistry can fetch content
+// addressed by digest, i.e. it is a sha256 digest
+func isFetchableDigest(digest string) bool {
+	return strings.HasPrefix(digest, "sha256:")
+}
+
+// errUnsupportedDigest is returned for manifests which cannot be mirrored
+// because they are not addressed by a sha256 digest
+var errUnsupportedDigest = errors.New("unsupported digest algorithm, only sha256 content can be mirrored")
+
+// errDigestMismatch is returned when content does not match the expected digest
+var errDigestMismatch = errors.New("content does not match digest")
+
+// verifyingReader hashes content as it is read, and returns an error instead
+// of io.EOF if the content does not match the expected digest
+//
+// This allows aborting an upload of corrupted content after streaming it,
+// without buffering the content first.
+type verifyingReader struct {
+	r         io.Reader
+	algorithm crypto.Hash
+	hash      hash.Hash
+	expected  v1.Hash
+	// open opens the content at an offset, r is opened lazily
+	// from the start unless ResumeAt is called first
+	open func(offset int64) (io.ReadCloser, error)
+	// opened is the reader returned by open, if any
+	opened io.Closer
+}
+
+// newResumableVerifyingReader returns a verifyingReader for the content
+// opened by open, which allows skipping content with ResumeAt
+//
+// The caller must Close the reader.
+func newResumableVerifyingReader(open func(offset int64) (io.ReadCloser, error), expected v1.Hash) (*verifyingReader, error) {
+	h, ok := digestAlgorithms[expected.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported digest algorithm: %q", expected.Algorithm)
+	}
+	return &verifyingReader{algorithm: h, hash: h.New(), expected: expected, open: open}, nil
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	if v.r == nil {
+		r, err := v.open(0)
+		if err != nil {
+			return 0, err
+		}
+		v.r, v.opened = r, r
+	}
+	n, err := v.r.Read(p)
+	// hash.Hash.Write never returns an error
+	_, _ = v.hash.Write(p[:n])
+	if err == io.EOF {
+		if actual := hex.EncodeToString(v.hash.Sum(nil)); actual != v.expected.Hex {
+			return n, fmt.Errorf("%w: expected %s, got %s:%s", errDigestMismatch, v.expected, v.expected.Algorithm, actual)
+		}
+	}
+	return n, err
+}
+
+// HashState returns the state of the hash of the content read so far,
+// which may be passed to ResumeAt by a later reader of the same content
+func (v *verifyingReader) HashState() []byte {
+	// all of digestAlgorithms implement encoding.BinaryMarshaler,
+	// and never fail to marshal
+	state, _ := v.hash.(encoding.BinaryMarshaler).MarshalBinary()
+	return state
+}
+
+// ResumeAt skips to offset without reading the content before it, restoring
+// hashState from HashState at offset, it must be called before Read
+//
+// If ResumeAt fails the reader is unchanged.
+func (v *verifyingReader) ResumeAt(offset int64, hashState []byte) error {
+	if v.r != nil {
+		return errors.New("reader cannot be resumed")
+	}
+	h := v.algorithm.New()
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(hashState); err != nil {
+		return err
+	}
+	r, err := v.open(offset)
+	if err != nil {
+		return err
+	}
+	v.r, v.opened, v.hash = r, r, h
+	return nil
+}
+
+// Close closes the content if it was opened by the reader
+func (v *verifyingReader) Close() error {
+	if v.opened == nil {
+		return nil
+	}
+	return v.opened.Close()
+}
+
+// s3ChecksumSHA256 returns the S3 ChecksumSHA256 for digest, or nil if digest
+// is not sha256, S3 does not support any of the other digestAlgorithms
+func s3ChecksumSHA256(digest v1.Hash) (*string, error) {
+	if digest.Algorithm != "sha256" {
+		return nil, nil
+	}
+	b, err := hex.DecodeString(digest.Hex)
+	if err != nil {
+		return nil, err
+	}
+	checksum := base64.StdEncoding.EncodeToString(b)
+	return &checksum, nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// testDigest returns the digest of content with algorithm
+func testDigest(algorithm string, content []byte) v1.Hash {
+	h := digestAlgorithms[algorithm].New()
+	_, _ = h.Write(content)
+	return v1.Hash{Algorithm: algorithm, Hex: hex.EncodeToString(h.Sum(nil))}
+}
+
+// openContent opens content at an offset, for newResumableVerifyingReader
+func openContent(content []byte) func(offset int64) (io.ReadCloser, error) {
+	return func(offset int64) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(content[offset:])), nil
+	}
+}
+
+func TestIsFetchableDigest(t *testing.T) {
+	if !isFetchableDigest(testDigest("sha256", []byte("content")).String()) {
+		t.Error("expected sha256 digest to be fetchable")
+	}
+	if isFetchableDigest(testDigest("sha512", []byte("content")).String()) {
+		t.Error("expected sha512 digest not to be fetchable")
+	}
+}
+
+func TestVerifyingReader(t *testing.T) {
+	content := []byte("content")
+	digest := testDigest("sha512", content)
+	// matching content reads through to io.EOF
+	r, err := newResumableVerifyingReader(openContent(content), digest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(b, content) {
+		t.Fatalf("expected %q but got %q", content, b)
+	}
+	// mismatched content fails at the end
+	r, err = newResumableVerifyingReader(openContent([]byte("corrupted")), digest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := io.ReadAll(r); !errors.Is(err, errDigestMismatch) {
+		t.Fatalf("expected digest mismatch error but got: %v", err)
+	}
+}
+
+func TestVerifyingReaderUnsupportedAlgorithm(t *testing.T) {
+	digest := v1.Hash{Algorithm: "md5", Hex: "9a0364b9e99bb480dd25e1f0284c8555"}
+	if _, err := newResumableVerifyingReader(nil, digest); err == nil {
+		t.Fatal("expected error for unsupported digest algorithm")
+	}
+}
+
+func TestResumableVerifyingReader(t *testing.T) {
+	content := []byte("0123456789")
+	digest := testDigest("sha256", content)
+	open := openContent(content)
+
+	// read part of the content and save the hash state
+	r, err := newResumableVerifyingReader(open, digest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := io.ReadFull(r, make([]byte, 4)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hashState := r.HashState()
+	// the content was already opened
+	if err := r.ResumeAt(4, hashState); err == nil {
+		t.Fatal("expected error resuming a reader after reading it")
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// another reader resumes from the saved state and verifies the rest
+	r, err = newResumableVerifyingReader(open, digest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.ResumeAt(4, []byte("bogus")); err == nil {
+		t.Fatal("expected error resuming with an invalid hash state")
+	}
+	if err := r.ResumeAt(4, hashState); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil || string(b) != "456789" {
+		t.Fatalf("expected the rest of the content, got %q, %v", b, err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// a reader that was never opened has nothing to close
+	r, err = newResumableVerifyingReader(open, digest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// failing to open the content fails the read, or the resume
+	failed := errors.New("failed")
+	r, err = newResumableVerifyingReader(func(int64) (io.ReadCloser, error) { return nil, failed }, digest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.ResumeAt(4, hashState); !errors.Is(err, failed) {
+		t.Fatalf("expected error resuming but got: %v", err)
+	}
+	if _, err := r.Read(make([]byte, 1)); !errors.Is(err, failed) {
+		t.Fatalf("expected error reading but got: %v", err)
+	}
+}
+
+func TestS3ChecksumSHA256(t *testing.T) {
+	checksum, err := s3ChecksumSHA256(testDigest("sha256", []byte("content")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// base64 of sha256("content")
+	if expected := "7XACtDnprIRfIjV9giusFERzD722AW0+yUMil7nsn3M="; checksum == nil || *checksum != expected {
+		t.Fatalf("expected checksum %q but got %v", expected, checksum)
+	}
+	if checksum, err := s3ChecksumSHA256(testDigest("sha512", []byte("content"))); err != nil || checksum != nil {
+		t.Fatalf("expected no checksum for sha512 but got %v, %v", checksum, err)
+	}
+	if _, err := s3ChecksumSHA256(v1.Hash{Algorithm: "sha256", Hex: "not hex"}); err == nil {
+		t.Fatal("expected error for invalid hex")
+	}
+}
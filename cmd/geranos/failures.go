@@ -0,0 +1,178 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	"k8s.io/klog/v2"
+)
+
+// imageFailures retries failures walking individual images and, in
+// continue-on-error mode, collects them instead of aborting the whole run
+//
+// A single malformed manifest or missing layer should not prevent mirroring
+// every other image, but we still want to know about it.
+//
+// All methods are safe for concurrent use. A nil *imageFailures runs each
+// operation once and returns any error, aborting the run.
+type imageFailures struct {
+	// continueOnError records failures and continues instead of returning them
+	continueOnError bool
+	// attempts is the maximum number of attempts for transient errors
+	attempts int
+	// backoff is the delay before the first retry, doubled for each retry
+	backoff time.Duration
+	// progress, if set, is updated with the number of failures
+	progress *progress
+
+	mu       sync.Mutex
+	failures []imageFailure
+}
+
+// imageFailure is a single failure in the JSON failure report
+type imageFailure struct {
+	Reference string `json:"reference"`
+	Error     string `json:"error"`
+	Attempts  int    `json:"attempts"`
+	Transient bool   `json:"transient"`
+}
+
+// failureReport is the JSON failure report
+type failureReport struct {
+	Failures []imageFailure `json:"failures"`
+}
+
+// errPartialFailure is returned when the run completed, but failed to
+// mirror some images
+var errPartialFailure = errors.New("failed to mirror some images")
+
+// Do calls fn for the image at ref, retrying transient errors with backoff
+func (f *imageFailures) Do(ref fmt.Stringer, fn func() error) error {
+	if f == nil {
+		return fn()
+	}
+	attempts := max(f.attempts, 1)
+	backoff := f.backoff
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if !isTransient(err) {
+			return f.record(ref, err, attempt)
+		}
+		if attempt < attempts {
+			klog.Warningf("Retrying %s after transient error in %s: %v", ref, backoff, err)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	return f.record(ref, err, attempts)
+}
+
+// Record records a failure for ref that cannot be retried
+func (f *imageFailures) Record(ref fmt.Stringer, err error) error {
+	if f == nil || err == nil {
+		return err
+	}
+	return f.record(ref, err, 1)
+}
+
+func (f *imageFailures) record(ref fmt.Stringer, err error, attempts int) error {
+	f.progress.Error()
+	if !f.continueOnError {
+		return err
+	}
+	klog.Errorf("Failed to mirror %s after %d attempt(s), continuing: %v", ref, attempts, err)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = append(f.failures, imageFailure{
+		Reference: ref.String(),
+		Error:     err.Error(),
+		Attempts:  attempts,
+		Transient: isTransient(err),
+	})
+	return nil
+}
+
+// Err returns errPartialFailure if any failures were collected
+func (f *imageFailures) Err() error {
+	if len(f.Failures()) != 0 {
+		return errPartialFailure
+	}
+	return nil
+}
+
+// Failures returns the collected failures, sorted by reference
+func (f *imageFailures) Failures() []imageFailure {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	failures := append([]imageFailure{}, f.failures...)
+	sort.SliceStable(failures, func(i, j int) bool {
+		return failures[i].Reference < failures[j].Reference
+	})
+	return failures
+}
+
+// WriteReport writes a JSON report of the collected failures to w
+func (f *imageFailures) WriteReport(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(failureReport{Failures: f.Failures()})
+}
+
+// WriteReportFile writes the JSON failure report to path
+func (f *imageFailures) WriteReportFile(path string) error {
+	// #nosec G304 -- path is trusted configuration
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := f.WriteReport(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// isTransient returns true for errors that may succeed if retried
+func isTransient(err error) bool {
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		return terr.Temporary()
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+type stringer string
+
+func (s stringer) String() string { return string(s) }
+
+func TestIsTransient(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Err      error
+		Expected bool
+	}{
+		{Name: "registry 503", Err: &transport.Error{StatusCode: http.StatusServiceUnavailable}, Expected: true},
+		{Name: "registry 429", Err: fmt.Errorf("wrapped: %w", &transport.Error{StatusCode: http.StatusTooManyRequests}), Expected: true},
+		{Name: "registry 404", Err: &transport.Error{StatusCode: http.StatusNotFound}, Expected: false},
+		{Name: "connection reset", Err: fmt.Errorf("read: %w", syscall.ECONNRESET), Expected: true},
+		{Name: "unexpected EOF", Err: io.ErrUnexpectedEOF, Expected: true},
+		{Name: "timeout", Err: os.ErrDeadlineExceeded, Expected: true},
+		{Name: "other", Err: errors.New("malformed manifest"), Expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			if got := isTransient(tc.Err); got != tc.Expected {
+				t.Fatalf("expected isTransient(%v) to be %v", tc.Err, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestImageFailuresDo(t *testing.T) {
+	transient := &transport.Error{StatusCode: http.StatusServiceUnavailable}
+	permanent := errors.New("malformed manifest")
+	testCases := []struct {
+		Name             string
+		ContinueOnError  bool
+		Errors           []error
+		ExpectedCalls    int
+		ExpectedErr      error
+		ExpectedFailures int
+	}{
+		{Name: "success", Errors: []error{nil}, ExpectedCalls: 1},
+		{Name: "retried transient error", Errors: []error{transient, transient, nil}, ExpectedCalls: 3},
+		{Name: "transient error exhausts retries", Errors: []error{transient, transient, transient}, ExpectedCalls: 3, ExpectedErr: transient},
+		{Name: "permanent error is not retried", Errors: []error{permanent}, ExpectedCalls: 1, ExpectedErr: permanent},
+		{Name: "continue on permanent error", ContinueOnError: true, Errors: []error{permanent}, ExpectedCalls: 1, ExpectedFailures: 1},
+		{Name: "continue on transient error", ContinueOnError: true, Errors: []error{transient, transient, transient}, ExpectedCalls: 3, ExpectedFailures: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			p := newProgress()
+			f := &imageFailures{continueOnError: tc.ContinueOnError, attempts: 3, progress: p}
+			calls := 0
+			err := f.Do(stringer("example.com/foo@sha256:abc"), func() error {
+				calls++
+				return tc.Errors[calls-1]
+			})
+			if !errors.Is(err, tc.ExpectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.ExpectedErr, err)
+			}
+			if calls != tc.ExpectedCalls {
+				t.Fatalf("expected %d calls, got %d", tc.ExpectedCalls, calls)
+			}
+			if n := len(f.Failures()); n != tc.ExpectedFailures {
+				t.Fatalf("expected %d failures, got %d", tc.ExpectedFailures, n)
+			}
+			if expected := tc.ExpectedErr != nil || tc.ExpectedFailures != 0; expected != (p.errors.Load() == 1) {
+				t.Fatalf("expected errors counted: %v, got %d", expected, p.errors.Load())
+			}
+			if expected := tc.ExpectedFailures != 0; expected != errors.Is(f.Err(), errPartialFailure) {
+				t.Fatalf("expected partial failure: %v, got: %v", expected, f.Err())
+			}
+		})
+	}
+}
+
+func TestImageFailuresNil(t *testing.T) {
+	var f *imageFailures
+	expected := errors.New("failed")
+	if err := f.Do(stringer("ref"), func() error { return expected }); err != expected {
+		t.Fatalf("expected error to be returned, got: %v", err)
+	}
+	if err := f.Record(stringer("ref"), expected); err != expected {
+		t.Fatalf("expected error to be returned, got: %v", err)
+	}
+	if err := f.Err(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+}
+
+func TestImageFailuresReport(t *testing.T) {
+	f := &imageFailures{continueOnError: true}
+	if err := f.Record(stringer("b"), errors.New("second")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := f.Record(stringer("a"), &transport.Error{StatusCode: http.StatusBadGateway}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := f.Record(stringer("c"), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "failures.json")
+	if err := f.WriteReportFile(path); err != nil {
+		t.Fatalf("unexpected error writing report: %v", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := failureReport{}
+	if err := json.Unmarshal(b, &report); err != nil {
+		t.Fatalf("invalid report: %v\n%s", err, b)
+	}
+	if len(report.Failures) != 2 {
+		t.Fatalf("expected two failures, got: %s", b)
+	}
+	if a := report.Failures[0]; a.Reference != "a" || !a.Transient || a.Attempts != 1 {
+		t.Errorf("unexpected first failure: %+v", a)
+	}
+	if b := report.Failures[1]; b.Reference != "b" || b.Error != "second" || b.Transient {
+		t.Errorf("unexpected second failure: %+v", b)
+	}
+	if err := f.WriteReportFile(filepath.Join(path, "not-a-dir")); err == nil {
+		t.Error("expected error writing report to a file path")
+	}
+}
+
+func TestWalkImageLayersOCIContinueOnError(t *testing.T) {
+	r := newTestRegistry(t)
+	repos, err := CatalogRepositories(http.DefaultTransport, r.root)
+	if err != nil {
+		t.Fatalf("unexpected error listing catalog: %v", err)
+	}
+	// the first image fails permanently, and the second fails once
+	failing, flaky := r.images[0], r.images[1]
+	var mu sync.Mutex
+	flaked := false
+	recorder := &imageRecorder{}
+	walk := func(ref name.Reference, layers []v1.Layer) error {
+		mu.Lock()
+		defer mu.Unlock()
+		switch ref.String() {
+		case failing:
+			return errors.New("missing layer")
+		case flaky:
+			if !flaked {
+				flaked = true
+				return &transport.Error{StatusCode: http.StatusInternalServerError}
+			}
+		}
+		return recorder.walk(ref, layers)
+	}
+	failures := &imageFailures{continueOnError: true, attempts: 2}
+	if err := WalkImageLayersOCI(http.DefaultTransport, repos, walk, noSkip, nil, failures); err != nil {
+		t.Fatalf("unexpected error walking: %v", err)
+	}
+	if got := recorder.sorted(); !slices.Equal(got, r.images[1:]) {
+		t.Fatalf("expected to walk images %v but walked %v", r.images[1:], got)
+	}
+	if got := failures.Failures(); len(got) != 1 || got[0].Reference != failing {
+		t.Fatalf("expected only %s to fail, got: %+v", failing, got)
+	}
+
+	// without continuing, the first failure is returned
+	failures = &imageFailures{attempts: 2}
+	if err := WalkImageLayersOCI(http.DefaultTransport, repos, walk, noSkip, nil, failures); err == nil {
+		t.Fatal("expected error walking")
+	}
+}
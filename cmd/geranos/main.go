@@ -17,13 +17,22 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+
 	"k8s.io/klog/v2"
 )
 
@@ -35,48 +44,199 @@ func main() {
 func Main() {
 	klog.InitFlags(flag.CommandLine)
 	flag.Parse()
-	if err := Run(os.Args); err != nil {
+	if err := Run(os.Args); errors.Is(err, errPartialFailure) {
+		// distinguish partial failure from failing entirely, see CONTINUE_ON_ERROR
+		klog.Error(err)
+		klog.Flush()
+		os.Exit(2)
+	} else if err != nil {
 		klog.Fatal(err)
 	}
 }
 
 // Run implements the actual application logic, accepting global inputs
 func Run(_ []string) error {
-	// one of the backing registries for registry.k8s.io
-	// TODO: make configurable later
-	const sourceRegistry = "us-central1-docker.pkg.dev/k8s-artifacts-prod/images"
-
-	// TODO: make configurable later
-	const s3Bucket = "prod-registry-k8s-io-us-east-2"
+	// one of the backing registries for registry.k8s.io by default
+	sourceRegistry := getEnv("SOURCE_REGISTRY", "us-central1-docker.pkg.dev/k8s-artifacts-prod/images")
+	// how to discover images in sourceRegistry, one of:
+	// - "gcp": GCR / Artifact Registry specific listing of all manifests
+	// - "oci": standard tag listing, for any other registry
+	sourceAPI := getEnv("SOURCE_API", "gcp")
+	// comma separated repositories under sourceRegistry to walk, only used
+	// with SOURCE_API=oci, if unset _catalog will be used to discover them
+	sourceRepositories := getEnv("SOURCE_REPOSITORIES", "")
+	// how often to log progress, and update METRICS_TEXTFILE / PUSHGATEWAY_URL
+	progressInterval, err := parseProgressInterval(getEnv("PROGRESS_INTERVAL", "1m"))
+	if err != nil {
+		return fmt.Errorf("invalid PROGRESS_INTERVAL: %w", err)
+	}
+	// optional address to serve Prometheus metrics on while running, e.g. ":9090"
+	metricsAddr := getEnv("METRICS_ADDR", "")
+	// optional path to write metrics to for the node_exporter textfile collector
+	metricsTextfile := getEnv("METRICS_TEXTFILE", "")
+	// optional Prometheus Pushgateway to push metrics to, e.g. "http://pushgateway:9091"
+	pushgatewayURL := getEnv("PUSHGATEWAY_URL", "")
+	// if "true", failures mirroring individual images are reported and the
+	// rest of the images are still mirrored, exiting 2 at the end
+	continueOnError := getEnv("CONTINUE_ON_ERROR", "") == "true"
+	// optional path to write a JSON report of images that failed to mirror
+	failureReport := getEnv("FAILURE_REPORT", "")
+	// how many times to attempt each image in case of transient errors
+	retryAttempts, err := strconv.Atoi(getEnv("RETRY_ATTEMPTS", "3"))
+	if err != nil {
+		return fmt.Errorf("invalid RETRY_ATTEMPTS: %w", err)
+	}
+	// where to mirror blobs to, one of:
+	// - s3://bucket
+	// - gs://bucket
+	// - azblob://account/container, authenticated with AZURE_STORAGE_SAS_TOKEN
+	// - file:///path/to/directory
+	destination := getEnv("DESTINATION", "s3://prod-registry-k8s-io-us-east-2")
+	// blobs larger than this many bytes are uploaded in resumable parts of this size,
+	// Azure blobs are always uploaded in blocks of this size
+	uploadPartSize, err := strconv.ParseInt(getEnv("UPLOAD_PART_SIZE", strconv.Itoa(64*1024*1024)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid UPLOAD_PART_SIZE: %w", err)
+	}
+	if uploadPartSize < manager.MinUploadPartSize {
+		return fmt.Errorf("UPLOAD_PART_SIZE must be at least %d", manager.MinUploadPartSize)
+	}
+	// how many parts of a blob to upload at once, each is buffered in memory
+	uploadConcurrency, err := strconv.Atoi(getEnv("UPLOAD_CONCURRENCY", strconv.Itoa(manager.DefaultUploadConcurrency)))
+	if err != nil {
+		return fmt.Errorf("invalid UPLOAD_CONCURRENCY: %w", err)
+	}
+	// incomplete multipart uploads older than this are aborted instead of
+	// resumed, "0" disables aborting them
+	abandonedUploadAge, err := time.ParseDuration(getEnv("ABANDONED_UPLOAD_AGE", "168h"))
+	if err != nil {
+		return fmt.Errorf("invalid ABANDONED_UPLOAD_AGE: %w", err)
+	}
 
 	// 80*60s = 4800 RPM, below our current 5000 RPM per-user limit on the registry
 	// Even with the host node making other registry API calls
+	// If we do exceed it anyhow, we will back off per the registry responses
 	registryRateLimit := NewRateLimitRoundTripper(80)
 
 	repo, err := name.NewRepository(sourceRegistry)
 	if err != nil {
 		return err
 	}
-	s3Uploader, err := newS3Uploader(os.Getenv("REALLY_UPLOAD") == "")
+	p := newProgress()
+	failures := &imageFailures{
+		continueOnError: continueOnError,
+		attempts:        retryAttempts,
+		backoff:         time.Second,
+		progress:        p,
+	}
+	dryRun := os.Getenv("REALLY_UPLOAD") == ""
+	store, err := newBlobStore(context.Background(), destination, blobStoreOptions{
+		dryRun:        dryRun,
+		partSize:      uploadPartSize,
+		concurrency:   uploadConcurrency,
+		azureSASToken: os.Getenv("AZURE_STORAGE_SAS_TOKEN"),
+	})
 	if err != nil {
 		return err
 	}
+	if s3Store, ok := store.(*s3BlobStore); ok && abandonedUploadAge > 0 && !dryRun {
+		// this is only cleanup, so don't fail on it
+		if err := s3Store.AbortAbandonedUploads(context.Background(), abandonedUploadAge); err != nil {
+			klog.Errorf("Failed to abort abandoned uploads: %v", err)
+		}
+	}
+	uploader := newBlobUploader(store, dryRun, p)
+
+	if metricsAddr != "" {
+		server := &http.Server{
+			Addr:              metricsAddr,
+			Handler:           p,
+			ReadHeaderTimeout: 2 * time.Second,
+		}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				klog.Errorf("Metrics server failed: %v", err)
+			}
+		}()
+		defer server.Close()
+	}
+	exporter := &progressExporter{
+		progress:       p,
+		interval:       progressInterval,
+		textfilePath:   metricsTextfile,
+		pushgatewayURL: pushgatewayURL,
+	}
+	ctx, stopProgress := context.WithCancel(context.Background())
+	go exporter.Run(ctx)
 
 	// copy layers from all images in the repo
-	// TODO: print some progress logs at lower frequency instead of logging each image
-	// We will punt this temporarily, as we're about to refactor how this works anyhow
-	// to avoid fetching manifests for images we've already uploaded
-	err = WalkImageLayersGCP(registryRateLimit, repo,
-		func(ref name.Reference, layers []v1.Layer) error {
-			klog.Infof("Processing image: %s", ref.String())
-			return s3Uploader.UploadImage(s3Bucket, ref, layers, crane.WithTransport(registryRateLimit))
-		},
-		func(imageHash string) bool {
-			s, _ := s3Uploader.ImageAlreadyUploaded(s3Bucket, imageHash)
-			return s
-		})
+	walkImageLayers := func(ref name.Reference, layers []v1.Layer) error {
+		klog.V(2).Infof("Processing image: %s", ref.String())
+		if err := uploader.UploadImage(ref, layers, crane.WithTransport(registryRateLimit)); err != nil {
+			return err
+		}
+		p.ManifestProcessed()
+		return nil
+	}
+	skipImage := func(imageHash string) bool {
+		s, _ := uploader.ImageAlreadyUploaded(imageHash)
+		p.ManifestSeen(s)
+		return s
+	}
+	switch sourceAPI {
+	case "gcp":
+		err = WalkImageLayersGCP(registryRateLimit, repo, walkImageLayers, skipImage, p, failures)
+	case "oci":
+		var repos []name.Repository
+		repos, err = sourceRepos(registryRateLimit, repo, sourceRepositories)
+		if err == nil {
+			err = WalkImageLayersOCI(registryRateLimit, repos, walkImageLayers, skipImage, p, failures)
+		}
+	default:
+		err = fmt.Errorf("unknown SOURCE_API: %q", sourceAPI)
+	}
+	if err == nil {
+		err = failures.Err()
+	}
+	if failureReport != "" {
+		if err := failures.WriteReportFile(failureReport); err != nil {
+			klog.Errorf("Failed to write failure report: %v", err)
+		}
+	}
+	// report final progress and metrics, including whether we succeeded
+	stopProgress()
+	p.Finish(err)
+	exporter.Report()
+	stats := uploader.DedupStats()
+	klog.Infof("Copied or found %d unique blobs, skipped %d duplicate copies saving %d bytes",
+		stats.Unique, stats.Deduplicated, stats.BytesSaved)
 	if err == nil {
 		klog.Info("Done!")
 	}
 	return err
 }
+
+// sourceRepos returns the comma separated repositories relative to root,
+// or all repositories under root if there are none
+func sourceRepos(transport http.RoundTripper, root name.Repository, commaSeparated string) ([]name.Repository, error) {
+	if commaSeparated == "" {
+		return CatalogRepositories(transport, root)
+	}
+	repos := []name.Repository{}
+	for _, r := range strings.Split(commaSeparated, ",") {
+		repo, err := name.NewRepository(root.String() + "/" + strings.TrimSpace(r))
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
+
+// getEnv returns defaultValue if key is not set, else the value of os.LookupEnv(key)
+func getEnv(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return defaultValue
+}
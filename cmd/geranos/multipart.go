@@ -0,0 +1,442 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"golang.org/x/sync/errgroup"
+
+	"k8s.io/klog/v2"
+)
+
+// s3MultipartAPI is the subset of *s3.Client used for multipart uploads
+type s3MultipartAPI interface {
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+	ListMultipartUploads(ctx context.Context, params *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error)
+	ListParts(ctx context.Context, params *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// resumableReader is a reader which can skip to an offset without reading
+// the content before it, given the state of its digest hash at that offset,
+// see verifyingReader
+type resumableReader interface {
+	io.Reader
+	HashState() []byte
+	ResumeAt(offset int64, hashState []byte) error
+}
+
+// multipartStateKeyPrefix + upload ID is where the multipartState of an
+// incomplete upload is stored
+const multipartStateKeyPrefix = "geranos/multipart-uploads/"
+
+// multipartState records how much of an incomplete upload may be skipped
+// when it is resumed
+type multipartState struct {
+	// Parts is the number of parts uploaded from the start of the content
+	Parts int32 `json:"parts"`
+	// PartSize is the size of each of these parts
+	PartSize int64 `json:"partSize"`
+	// HashState is the resumableReader hash state after these parts
+	HashState []byte `json:"hashState"`
+}
+
+// multipartUploader uploads large blobs to S3 in parts, resuming any
+// incomplete multipart upload of the same key left by a previous attempt
+//
+// If the content is a resumableReader, the parts that were uploaded from the
+// start of the content are skipped without reading them, along with the hash
+// state needed to verify the rest of the content against its digest.
+// Otherwise the parts that were already uploaded are still read from the
+// source, but they are not uploaded again.
+type multipartUploader struct {
+	client s3MultipartAPI
+	// partSize is the size of each part, the last part may be smaller
+	partSize int64
+	// concurrency is the maximum number of parts uploaded at once,
+	// each part is buffered in memory while it is uploaded
+	concurrency int
+}
+
+// Upload uploads the content of r to bucket/key
+//
+// If reading r fails the upload is left incomplete to be resumed by the next
+// Upload of the same key, unless the content did not match its digest.
+func (m *multipartUploader) Upload(ctx context.Context, bucket, key string, r io.Reader) error {
+	uploadID, existing, err := m.resumeOrCreate(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	skipped := m.skipUploaded(ctx, bucket, key, uploadID, existing, r)
+	parts, err := m.uploadParts(ctx, bucket, key, uploadID, existing, skipped, r)
+	if err != nil {
+		// corrupted content is not worth resuming
+		if errors.Is(err, errDigestMismatch) {
+			m.abort(ctx, bucket, key, uploadID)
+		}
+		return err
+	}
+	_, err = m.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return err
+	}
+	m.deleteState(ctx, bucket, uploadID)
+	return nil
+}
+
+// skipUploaded skips r past the parts uploaded from the start of the content
+// by a previous attempt, if r is a resumableReader and the attempt saved its
+// state, and returns the number of parts skipped
+func (m *multipartUploader) skipUploaded(ctx context.Context, bucket, key, uploadID string, existing map[int32]types.Part, r io.Reader) int32 {
+	rr, ok := r.(resumableReader)
+	if !ok || len(existing) == 0 {
+		return 0
+	}
+	state, err := m.loadState(ctx, bucket, uploadID)
+	if err != nil {
+		klog.Warningf("Failed to load upload state of %s, reading uploaded parts again: %v", key, err)
+		return 0
+	}
+	if state.PartSize != m.partSize {
+		return 0
+	}
+	for number := int32(1); number <= state.Parts; number++ {
+		if p, ok := existing[number]; !ok || aws.ToInt64(p.Size) != m.partSize {
+			return 0
+		}
+	}
+	if err := rr.ResumeAt(int64(state.Parts)*m.partSize, state.HashState); err != nil {
+		klog.Warningf("Failed to skip uploaded parts of %s, reading them again: %v", key, err)
+		return 0
+	}
+	klog.Infof("Skipped %d uploaded parts of %s", state.Parts, key)
+	return state.Parts
+}
+
+func (m *multipartUploader) loadState(ctx context.Context, bucket, uploadID string) (*multipartState, error) {
+	out, err := m.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(multipartStateKeyPrefix + uploadID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	state := &multipartState{}
+	if err := json.NewDecoder(out.Body).Decode(state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// saveState records that the first parts of uploadID were uploaded, failing
+// to do so only means that a resumed upload will read these parts again
+func (m *multipartUploader) saveState(ctx context.Context, bucket, uploadID string, state *multipartState) {
+	// json.Marshal cannot fail for multipartState
+	b, _ := json.Marshal(state)
+	_, err := m.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(multipartStateKeyPrefix + uploadID),
+		Body:   bytes.NewReader(b),
+	})
+	if err != nil {
+		klog.Warningf("Failed to save state of upload %s: %v", uploadID, err)
+	}
+}
+
+func (m *multipartUploader) deleteState(ctx context.Context, bucket, uploadID string) {
+	_, err := m.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(multipartStateKeyPrefix + uploadID),
+	})
+	if err != nil {
+		klog.Warningf("Failed to delete state of upload %s: %v", uploadID, err)
+	}
+}
+
+// resumeOrCreate returns the most recent incomplete upload of key and its
+// uploaded parts by part number, or else creates a new upload
+func (m *multipartUploader) resumeOrCreate(ctx context.Context, bucket, key string) (string, map[int32]types.Part, error) {
+	var resume *types.MultipartUpload
+	paginator := s3.NewListMultipartUploadsPaginator(m.client, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(key),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", nil, err
+		}
+		for i := range page.Uploads {
+			upload := &page.Uploads[i]
+			// we can only verify parts that were uploaded with our checksums
+			if aws.ToString(upload.Key) != key || upload.ChecksumAlgorithm != types.ChecksumAlgorithmSha256 {
+				continue
+			}
+			if resume == nil || aws.ToTime(upload.Initiated).After(aws.ToTime(resume.Initiated)) {
+				resume = upload
+			}
+		}
+	}
+	if resume != nil {
+		uploadID := aws.ToString(resume.UploadId)
+		parts, err := m.listParts(ctx, bucket, key, uploadID)
+		if err != nil {
+			return "", nil, err
+		}
+		klog.Infof("Resuming upload of %s with %d parts already uploaded", key, len(parts))
+		return uploadID, parts, nil
+	}
+	out, err := m.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:            aws.String(bucket),
+		Key:               aws.String(key),
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return aws.ToString(out.UploadId), nil, nil
+}
+
+func (m *multipartUploader) listParts(ctx context.Context, bucket, key, uploadID string) (map[int32]types.Part, error) {
+	parts := map[int32]types.Part{}
+	paginator := s3.NewListPartsPaginator(m.client, &s3.ListPartsInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range page.Parts {
+			parts[aws.ToInt32(part.PartNumber)] = part
+		}
+	}
+	return parts, nil
+}
+
+// uploadParts reads r in partSize parts after the skipped parts, uploading
+// each part unless an identical part already exists, and returns all of the
+// parts in order
+func (m *multipartUploader) uploadParts(ctx context.Context, bucket, key, uploadID string, existing map[int32]types.Part, skipped int32, r io.Reader) ([]types.CompletedPart, error) {
+	parts := []types.CompletedPart{}
+	for number := int32(1); number <= skipped; number++ {
+		p := existing[number]
+		parts = append(parts, types.CompletedPart{
+			PartNumber:     aws.Int32(number),
+			ETag:           p.ETag,
+			ChecksumSHA256: p.ChecksumSHA256,
+		})
+	}
+	progress := &multipartProgress{
+		m:        m,
+		bucket:   bucket,
+		uploadID: uploadID,
+		uploaded: skipped,
+		done:     map[int32]bool{},
+		states:   map[int32][]byte{},
+	}
+	rr, resumable := r.(resumableReader)
+	var mu sync.Mutex
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(m.concurrency, 1))
+	for partNumber := skipped + 1; ctx.Err() == nil; partNumber++ {
+		buf := make([]byte, m.partSize)
+		n, readErr := io.ReadFull(r, buf)
+		if readErr != nil && !errors.Is(readErr, io.EOF) && !errors.Is(readErr, io.ErrUnexpectedEOF) {
+			// don't start any more parts, but let the ones in flight finish
+			// so that they can be resumed
+			_ = g.Wait()
+			return nil, readErr
+		}
+		if n == 0 {
+			break
+		}
+		part := buf[:n]
+		sum := sha256.Sum256(part)
+		checksum := base64.StdEncoding.EncodeToString(sum[:])
+		number := partNumber
+		// only full parts may be skipped when resuming
+		if resumable && readErr == nil {
+			progress.read(number, rr.HashState())
+		}
+		if p, ok := existing[number]; ok && aws.ToInt64(p.Size) == int64(n) && aws.ToString(p.ChecksumSHA256) == checksum {
+			klog.V(4).Infof("Part %d already uploaded: %s", number, key)
+			mu.Lock()
+			parts = append(parts, types.CompletedPart{
+				PartNumber:     aws.Int32(number),
+				ETag:           p.ETag,
+				ChecksumSHA256: p.ChecksumSHA256,
+			})
+			mu.Unlock()
+			progress.uploadedPart(ctx, number)
+		} else {
+			g.Go(func() error {
+				out, err := m.client.UploadPart(ctx, &s3.UploadPartInput{
+					Bucket:         aws.String(bucket),
+					Key:            aws.String(key),
+					UploadId:       aws.String(uploadID),
+					PartNumber:     aws.Int32(number),
+					Body:           bytes.NewReader(part),
+					ChecksumSHA256: aws.String(checksum),
+				})
+				if err != nil {
+					return fmt.Errorf("failed to upload part %d of %s: %w", number, key, err)
+				}
+				klog.V(4).Infof("Uploaded part %d: %s", number, key)
+				mu.Lock()
+				parts = append(parts, types.CompletedPart{
+					PartNumber:     aws.Int32(number),
+					ETag:           out.ETag,
+					ChecksumSHA256: aws.String(checksum),
+				})
+				mu.Unlock()
+				progress.uploadedPart(ctx, number)
+				return nil
+			})
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return aws.ToInt32(parts[i].PartNumber) < aws.ToInt32(parts[j].PartNumber)
+	})
+	return parts, nil
+}
+
+// multipartProgress saves the multipartState of an upload each time the
+// parts uploaded from the start of the content grow
+type multipartProgress struct {
+	m        *multipartUploader
+	bucket   string
+	uploadID string
+
+	mu sync.Mutex
+	// uploaded is the number of parts uploaded from the start of the content
+	uploaded int32
+	// done is the parts uploaded after uploaded
+	done map[int32]bool
+	// states is the reader hash state after each part that was read
+	// but is not yet part of uploaded
+	states map[int32][]byte
+}
+
+// read records the reader hash state after part number was read
+func (p *multipartProgress) read(number int32, hashState []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.states[number] = hashState
+}
+
+// uploadedPart records that part number was uploaded
+func (p *multipartProgress) uploadedPart(ctx context.Context, number int32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done[number] = true
+	uploaded := p.uploaded
+	for p.done[uploaded+1] {
+		uploaded++
+		delete(p.done, uploaded)
+	}
+	if uploaded == p.uploaded {
+		return
+	}
+	for number := p.uploaded + 1; number < uploaded; number++ {
+		delete(p.states, number)
+	}
+	p.uploaded = uploaded
+	hashState, ok := p.states[uploaded]
+	if !ok {
+		// the last part, which is never skipped
+		return
+	}
+	delete(p.states, uploaded)
+	p.m.saveState(ctx, p.bucket, p.uploadID, &multipartState{
+		Parts:     uploaded,
+		PartSize:  p.m.partSize,
+		HashState: hashState,
+	})
+}
+
+func (m *multipartUploader) abort(ctx context.Context, bucket, key, uploadID string) {
+	_, err := m.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		klog.Errorf("Failed to abort upload of %s: %v", key, err)
+		return
+	}
+	m.deleteState(ctx, bucket, uploadID)
+}
+
+// AbortAbandoned aborts incomplete uploads under prefix initiated before
+// olderThan ago, these are no longer expected to be resumed and S3 charges
+// for storing their parts
+func (m *multipartUploader) AbortAbandoned(ctx context.Context, bucket, prefix string, olderThan time.Duration) error {
+	cutoff := time.Now().Add(-olderThan)
+	paginator := s3.NewListMultipartUploadsPaginator(m.client, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, upload := range page.Uploads {
+			if !aws.ToTime(upload.Initiated).Before(cutoff) {
+				continue
+			}
+			key := aws.ToString(upload.Key)
+			klog.Infof("Aborting abandoned upload of %s initiated at %s", key, aws.ToTime(upload.Initiated))
+			m.abort(ctx, bucket, key, aws.ToString(upload.UploadId))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,596 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeMultipartS3 is an in-memory s3MultipartAPI for a single bucket
+type fakeMultipartS3 struct {
+	mu          sync.Mutex
+	nextID      int
+	uploads     map[string]*fakeUpload
+	objects     map[string][]byte
+	partUploads int
+	failPart    int32
+	// errs fails each operation by name with the error, if set
+	errs map[string]error
+}
+
+type fakeUpload struct {
+	key       string
+	initiated time.Time
+	parts     map[int32][]byte
+}
+
+func newFakeMultipartS3() *fakeMultipartS3 {
+	return &fakeMultipartS3{
+		uploads: map[string]*fakeUpload{},
+		objects: map[string][]byte{},
+		errs:    map[string]error{},
+	}
+}
+
+func partChecksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func (f *fakeMultipartS3) CreateMultipartUpload(_ context.Context, params *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["CreateMultipartUpload"]; err != nil {
+		return nil, err
+	}
+	f.nextID++
+	id := fmt.Sprintf("upload-%d", f.nextID)
+	f.uploads[id] = &fakeUpload{key: aws.ToString(params.Key), initiated: time.Now(), parts: map[int32][]byte{}}
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String(id)}, nil
+}
+
+func (f *fakeMultipartS3) UploadPart(_ context.Context, params *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	b, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if aws.ToInt32(params.PartNumber) == f.failPart {
+		return nil, errors.New("connection reset")
+	}
+	if partChecksum(b) != aws.ToString(params.ChecksumSHA256) {
+		return nil, errors.New("bad part checksum")
+	}
+	f.partUploads++
+	f.uploads[aws.ToString(params.UploadId)].parts[aws.ToInt32(params.PartNumber)] = b
+	return &s3.UploadPartOutput{ETag: aws.String(partChecksum(b))}, nil
+}
+
+func (f *fakeMultipartS3) CompleteMultipartUpload(_ context.Context, params *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["CompleteMultipartUpload"]; err != nil {
+		return nil, err
+	}
+	upload := f.uploads[aws.ToString(params.UploadId)]
+	var object []byte
+	for i, part := range params.MultipartUpload.Parts {
+		if aws.ToInt32(part.PartNumber) != int32(i+1) {
+			return nil, errors.New("parts out of order")
+		}
+		b := upload.parts[aws.ToInt32(part.PartNumber)]
+		if aws.ToString(part.ETag) != partChecksum(b) {
+			return nil, errors.New("bad part etag")
+		}
+		object = append(object, b...)
+	}
+	f.objects[upload.key] = object
+	delete(f.uploads, aws.ToString(params.UploadId))
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (f *fakeMultipartS3) AbortMultipartUpload(_ context.Context, params *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["AbortMultipartUpload"]; err != nil {
+		return nil, err
+	}
+	delete(f.uploads, aws.ToString(params.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (f *fakeMultipartS3) ListMultipartUploads(_ context.Context, _ *s3.ListMultipartUploadsInput, _ ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["ListMultipartUploads"]; err != nil {
+		return nil, err
+	}
+	out := &s3.ListMultipartUploadsOutput{}
+	for id, upload := range f.uploads {
+		out.Uploads = append(out.Uploads, types.MultipartUpload{
+			UploadId:          aws.String(id),
+			Key:               aws.String(upload.key),
+			Initiated:         aws.Time(upload.initiated),
+			ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+		})
+	}
+	return out, nil
+}
+
+func (f *fakeMultipartS3) ListParts(_ context.Context, params *s3.ListPartsInput, _ ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["ListParts"]; err != nil {
+		return nil, err
+	}
+	out := &s3.ListPartsOutput{}
+	for number, b := range f.uploads[aws.ToString(params.UploadId)].parts {
+		out.Parts = append(out.Parts, types.Part{
+			PartNumber:     aws.Int32(number),
+			Size:           aws.Int64(int64(len(b))),
+			ETag:           aws.String(partChecksum(b)),
+			ChecksumSHA256: aws.String(partChecksum(b)),
+		})
+	}
+	sort.Slice(out.Parts, func(i, j int) bool {
+		return aws.ToInt32(out.Parts[i].PartNumber) < aws.ToInt32(out.Parts[j].PartNumber)
+	})
+	return out, nil
+}
+
+func (f *fakeMultipartS3) PutObject(_ context.Context, params *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	b, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["PutObject"]; err != nil {
+		return nil, err
+	}
+	f.objects[aws.ToString(params.Key)] = b
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeMultipartS3) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["GetObject"]; err != nil {
+		return nil, err
+	}
+	b, ok := f.objects[aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(b))}, nil
+}
+
+func (f *fakeMultipartS3) DeleteObject(_ context.Context, params *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["DeleteObject"]; err != nil {
+		return nil, err
+	}
+	delete(f.objects, aws.ToString(params.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// verifiedContent returns a verifyingReader for content with digest of expected
+func verifiedContent(t *testing.T, content, expected []byte) io.Reader {
+	t.Helper()
+	r, err := newResumableVerifyingReader(openContent(content), testDigest("sha256", expected))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return r
+}
+
+// resumableContent is verifiedContent, but it can be resumed, and records
+// the offsets it was opened at and how much of content was read
+type resumableContent struct {
+	mu      sync.Mutex
+	content []byte
+	opened  []int64
+	read    int
+	openErr error
+}
+
+func (c *resumableContent) reader(t *testing.T, expected []byte) *verifyingReader {
+	t.Helper()
+	r, err := newResumableVerifyingReader(c.open, testDigest("sha256", expected))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return r
+}
+
+func (c *resumableContent) open(offset int64) (io.ReadCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.openErr != nil && offset != 0 {
+		return nil, c.openErr
+	}
+	c.opened = append(c.opened, offset)
+	return io.NopCloser(c), nil
+}
+
+func (c *resumableContent) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	offset := int(c.opened[len(c.opened)-1]) + c.read
+	if offset >= len(c.content) {
+		return 0, io.EOF
+	}
+	n := copy(p, c.content[offset:])
+	c.read += n
+	return n, nil
+}
+
+// failingReader returns err after reading r
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, f.err
+	}
+	return n, err
+}
+
+func TestMultipartUploaderResume(t *testing.T) {
+	const key = "containers/images/sha256:aaaa"
+	// 4 full parts and a partial one
+	content := bytes.Repeat([]byte("0123456789"), 45)
+	fake := newFakeMultipartS3()
+	m := &multipartUploader{client: fake, partSize: 100, concurrency: 2}
+	// uploads of other keys are not resumed
+	fake.uploads["other"] = &fakeUpload{key: key + "0", initiated: time.Now(), parts: map[int32][]byte{}}
+
+	// the first attempt fails on the third part, leaving an incomplete upload
+	fake.failPart = 3
+	if err := m.Upload(context.Background(), "bucket", key, verifiedContent(t, content, content)); err == nil {
+		t.Fatal("expected upload to fail")
+	}
+	var existing int
+	for id, upload := range fake.uploads {
+		if id != "other" {
+			existing = len(upload.parts)
+		}
+	}
+	if len(fake.uploads) != 2 || existing == 0 {
+		t.Fatalf("expected incomplete upload to be kept, got %d uploads", len(fake.uploads))
+	}
+	uploaded := fake.partUploads
+
+	// the next attempt only uploads the missing parts
+	fake.failPart = 0
+	if err := m.Upload(context.Background(), "bucket", key, verifiedContent(t, content, content)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(fake.objects[key], content) {
+		t.Fatalf("uploaded object does not match content")
+	}
+	if resumed := fake.partUploads - uploaded; resumed != 5-existing {
+		t.Fatalf("expected only the %d missing parts to be uploaded, got %d", 5-existing, resumed)
+	}
+	if len(fake.uploads) != 1 {
+		t.Fatalf("expected only the other incomplete upload, got %d", len(fake.uploads))
+	}
+}
+
+func TestMultipartUploaderFullParts(t *testing.T) {
+	const key = "containers/images/sha256:aaaa"
+	// exactly 4 full parts
+	content := bytes.Repeat([]byte("0123456789"), 40)
+	fake := newFakeMultipartS3()
+	m := &multipartUploader{client: fake, partSize: 100, concurrency: 2}
+	if err := m.Upload(context.Background(), "bucket", key, verifiedContent(t, content, content)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(fake.objects[key], content) || fake.partUploads != 4 {
+		t.Fatalf("expected content to be uploaded in 4 parts, got %d", fake.partUploads)
+	}
+}
+
+func TestMultipartUploaderDigestMismatch(t *testing.T) {
+	const key = "containers/images/sha256:aaaa"
+	content := bytes.Repeat([]byte("0123456789"), 45)
+	body := verifiedContent(t, content, []byte("something else"))
+	fake := newFakeMultipartS3()
+	m := &multipartUploader{client: fake, partSize: 100, concurrency: 2}
+	if err := m.Upload(context.Background(), "bucket", key, body); !errors.Is(err, errDigestMismatch) {
+		t.Fatalf("expected digest mismatch error but got: %v", err)
+	}
+	if len(fake.objects) != 0 || len(fake.uploads) != 0 {
+		t.Fatalf("expected corrupt upload to be aborted, got %d objects and %d uploads", len(fake.objects), len(fake.uploads))
+	}
+}
+
+func TestMultipartUploaderAbortAbandoned(t *testing.T) {
+	fake := newFakeMultipartS3()
+	fake.uploads["old"] = &fakeUpload{key: "containers/images/sha256:old", initiated: time.Now().Add(-48 * time.Hour)}
+	fake.uploads["new"] = &fakeUpload{key: "containers/images/sha256:new", initiated: time.Now()}
+	m := &multipartUploader{client: fake, partSize: 100, concurrency: 2}
+	if err := m.AbortAbandoned(context.Background(), "bucket", blobKeyPrefix, 24*time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := fake.uploads["old"]; ok {
+		t.Fatal("expected old upload to be aborted")
+	}
+	if _, ok := fake.uploads["new"]; !ok {
+		t.Fatal("expected new upload to be kept")
+	}
+}
+
+func TestMultipartUploaderResumeSkipsUploaded(t *testing.T) {
+	const key = "containers/images/sha256:aaaa"
+	// 4 full parts and a partial one
+	content := bytes.Repeat([]byte("0123456789"), 45)
+	fake := newFakeMultipartS3()
+	m := &multipartUploader{client: fake, partSize: 100, concurrency: 1}
+
+	// the first attempt fails on the third part, after the first two parts
+	// were uploaded and their state saved
+	fake.failPart = 3
+	first := &resumableContent{content: content}
+	if err := m.Upload(context.Background(), "bucket", key, first.reader(t, content)); err == nil {
+		t.Fatal("expected upload to fail")
+	}
+	var uploadID string
+	for id := range fake.uploads {
+		uploadID = id
+	}
+	if _, ok := fake.objects[multipartStateKeyPrefix+uploadID]; !ok {
+		t.Fatal("expected upload state to be saved")
+	}
+
+	// the next attempt skips the first two parts without reading them
+	fake.failPart = 0
+	second := &resumableContent{content: content}
+	if err := m.Upload(context.Background(), "bucket", key, second.reader(t, content)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(fake.objects[key], content) {
+		t.Fatalf("uploaded object does not match content")
+	}
+	if len(second.opened) != 1 || second.opened[0] != 200 || second.read != len(content)-200 {
+		t.Fatalf("expected content to be read from offset 200, got opened at %v and read %d bytes", second.opened, second.read)
+	}
+	if _, ok := fake.objects[multipartStateKeyPrefix+uploadID]; ok {
+		t.Fatal("expected upload state to be deleted")
+	}
+
+	// corrupted content after the skipped parts is still detected
+	fake.failPart = 3
+	if err := m.Upload(context.Background(), "bucket", "other", (&resumableContent{content: content}).reader(t, content)); err == nil {
+		t.Fatal("expected upload to fail")
+	}
+	fake.failPart = 0
+	corrupted := append(bytes.Clone(content[:200]), bytes.Repeat([]byte("x"), 250)...)
+	if err := m.Upload(context.Background(), "bucket", "other", (&resumableContent{content: corrupted}).reader(t, content)); !errors.Is(err, errDigestMismatch) {
+		t.Fatalf("expected digest mismatch error but got: %v", err)
+	}
+	if len(fake.uploads) != 0 || len(fake.objects) != 1 {
+		t.Fatalf("expected corrupt upload and its state to be deleted, got %d uploads and %d objects", len(fake.uploads), len(fake.objects))
+	}
+}
+
+func TestMultipartUploaderResumeFallback(t *testing.T) {
+	const key = "containers/images/sha256:aaaa"
+	content := bytes.Repeat([]byte("0123456789"), 45)
+	uploadID := "upload-1"
+	stateKey := multipartStateKeyPrefix + uploadID
+	failed := errors.New("failed")
+	testCases := []struct {
+		Name    string
+		Setup   func(fake *fakeMultipartS3)
+		OpenErr error
+	}{
+		{
+			Name: "state cannot be loaded",
+			Setup: func(fake *fakeMultipartS3) {
+				fake.errs["GetObject"] = failed
+			},
+		},
+		{
+			Name: "state is missing",
+			Setup: func(fake *fakeMultipartS3) {
+				delete(fake.objects, stateKey)
+			},
+		},
+		{
+			Name: "state is invalid",
+			Setup: func(fake *fakeMultipartS3) {
+				fake.objects[stateKey] = []byte("{")
+			},
+		},
+		{
+			Name: "state has another part size",
+			Setup: func(fake *fakeMultipartS3) {
+				fake.objects[stateKey] = []byte(`{"parts":2,"partSize":50}`)
+			},
+		},
+		{
+			Name: "state has parts that are missing",
+			Setup: func(fake *fakeMultipartS3) {
+				delete(fake.uploads[uploadID].parts, 2)
+			},
+		},
+		{
+			Name: "state has an invalid hash state",
+			Setup: func(fake *fakeMultipartS3) {
+				fake.objects[stateKey] = []byte(`{"parts":2,"partSize":100,"hashState":"AA=="}`)
+			},
+		},
+		{
+			Name:    "content cannot be opened at offset",
+			OpenErr: failed,
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			fake := newFakeMultipartS3()
+			m := &multipartUploader{client: fake, partSize: 100, concurrency: 1}
+			fake.failPart = 3
+			first := &resumableContent{content: content}
+			if err := m.Upload(context.Background(), "bucket", key, first.reader(t, content)); err == nil {
+				t.Fatal("expected upload to fail")
+			}
+			fake.failPart = 0
+			if tc.Setup != nil {
+				tc.Setup(fake)
+			}
+			fake.errs["DeleteObject"] = failed
+			// the uploaded parts are read again instead
+			second := &resumableContent{content: content, openErr: tc.OpenErr}
+			if err := m.Upload(context.Background(), "bucket", key, second.reader(t, content)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(fake.objects[key], content) {
+				t.Fatalf("uploaded object does not match content")
+			}
+			if len(second.opened) != 1 || second.opened[0] != 0 || second.read != len(content) {
+				t.Fatalf("expected all content to be read, got opened at %v and read %d bytes", second.opened, second.read)
+			}
+		})
+	}
+}
+
+func TestMultipartUploaderErrors(t *testing.T) {
+	const key = "containers/images/sha256:aaaa"
+	content := bytes.Repeat([]byte("0123456789"), 45)
+	failed := errors.New("failed")
+	testCases := []struct {
+		Name string
+		// Resume leaves an incomplete upload to resume first
+		Resume bool
+		Errs   map[string]error
+		Body   func(t *testing.T) io.Reader
+		// ExpectedUploads is the number of incomplete uploads left
+		ExpectedUploads int
+	}{
+		{
+			Name: "list uploads fails",
+			Errs: map[string]error{"ListMultipartUploads": failed},
+		},
+		{
+			Name:            "list parts fails",
+			Resume:          true,
+			Errs:            map[string]error{"ListParts": failed},
+			ExpectedUploads: 1,
+		},
+		{
+			Name: "create upload fails",
+			Errs: map[string]error{"CreateMultipartUpload": failed},
+		},
+		{
+			Name:            "complete upload fails",
+			Errs:            map[string]error{"CompleteMultipartUpload": failed},
+			ExpectedUploads: 1,
+		},
+		{
+			Name: "reading content fails",
+			Body: func(t *testing.T) io.Reader {
+				return &failingReader{r: bytes.NewReader(content[:250]), err: failed}
+			},
+			ExpectedUploads: 1,
+		},
+		{
+			Name: "abort fails",
+			Errs: map[string]error{"AbortMultipartUpload": failed},
+			Body: func(t *testing.T) io.Reader {
+				return &failingReader{r: verifiedContent(t, content, []byte("something else")), err: failed}
+			},
+			ExpectedUploads: 1,
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			fake := newFakeMultipartS3()
+			m := &multipartUploader{client: fake, partSize: 100, concurrency: 2}
+			if tc.Resume {
+				fake.failPart = 3
+				if err := m.Upload(context.Background(), "bucket", key, verifiedContent(t, content, content)); err == nil {
+					t.Fatal("expected upload to fail")
+				}
+				fake.failPart = 0
+			}
+			for op, err := range tc.Errs {
+				fake.errs[op] = err
+			}
+			body := verifiedContent(t, content, content)
+			if tc.Body != nil {
+				body = tc.Body(t)
+			}
+			if err := m.Upload(context.Background(), "bucket", key, body); err == nil {
+				t.Fatal("expected upload to fail")
+			}
+			if _, ok := fake.objects[key]; ok {
+				t.Fatal("expected no object to be uploaded")
+			}
+			if len(fake.uploads) != tc.ExpectedUploads {
+				t.Fatalf("expected %d incomplete uploads, got %d", tc.ExpectedUploads, len(fake.uploads))
+			}
+		})
+	}
+}
+
+func TestMultipartUploaderSaveStateFails(t *testing.T) {
+	const key = "containers/images/sha256:aaaa"
+	content := bytes.Repeat([]byte("0123456789"), 45)
+	fake := newFakeMultipartS3()
+	fake.errs["PutObject"] = errors.New("failed")
+	m := &multipartUploader{client: fake, partSize: 100, concurrency: 1}
+	// failing to save the state does not fail the upload
+	if err := m.Upload(context.Background(), "bucket", key, (&resumableContent{content: content}).reader(t, content)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(fake.objects[key], content) {
+		t.Fatalf("uploaded object does not match content")
+	}
+}
+
+func TestMultipartUploaderAbortAbandonedListFails(t *testing.T) {
+	fake := newFakeMultipartS3()
+	fake.errs["ListMultipartUploads"] = errors.New("failed")
+	m := &multipartUploader{client: fake, partSize: 100, concurrency: 2}
+	if err := m.AbortAbandoned(context.Background(), "bucket", blobKeyPrefix, 24*time.Hour); err == nil {
+		t.Fatal("expected error listing uploads")
+	}
+}
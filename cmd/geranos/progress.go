@@ -0,0 +1,298 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// progress tracks what a geranos run has done so far
+//
+// It is used for periodic progress logs and is exported as Prometheus metrics
+// so scheduled runs can be alerted on when they stall or fail.
+//
+// All methods are safe for concurrent use, and are no-ops on a nil *progress
+type progress struct {
+	start time.Time
+
+	repositoriesWalked atomic.Int64
+	manifestsSeen      atomic.Int64
+	manifestsSkipped   atomic.Int64
+	manifestsProcessed atomic.Int64
+	blobsChecked       atomic.Int64
+	blobsUploaded      atomic.Int64
+	blobsSkipped       atomic.Int64
+	bytesUploaded      atomic.Int64
+	errors             atomic.Int64
+
+	// unix nanoseconds of the last change to any counter
+	lastProgress atomic.Int64
+	// set once the run has finished, see Finish
+	finished  atomic.Int64
+	succeeded atomic.Bool
+}
+
+func newProgress() *progress {
+	p := &progress{start: time.Now()}
+	p.lastProgress.Store(p.start.UnixNano())
+	return p
+}
+
+func (p *progress) add(counter *atomic.Int64, n int64) {
+	counter.Add(n)
+	p.lastProgress.Store(time.Now().UnixNano())
+}
+
+// RepositoryWalked records that all manifests in a repository have been listed
+func (p *progress) RepositoryWalked() {
+	if p != nil {
+		p.add(&p.repositoriesWalked, 1)
+	}
+}
+
+// ManifestSeen records that a manifest was found, and whether it was skipped
+// because it was already uploaded by an earlier run
+func (p *progress) ManifestSeen(skipped bool) {
+	if p == nil {
+		return
+	}
+	p.add(&p.manifestsSeen, 1)
+	if skipped {
+		p.add(&p.manifestsSkipped, 1)
+	}
+}
+
+// ManifestProcessed records that all blobs for a manifest have been copied
+func (p *progress) ManifestProcessed() {
+	if p != nil {
+		p.add(&p.manifestsProcessed, 1)
+	}
+}
+
+// BlobChecked records checking if a blob exists in the destination
+func (p *progress) BlobChecked() {
+	if p != nil {
+		p.add(&p.blobsChecked, 1)
+	}
+}
+
+// BlobUploaded records uploading size bytes of a blob
+func (p *progress) BlobUploaded(size int64) {
+	if p == nil {
+		return
+	}
+	p.add(&p.blobsUploaded, 1)
+	p.add(&p.bytesUploaded, size)
+}
+
+// BlobSkipped records not uploading a blob, because it already exists or
+// was already copied earlier in this run
+func (p *progress) BlobSkipped() {
+	if p != nil {
+		p.add(&p.blobsSkipped, 1)
+	}
+}
+
+// Error records a failure
+func (p *progress) Error() {
+	if p != nil {
+		p.add(&p.errors, 1)
+	}
+}
+
+// Finish records the end of the run
+func (p *progress) Finish(err error) {
+	if p == nil {
+		return
+	}
+	p.succeeded.Store(err == nil)
+	p.finished.Store(time.Now().UnixNano())
+}
+
+// Summary returns a human readable summary of progress so far, including
+// throughput and an estimate of the time remaining for manifests found so far
+func (p *progress) Summary() string {
+	elapsed := time.Since(p.start)
+	seen := p.manifestsSeen.Load()
+	done := p.manifestsSkipped.Load() + p.manifestsProcessed.Load()
+	processed := p.manifestsProcessed.Load()
+	bytesUploaded := p.bytesUploaded.Load()
+	eta := "unknown"
+	// we don't know how many manifests exist until we've walked everything,
+	// so this is only an estimate for the manifests we know about so far
+	if remaining := seen - done; processed > 0 && remaining >= 0 {
+		perManifest := elapsed / time.Duration(processed)
+		eta = (perManifest * time.Duration(remaining)).Round(time.Second).String()
+	}
+	return fmt.Sprintf(
+		"Progress after %s: walked %d repositories; manifests: %d seen, %d skipped, %d processed (%.1f/s); "+
+			"blobs: %d checked, %d uploaded, %d skipped; %d bytes uploaded (%.0f B/s); %d errors; ETA for known manifests: %s",
+		elapsed.Round(time.Second), p.repositoriesWalked.Load(),
+		seen, p.manifestsSkipped.Load(), processed, float64(processed)/elapsed.Seconds(),
+		p.blobsChecked.Load(), p.blobsUploaded.Load(), p.blobsSkipped.Load(),
+		bytesUploaded, float64(bytesUploaded)/elapsed.Seconds(),
+		p.errors.Load(), eta,
+	)
+}
+
+// WriteMetrics writes the current progress in the Prometheus text format
+func (p *progress) WriteMetrics(w io.Writer) error {
+	b := &bytes.Buffer{}
+	metric := func(name, kind, help string, value float64) {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+	}
+	counter := func(name, help string, value *atomic.Int64) {
+		metric(name, "counter", help, float64(value.Load()))
+	}
+	counter("geranos_repositories_walked_total", "Repositories with all manifests listed.", &p.repositoriesWalked)
+	counter("geranos_manifests_seen_total", "Manifests found in the source registry.", &p.manifestsSeen)
+	counter("geranos_manifests_skipped_total", "Manifests skipped because they were already uploaded.", &p.manifestsSkipped)
+	counter("geranos_manifests_processed_total", "Manifests with all blobs copied.", &p.manifestsProcessed)
+	counter("geranos_blobs_checked_total", "Blobs checked for existence in the destination.", &p.blobsChecked)
+	counter("geranos_blobs_uploaded_total", "Blobs uploaded to the destination.", &p.blobsUploaded)
+	counter("geranos_blobs_skipped_total", "Blobs not uploaded because they already exist or were already copied in this run.", &p.blobsSkipped)
+	counter("geranos_uploaded_bytes_total", "Bytes uploaded to the destination.", &p.bytesUploaded)
+	counter("geranos_errors_total", "Errors encountered.", &p.errors)
+	metric("geranos_start_time_seconds", "gauge", "Unix time the run started.", float64(p.start.Unix()))
+	metric("geranos_last_progress_time_seconds", "gauge", "Unix time any counter last changed, for detecting stalled runs.",
+		float64(time.Unix(0, p.lastProgress.Load()).Unix()))
+	if finished := p.finished.Load(); finished != 0 {
+		metric("geranos_finish_time_seconds", "gauge", "Unix time the run finished.", float64(time.Unix(0, finished).Unix()))
+		success := 0.0
+		if p.succeeded.Load() {
+			success = 1
+		}
+		metric("geranos_success", "gauge", "Whether the run finished without error.", success)
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// ServeHTTP serves the metrics, for scraping while geranos runs
+func (p *progress) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := p.WriteMetrics(w); err != nil {
+		klog.Errorf("Failed to write metrics: %v", err)
+	}
+}
+
+// WriteTextfile writes the metrics to path for the node_exporter textfile
+// collector, replacing the file atomically so it is never read half-written
+func (p *progress) WriteTextfile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := p.WriteMetrics(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// CreateTemp uses 0600, but the collector may not run as the same user
+	// #nosec G302 -- metrics are not sensitive
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Push pushes the metrics to a Prometheus Pushgateway, replacing any
+// previously pushed metrics for the geranos job
+func (p *progress) Push(pushgatewayURL string) error {
+	b := &bytes.Buffer{}
+	if err := p.WriteMetrics(b); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, pushgatewayURL+"/metrics/job/geranos", b)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("pushing metrics failed with status: %s", resp.Status)
+	}
+	return nil
+}
+
+// parseProgressInterval parses a PROGRESS_INTERVAL duration, which must be positive
+func parseProgressInterval(s string) (time.Duration, error) {
+	interval, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if interval <= 0 {
+		return 0, fmt.Errorf("must be positive: %q", s)
+	}
+	return interval, nil
+}
+
+// progressExporter periodically logs progress and exports metrics
+type progressExporter struct {
+	progress       *progress
+	interval       time.Duration
+	textfilePath   string
+	pushgatewayURL string
+}
+
+// Run reports progress every interval until ctx is done
+func (e *progressExporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.Report()
+		}
+	}
+}
+
+// Report logs progress and exports metrics once
+func (e *progressExporter) Report() {
+	klog.Info(e.progress.Summary())
+	if e.textfilePath != "" {
+		if err := e.progress.WriteTextfile(e.textfilePath); err != nil {
+			klog.Errorf("Failed to write metrics textfile: %v", err)
+		}
+	}
+	if e.pushgatewayURL != "" {
+		if err := e.progress.Push(e.pushgatewayURL); err != nil {
+			klog.Errorf("Failed to push metrics: %v", err)
+		}
+	}
+}
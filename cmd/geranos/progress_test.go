@@ -0,0 +1,213 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressNil(t *testing.T) {
+	// walkers and the uploader are used without progress in tests
+	var p *progress
+	p.RepositoryWalked()
+	p.ManifestSeen(true)
+	p.ManifestProcessed()
+	p.BlobChecked()
+	p.BlobUploaded(1)
+	p.BlobSkipped()
+	p.Error()
+	p.Finish(nil)
+}
+
+func TestProgressMetrics(t *testing.T) {
+	p := newProgress()
+	p.RepositoryWalked()
+	p.ManifestSeen(false)
+	p.ManifestSeen(false)
+	p.ManifestSeen(true)
+	p.ManifestProcessed()
+	p.BlobChecked()
+	p.BlobChecked()
+	p.BlobUploaded(1024)
+	p.BlobSkipped()
+	p.Error()
+
+	summary := p.Summary()
+	for _, expected := range []string{
+		"walked 1 repositories",
+		"3 seen, 1 skipped, 1 processed",
+		"2 checked, 1 uploaded, 1 skipped",
+		"1024 bytes uploaded",
+		"1 errors",
+	} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("expected summary to contain %q: %s", expected, summary)
+		}
+	}
+	if strings.Contains(summary, "ETA for known manifests: unknown") {
+		t.Errorf("expected an ETA once manifests were processed: %s", summary)
+	}
+	if summary := newProgress().Summary(); !strings.Contains(summary, "ETA for known manifests: unknown") {
+		t.Errorf("expected unknown ETA before any manifests were processed: %s", summary)
+	}
+
+	metrics := &strings.Builder{}
+	if err := p.WriteMetrics(metrics); err != nil {
+		t.Fatalf("unexpected error writing metrics: %v", err)
+	}
+	for _, expected := range []string{
+		"# TYPE geranos_repositories_walked_total counter\ngeranos_repositories_walked_total 1\n",
+		"geranos_manifests_seen_total 3\n",
+		"geranos_manifests_skipped_total 1\n",
+		"geranos_manifests_processed_total 1\n",
+		"geranos_blobs_checked_total 2\n",
+		"geranos_blobs_uploaded_total 1\n",
+		"geranos_blobs_skipped_total 1\n",
+		"geranos_uploaded_bytes_total 1024\n",
+		"geranos_errors_total 1\n",
+		"# TYPE geranos_last_progress_time_seconds gauge\n",
+	} {
+		if !strings.Contains(metrics.String(), expected) {
+			t.Errorf("expected metrics to contain %q:\n%s", expected, metrics)
+		}
+	}
+	if strings.Contains(metrics.String(), "geranos_success") {
+		t.Errorf("expected no success metric before finishing:\n%s", metrics)
+	}
+
+	for _, tc := range []struct {
+		err      error
+		expected string
+	}{
+		{err: nil, expected: "geranos_success 1\n"},
+		{err: errors.New("failed"), expected: "geranos_success 0\n"},
+	} {
+		p.Finish(tc.err)
+		metrics.Reset()
+		if err := p.WriteMetrics(metrics); err != nil {
+			t.Fatalf("unexpected error writing metrics: %v", err)
+		}
+		if !strings.Contains(metrics.String(), tc.expected) {
+			t.Errorf("expected metrics to contain %q:\n%s", tc.expected, metrics)
+		}
+	}
+}
+
+func TestProgressExport(t *testing.T) {
+	p := newProgress()
+	p.ManifestSeen(true)
+
+	// scraping
+	recorder := httptest.NewRecorder()
+	p.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(recorder.Body.String(), "geranos_manifests_skipped_total 1\n") {
+		t.Errorf("unexpected scraped metrics:\n%s", recorder.Body.String())
+	}
+
+	// pushing
+	pushed := make(chan string, 1)
+	pushgateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/metrics/job/geranos" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		select {
+		case pushed <- string(b):
+		default:
+		}
+	}))
+	defer pushgateway.Close()
+
+	textfile := filepath.Join(t.TempDir(), "geranos.prom")
+	exporter := &progressExporter{
+		progress:       p,
+		interval:       time.Millisecond,
+		textfilePath:   textfile,
+		pushgatewayURL: pushgateway.URL,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		exporter.Run(ctx)
+		close(done)
+	}()
+	if got := <-pushed; !strings.Contains(got, "geranos_manifests_skipped_total 1\n") {
+		t.Errorf("unexpected pushed metrics:\n%s", got)
+	}
+	cancel()
+	<-done
+	b, err := os.ReadFile(textfile)
+	if err != nil {
+		t.Fatalf("unexpected error reading textfile: %v", err)
+	}
+	if !strings.Contains(string(b), "geranos_manifests_skipped_total 1\n") {
+		t.Errorf("unexpected textfile metrics:\n%s", b)
+	}
+	// no temporary files should be left behind
+	if entries, _ := os.ReadDir(filepath.Dir(textfile)); len(entries) != 1 {
+		t.Errorf("expected only the textfile, got: %v", entries)
+	}
+
+	// failures are reported, but should not stop geranos
+	if err := p.Push(pushgateway.URL + "/bogus"); err == nil {
+		t.Error("expected error pushing to the wrong path")
+	}
+	if err := p.WriteTextfile(filepath.Join(textfile, "not-a-dir", "geranos.prom")); err == nil {
+		t.Error("expected error writing textfile to a missing directory")
+	}
+	exporter.pushgatewayURL = "http://127.0.0.1:0"
+	exporter.textfilePath = filepath.Join(textfile, "not-a-dir", "geranos.prom")
+	exporter.Report()
+}
+
+func TestParseProgressInterval(t *testing.T) {
+	testCases := []struct {
+		Name          string
+		Value         string
+		Expected      time.Duration
+		ExpectedError bool
+	}{
+		{Name: "default", Value: "1m", Expected: time.Minute},
+		{Name: "invalid", Value: "soon", ExpectedError: true},
+		// time.NewTicker panics on non-positive intervals
+		{Name: "zero", Value: "0", ExpectedError: true},
+		{Name: "negative", Value: "-1s", ExpectedError: true},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			interval, err := parseProgressInterval(tc.Value)
+			if (err != nil) != tc.ExpectedError {
+				t.Fatalf("expected error: %t but got: %v", tc.ExpectedError, err)
+			}
+			if interval != tc.Expected {
+				t.Fatalf("expected interval %v but got %v", tc.Expected, interval)
+			}
+		})
+	}
+}
@@ -1,5 +1,5 @@
 /*
-Copyright 2023 The Kubernetes Authors.
+Copyright 2022 The Kubernetes Authors.
 
 Licensed under the Apache License, Version 2.0 (the "License");
 you may not use this file except in compliance with the License.
@@ -17,31 +17,158 @@ limitations under the License.
 package main
 
 import (
-	"context"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"golang.org/x/time/rate"
+
+	"k8s.io/klog/v2"
 )
 
-// RateLimitRoundTripper wraps an http.RoundTripper with rate limiting
+// RateLimitRoundTripper wraps an http.RoundTripper with adaptive rate limiting
+//
+// Each host gets a separate limiter, starting at the configured limit.
+// When a host responds with 429 or 503 the limit for that host is halved,
+// and no further requests are sent to it until any Retry-After has passed.
+// The limit then recovers additively while requests succeed, up to the
+// configured limit (AIMD, as in TCP congestion control).
+//
+// This allows running near a quota without repeatedly exceeding it.
 type RateLimitRoundTripper struct {
-	rateLimiter  *rate.Limiter
-	roundTripper http.RoundTripper
+	// maxLimit is the initial and maximum rate for each host
+	maxLimit rate.Limit
+	// minLimit is the lowest rate we will back off to
+	minLimit rate.Limit
+	// increase is added to the rate at most once per increaseInterval
+	increase         rate.Limit
+	increaseInterval time.Duration
+	roundTripper     http.RoundTripper
+	now              func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+}
+
+// hostLimiter is the rate limiting state for a single host
+type hostLimiter struct {
+	limiter *rate.Limiter
+
+	mu           sync.Mutex
+	lastIncrease time.Time
+	// retryAfter is when we may send requests again after a Retry-After
+	retryAfter time.Time
 }
 
 var _ http.RoundTripper = &RateLimitRoundTripper{}
 
 func NewRateLimitRoundTripper(limit rate.Limit) *RateLimitRoundTripper {
 	return &RateLimitRoundTripper{
-		rateLimiter:  rate.NewLimiter(limit, 1),
-		roundTripper: http.DefaultTransport,
+		maxLimit: limit,
+		minLimit: limit / 100,
+		// recover from halving the limit in ~10s
+		increase:         limit / 20,
+		increaseInterval: time.Second,
+		roundTripper:     http.DefaultTransport,
+		now:              time.Now,
+		hosts:            map[string]*hostLimiter{},
 	}
 }
 
 func (rt *RateLimitRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
-	err := rt.rateLimiter.Wait(context.Background())
+	h := rt.hostLimiter(r.URL.Host)
+	ctx := r.Context()
+	if wait := h.retryAfterWait(rt.now()); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if err := h.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := rt.roundTripper.RoundTrip(r)
 	if err != nil {
 		return nil, err
 	}
-	return rt.roundTripper.RoundTrip(r)
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		rt.decreaseLimit(r.URL.Host, h, resp)
+	default:
+		rt.increaseLimit(h)
+	}
+	return resp, nil
+}
+
+// Limit returns the current rate limit for host
+func (rt *RateLimitRoundTripper) Limit(host string) rate.Limit {
+	return rt.hostLimiter(host).limiter.Limit()
+}
+
+func (rt *RateLimitRoundTripper) hostLimiter(host string) *hostLimiter {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	h, ok := rt.hosts[host]
+	if !ok {
+		h = &hostLimiter{
+			limiter:      rate.NewLimiter(rt.maxLimit, 1),
+			lastIncrease: rt.now(),
+		}
+		rt.hosts[host] = h
+	}
+	return h
+}
+
+// decreaseLimit multiplicatively decreases the rate limit for host, and honors
+// any Retry-After in resp
+func (rt *RateLimitRoundTripper) decreaseLimit(host string, h *hostLimiter, resp *http.Response) {
+	now := rt.now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	limit := max(h.limiter.Limit()/2, rt.minLimit)
+	h.limiter.SetLimitAt(now, limit)
+	// don't immediately start increasing again
+	h.lastIncrease = now
+	if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok && retryAfter.After(h.retryAfter) {
+		h.retryAfter = retryAfter
+	}
+	klog.Warningf("Received %d from %s, reducing rate limit to %.2f/s", resp.StatusCode, host, float64(limit))
+}
+
+// increaseLimit additively increases the rate limit for host, up to the maximum
+func (rt *RateLimitRoundTripper) increaseLimit(h *hostLimiter) {
+	now := rt.now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	limit := h.limiter.Limit()
+	if limit >= rt.maxLimit || now.Sub(h.lastIncrease) < rt.increaseInterval {
+		return
+	}
+	h.limiter.SetLimitAt(now, min(limit+rt.increase, rt.maxLimit))
+	h.lastIncrease = now
+}
+
+func (h *hostLimiter) retryAfterWait(now time.Time) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.retryAfter.Sub(now)
+}
+
+// parseRetryAfter parses a Retry-After header value, which may be either
+// a number of seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return now.Add(time.Duration(seconds) * time.Second), true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
 }
@@ -0,0 +1,209 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// fakeClock is a manually advanced clock for RateLimitRoundTripper.now
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Step(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestRateLimitRoundTripper(t *testing.T, limit rate.Limit) (*RateLimitRoundTripper, *fakeClock, *httptest.Server) {
+	t.Helper()
+	clock := &fakeClock{now: time.Now()}
+	rt := NewRateLimitRoundTripper(limit)
+	rt.now = clock.Now
+	// path -> Retry-After, for paths that should be rate limited
+	limited := map[string]string{
+		"/limited":            "",
+		"/limited/seconds":    "10",
+		"/limited/date":       clock.now.Add(time.Minute).UTC().Format(http.TimeFormat),
+		"/limited/unparsable": "soon",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		retryAfter, ok := limited[r.URL.Path]
+		if !ok {
+			return
+		}
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(server.Close)
+	return rt, clock, server
+}
+
+func doRequest(ctx context.Context, t *testing.T, rt http.RoundTripper, u string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := rt.RoundTrip(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestRateLimitRoundTripperAIMD(t *testing.T) {
+	rt, clock, server := newTestRateLimitRoundTripper(t, 1000)
+	host := server.Listener.Addr().String()
+	ctx := context.Background()
+
+	// rate limited responses halve the limit, down to the minimum
+	for _, expected := range []rate.Limit{500, 250, 125, 62.5, 31.25, 15.625, 10, 10} {
+		resp, err := doRequest(ctx, t, rt, server.URL+"/limited")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("expected response to be passed through, got: %d", resp.StatusCode)
+		}
+		if limit := rt.Limit(host); limit != expected {
+			t.Fatalf("expected limit %v, got %v", expected, limit)
+		}
+	}
+
+	// other hosts are not affected
+	other, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Host = "localhost:" + other.Port()
+	if _, err := doRequest(ctx, t, rt, other.String()+"/ok"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if limit := rt.Limit(other.Host); limit != 1000 {
+		t.Fatalf("expected limit for other host to be unchanged, got %v", limit)
+	}
+
+	// successful responses increase the limit, at most once per interval
+	if _, err := doRequest(ctx, t, rt, server.URL+"/ok"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if limit := rt.Limit(host); limit != 10 {
+		t.Fatalf("expected limit not to increase immediately, got %v", limit)
+	}
+	for _, expected := range []rate.Limit{60, 110} {
+		clock.Step(time.Second)
+		if _, err := doRequest(ctx, t, rt, server.URL+"/ok"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if limit := rt.Limit(host); limit != expected {
+			t.Fatalf("expected limit %v, got %v", expected, limit)
+		}
+	}
+	// up to the maximum
+	for range 30 {
+		clock.Step(time.Second)
+		if _, err := doRequest(ctx, t, rt, server.URL+"/ok"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if limit := rt.Limit(host); limit != 1000 {
+		t.Fatalf("expected limit to recover to the maximum, got %v", limit)
+	}
+}
+
+func TestRateLimitRoundTripperRetryAfter(t *testing.T) {
+	testCases := []struct {
+		Path     string
+		Expected time.Duration
+	}{
+		{Path: "/limited", Expected: 0},
+		{Path: "/limited/unparsable", Expected: 0},
+		{Path: "/limited/seconds", Expected: 10 * time.Second},
+		{Path: "/limited/date", Expected: time.Minute},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Path, func(t *testing.T) {
+			rt, clock, server := newTestRateLimitRoundTripper(t, 1000)
+			if _, err := doRequest(context.Background(), t, rt, server.URL+tc.Path); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			wait := max(rt.hostLimiter(server.Listener.Addr().String()).retryAfterWait(clock.Now()), 0)
+			// HTTP dates only have second precision
+			if wait > tc.Expected || wait < tc.Expected-time.Second {
+				t.Fatalf("expected to wait %v, got %v", tc.Expected, wait)
+			}
+		})
+	}
+}
+
+func TestRateLimitRoundTripperContext(t *testing.T) {
+	rt, clock, server := newTestRateLimitRoundTripper(t, 1000)
+	if _, err := doRequest(context.Background(), t, rt, server.URL+"/limited/seconds"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// requests waiting for Retry-After are cancelled with their context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := doRequest(ctx, t, rt, server.URL+"/ok"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context error, got: %v", err)
+	}
+
+	// once it has passed, requests are sent again
+	clock.Step(10 * time.Second)
+	resp, err := doRequest(context.Background(), t, rt, server.URL+"/ok")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+
+	// requests waiting for the limiter are also cancelled with their context
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := doRequest(cancelled, t, rt, server.URL+"/ok"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context error, got: %v", err)
+	}
+
+	// transport errors are returned
+	server.Close()
+	if _, err := doRequest(context.Background(), t, rt, server.URL+"/ok"); err == nil {
+		t.Fatal("expected error for closed server")
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// cosignTagSuffixes are the tag suffixes cosign uses to attach artifacts
+// to an image, as sha256-<hex>.<suffix>
+//
+// See also the SignatureUpstreamEndpoint in cmd/archeio
+var cosignTagSuffixes = []string{"sig", "att", "sbom"}
+
+// referrersOf discovers signatures, attestations, SBOMs etc. attached to the
+// manifest digest in repo, using both the OCI 1.1 referrers API (or the
+// equivalent fallback tag scheme) and the cosign tag scheme
+func referrersOf(transport http.RoundTripper, repo name.Repository, digest v1.Hash) ([]v1.Descriptor, error) {
+	index, err := remote.Referrers(repo.Digest(digest.String()), remote.WithTransport(transport))
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	referrers := manifest.Manifests
+	for _, suffix := range cosignTagSuffixes {
+		tag := repo.Tag(strings.Replace(digest.String(), ":", "-", 1) + "." + suffix)
+		desc, err := remote.Head(tag, remote.WithTransport(transport))
+		if isNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		referrers = append(referrers, *desc)
+	}
+	return referrers, nil
+}
+
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
+
+// layersForArtifact gets the blobs for a manifest that is neither an image
+// nor an index, such as an OCI artifact manifest
+func layersForArtifact(transport http.RoundTripper, ref name.Reference, desc *remote.Descriptor) ([]v1.Layer, error) {
+	m := &artifactManifest{}
+	if err := json.NewDecoder(bytes.NewReader(desc.Manifest)).Decode(m); err != nil {
+		return nil, err
+	}
+	descriptors := append(m.Layers, m.Blobs...)
+	if m.Config != nil {
+		descriptors = append(descriptors, *m.Config)
+	}
+	layers := make([]v1.Layer, len(descriptors))
+	for i, d := range descriptors {
+		layer, err := remote.Layer(ref.Context().Digest(d.Digest.String()), remote.WithTransport(transport))
+		if err != nil {
+			return nil, err
+		}
+		layers[i] = layer
+	}
+	return layers, nil
+}
+
+// artifactManifest covers the blob-referencing fields of both OCI image
+// manifests (with an artifactType) and the deprecated OCI artifact manifest
+type artifactManifest struct {
+	Config *v1.Descriptor  `json:"config,omitempty"`
+	Layers []v1.Descriptor `json:"layers,omitempty"`
+	Blobs  []v1.Descriptor `json:"blobs,omitempty"`
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// rawArtifact is a manifest with an arbitrary media type
+type rawArtifact struct {
+	raw       []byte
+	mediaType types.MediaType
+}
+
+func (r *rawArtifact) RawManifest() ([]byte, error)        { return r.raw, nil }
+func (r *rawArtifact) MediaType() (types.MediaType, error) { return r.mediaType, nil }
+
+func TestWalkImageLayersOCIReferrers(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.WithReferrersSupport(true)))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(u.Host + "/images/pause")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{}
+	push := func(ref name.Reference, img v1.Image) v1.Descriptor {
+		t.Helper()
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+		desc, err := partial.Descriptor(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, repo.Digest(desc.Digest.String()).String())
+		return *desc
+	}
+
+	// the signed image
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject := push(repo.Tag("v1"), img)
+
+	// a cosign signature, using the tag scheme
+	sig, err := random.Image(128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push(repo.Tag(strings.Replace(subject.Digest.String(), ":", "-", 1)+".sig"), sig)
+
+	// an attestation, using the OCI 1.1 subject field and not tagged
+	att, err := random.Image(128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	att = mutate.ConfigMediaType(att, "application/vnd.in-toto+json")
+	att = mutate.Subject(att, subject).(v1.Image)
+	attDigest, err := att.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	attDesc := push(repo.Digest(attDigest.String()), att)
+
+	// an SBOM attached to the attestation as a non-image artifact
+	sbom, err := random.Layer(64, "application/spdx+json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteLayer(repo, sbom); err != nil {
+		t.Fatal(err)
+	}
+	sbomDesc, err := partial.Descriptor(sbom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(map[string]any{
+		"mediaType":    "application/vnd.oci.artifact.manifest.v1+json",
+		"artifactType": "application/spdx+json",
+		"blobs":        []v1.Descriptor{*sbomDesc},
+		"subject":      attDesc,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifact := &rawArtifact{raw: raw, mediaType: "application/vnd.oci.artifact.manifest.v1+json"}
+	artifactDigest, _, err := v1.SHA256(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Put(repo.Digest(artifactDigest.String()), artifact); err != nil {
+		t.Fatal(err)
+	}
+	expected = append(expected, repo.Digest(artifactDigest.String()).String())
+
+	recorder := &imageRecorder{}
+	if err := WalkImageLayersOCI(http.DefaultTransport, []name.Repository{repo}, recorder.walk, noSkip, nil, nil); err != nil {
+		t.Fatalf("unexpected error walking: %v", err)
+	}
+	slices.Sort(expected)
+	if got := recorder.sorted(); !slices.Equal(got, expected) {
+		t.Fatalf("expected to walk %v but walked %v", expected, got)
+	}
+	if n := recorder.blobs[artifactDigest.String()]; n != 1 {
+		t.Fatalf("expected one blob for the artifact manifest, got %d", n)
+	}
+	if n := recorder.blobs[attDigest.String()]; n != 2 {
+		t.Fatalf("expected two blobs for the attestation, got %d", n)
+	}
+}
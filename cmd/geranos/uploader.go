@@ -0,0 +1,256 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	ggcrtypes "github.com/google/go-containerregistry/pkg/v1/types"
+
+	"k8s.io/klog/v2"
+)
+
+// see cmd/archeio, this matches the layout of GCR's GCS bucket
+// containers/images/sha256:$layer_digest
+const blobKeyPrefix = "containers/images/"
+
+// this is where geranos records manifests after uploading all of their blobs
+// cmd/archeio may serve these by digest, along with the manifest media type
+// recorded at the same key + mediaTypeKeySuffix
+const manifestKeyPrefix = "geranos/uploaded-images/"
+
+const mediaTypeKeySuffix = ".mediatype"
+
+// blobUploader mirrors images to a BlobStore
+type blobUploader struct {
+	store          BlobStore
+	reuploadLayers bool
+	dryRun         bool
+	blobs          *blobDeduper
+	progress       *progress
+}
+
+func newBlobUploader(store BlobStore, dryRun bool, p *progress) *blobUploader {
+	return &blobUploader{
+		store:    store,
+		dryRun:   dryRun,
+		blobs:    newBlobDeduper(),
+		progress: p,
+	}
+}
+
+func (u *blobUploader) UploadImage(ref name.Reference, layers []v1.Layer, opts ...crane.Option) error {
+	o := crane.GetOptions(opts...)
+	for _, layer := range layers {
+		blob := &remoteBlob{imageBlob: layer, repo: ref.Context(), keychain: o.Keychain, transport: o.Transport}
+		if err := u.copyLayer(blob); err != nil {
+			return err
+		}
+	}
+	m, err := manifestBlobFromRef(ref, opts...)
+	if err != nil {
+		return err
+	}
+	return u.copyManifest(m)
+}
+
+// DedupStats returns a summary of blob copies skipped during this run
+func (u *blobUploader) DedupStats() blobDedupStats {
+	return u.blobs.Stats()
+}
+
+func (u *blobUploader) ImageAlreadyUploaded(imageDigest string) (bool, error) {
+	// the media type is the last thing we upload for an image
+	key := keyForImageRecord(imageDigest)
+	exists, err := u.store.Exists(context.TODO(), key+mediaTypeKeySuffix)
+	if err != nil || exists {
+		return exists, err
+	}
+	// images uploaded before we recorded media types only have the manifest,
+	// backfill the media type from it rather than uploading the image again
+	exists, err = u.store.Exists(context.TODO(), key)
+	if err != nil || !exists {
+		return false, err
+	}
+	return u.backfillMediaType(key)
+}
+
+// backfillMediaType records the media type of the manifest already uploaded
+// at key, returning false if it cannot be determined from the manifest
+func (u *blobUploader) backfillMediaType(key string) (bool, error) {
+	r, err := u.store.Get(context.TODO(), key)
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+	// schema 1 manifests do not have a mediaType field, those are uploaded
+	// again, which also records their media type
+	var manifest struct {
+		MediaType ggcrtypes.MediaType `json:"mediaType"`
+	}
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil || manifest.MediaType == "" {
+		klog.V(4).Infof("Cannot backfill media type for %s: %v", key, err)
+		return false, nil
+	}
+	klog.Infof("Backfilling media type for: %s", key)
+	if err := u.copyMediaType(key, manifest.MediaType); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// imageBlob requires the subset of v1.Layer methods
+// required for uploading a blob
+type imageBlob interface {
+	Digest() (v1.Hash, error)
+	Compressed() (io.ReadCloser, error)
+	Size() (int64, error)
+}
+
+type manifestBlob struct {
+	raw       []byte
+	digest    v1.Hash
+	mediaType ggcrtypes.MediaType
+}
+
+func manifestBlobFromRef(ref name.Reference, opts ...crane.Option) (*manifestBlob, error) {
+	if _, ok := ref.(name.Digest); !ok {
+		return nil, errors.New("invalid reference, expected a digest: " + ref.String())
+	}
+	// the manifest is verified against the digest of ref when fetched
+	desc, err := crane.Get(ref.Name(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &manifestBlob{
+		raw:       desc.Manifest,
+		digest:    desc.Digest,
+		mediaType: desc.MediaType,
+	}, nil
+}
+
+func (m *manifestBlob) Digest() (v1.Hash, error) {
+	return m.digest, nil
+}
+
+func (m *manifestBlob) Compressed() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(m.raw)), nil
+}
+
+func (m *manifestBlob) Size() (int64, error) {
+	return int64(len(m.raw)), nil
+}
+
+func (u *blobUploader) copyManifest(m *manifestBlob) error {
+	key := keyForImageRecord(m.digest.String())
+	if err := u.dedupCopy(key, m.digest, m); err != nil {
+		return err
+	}
+	// the media type is written last, see ImageAlreadyUploaded
+	return u.copyMediaType(key, m.mediaType)
+}
+
+// copyMediaType records mediaType for the manifest at key
+func (u *blobUploader) copyMediaType(key string, mediaType ggcrtypes.MediaType) error {
+	blob := static.NewLayer([]byte(mediaType), ggcrtypes.MediaType("text/plain"))
+	sum := sha256.Sum256([]byte(mediaType))
+	digest := v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(sum[:])}
+	return u.dedupCopy(key+mediaTypeKeySuffix, digest, blob)
+}
+
+func (u *blobUploader) copyLayer(layer imageBlob) error {
+	digest, err := layer.Digest()
+	if err != nil {
+		return err
+	}
+	key := keyForLayer(digest.String())
+	return u.dedupCopy(key, digest, layer)
+}
+
+// dedupCopy is copy, but each key is only copied once per run
+func (u *blobUploader) dedupCopy(key string, digest v1.Hash, layer imageBlob) error {
+	deduplicated, err := u.blobs.Do(key, func() error {
+		return u.copy(key, digest, layer)
+	})
+	if err != nil || !deduplicated {
+		return err
+	}
+	klog.V(4).Infof("Already copied this run: %s", key)
+	u.progress.BlobSkipped()
+	// this is only used for the summary, so don't fail on it
+	if size, err := layer.Size(); err == nil {
+		u.blobs.RecordBytesSaved(size)
+	}
+	return nil
+}
+
+// copy copies layer with digest to key
+func (u *blobUploader) copy(key string, digest v1.Hash, layer imageBlob) error {
+	if !u.reuploadLayers {
+		exists, err := u.store.Exists(context.TODO(), key)
+		u.progress.BlobChecked()
+		if err != nil {
+			klog.Errorf("failed to check if blob exists: %v", err)
+		} else if exists {
+			klog.V(4).Infof("Layer already exists: %s", key)
+			u.progress.BlobSkipped()
+			return nil
+		}
+	}
+	// verify the content while we upload it, if it does not match the upload
+	// will fail when we reach the end of the content
+	//
+	// the content is only opened when the upload starts reading it, a resumed
+	// upload may skip to an offset first, see multipartUploader
+	body, err := newResumableVerifyingReader(openBlob(layer), digest)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	// skip actually uploading if this is a dry-run, otherwise finally upload
+	klog.Infof("Uploading: %s", key)
+	if u.dryRun {
+		return nil
+	}
+	size, err := layer.Size()
+	if err != nil {
+		return err
+	}
+	if err := u.store.Put(context.TODO(), key, body, size, digest); err != nil {
+		return err
+	}
+	u.progress.BlobUploaded(size)
+	return nil
+}
+
+func keyForLayer(digest string) string {
+	return blobKeyPrefix + digest
+}
+
+func keyForImageRecord(imageDigest string) string {
+	return manifestKeyPrefix + imageDigest
+}
@@ -0,0 +1,484 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// memBlobStore is an in memory BlobStore for tests, each operation fails with
+// the corresponding error if it is set
+type memBlobStore struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	existsErr error
+	putErr    error
+	getErr    error
+}
+
+func newMemBlobStore() *memBlobStore {
+	return &memBlobStore{blobs: map[string][]byte{}}
+}
+
+func (m *memBlobStore) Exists(_ context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.existsErr != nil {
+		return false, m.existsErr
+	}
+	_, exists := m.blobs[key]
+	return exists, nil
+}
+
+func (m *memBlobStore) Put(_ context.Context, key string, r io.Reader, _ int64, _ v1.Hash) error {
+	if m.putErr != nil {
+		return m.putErr
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[key] = b
+	return nil
+}
+
+func (m *memBlobStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	b, exists := m.blobs[key]
+	if !exists {
+		return nil, errBlobNotFound
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (m *memBlobStore) List(_ context.Context, prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := []string{}
+	for key := range m.blobs {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m *memBlobStore) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blobs, key)
+	return nil
+}
+
+// fakeBlob is an imageBlob, each method fails with the corresponding error
+// if it is set
+type fakeBlob struct {
+	digest        v1.Hash
+	content       []byte
+	digestErr     error
+	compressedErr error
+	sizeErr       error
+}
+
+func (f *fakeBlob) Digest() (v1.Hash, error) {
+	return f.digest, f.digestErr
+}
+
+func (f *fakeBlob) Compressed() (io.ReadCloser, error) {
+	if f.compressedErr != nil {
+		return nil, f.compressedErr
+	}
+	return io.NopCloser(bytes.NewReader(f.content)), nil
+}
+
+func (f *fakeBlob) Size() (int64, error) {
+	return int64(len(f.content)), f.sizeErr
+}
+
+func TestBlobUploaderSHA512(t *testing.T) {
+	content := []byte("content")
+	sum := sha512.Sum512(content)
+	digest := v1.Hash{Algorithm: "sha512", Hex: hex.EncodeToString(sum[:])}
+
+	store := newMemBlobStore()
+	uploader := newBlobUploader(store, false, nil)
+	if err := uploader.copyLayer(&fakeBlob{digest: digest, content: content}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if uploaded := string(store.blobs[keyForLayer(digest.String())]); uploaded != string(content) {
+		t.Fatalf("expected content to be uploaded, but got: %q", uploaded)
+	}
+
+	// content that does not match must not be stored
+	corrupted := &fakeBlob{digest: digest, content: []byte("corrupted")}
+	store = newMemBlobStore()
+	uploader = newBlobUploader(store, false, nil)
+	if err := uploader.copyLayer(corrupted); !errors.Is(err, errDigestMismatch) {
+		t.Fatalf("expected errDigestMismatch but got: %v", err)
+	}
+	if _, exists := store.blobs[keyForLayer(digest.String())]; exists {
+		t.Fatal("expected corrupted content not to be uploaded")
+	}
+}
+
+func TestImageAlreadyUploadedBackfillsMediaType(t *testing.T) {
+	const (
+		imageDigest = "sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e"
+		mediaType   = "application/vnd.oci.image.manifest.v1+json"
+	)
+	key := keyForImageRecord(imageDigest)
+	testCases := []struct {
+		Name              string
+		Blobs             map[string]string
+		DryRun            bool
+		ExpectedUploaded  bool
+		ExpectedMediaType string
+	}{
+		{
+			Name:             "not uploaded",
+			ExpectedUploaded: false,
+		},
+		{
+			Name: "uploaded with media type",
+			Blobs: map[string]string{
+				key:                      `{"mediaType":"` + mediaType + `"}`,
+				key + mediaTypeKeySuffix: mediaType,
+			},
+			ExpectedUploaded:  true,
+			ExpectedMediaType: mediaType,
+		},
+		{
+			Name: "uploaded before media types were recorded",
+			Blobs: map[string]string{
+				key: `{"schemaVersion":2,"mediaType":"` + mediaType + `"}`,
+			},
+			ExpectedUploaded:  true,
+			ExpectedMediaType: mediaType,
+		},
+		{
+			Name: "dry run does not backfill",
+			Blobs: map[string]string{
+				key: `{"schemaVersion":2,"mediaType":"` + mediaType + `"}`,
+			},
+			DryRun:           true,
+			ExpectedUploaded: true,
+		},
+		{
+			Name: "schema 1 manifest without media type",
+			Blobs: map[string]string{
+				key: `{"schemaVersion":1}`,
+			},
+			ExpectedUploaded: false,
+		},
+		{
+			Name: "unparsable manifest",
+			Blobs: map[string]string{
+				key: `{`,
+			},
+			ExpectedUploaded: false,
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			store := newMemBlobStore()
+			for k, v := range tc.Blobs {
+				store.blobs[k] = []byte(v)
+			}
+			uploader := newBlobUploader(store, tc.DryRun, nil)
+			uploaded, err := uploader.ImageAlreadyUploaded(imageDigest)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if uploaded != tc.ExpectedUploaded {
+				t.Fatalf("expected uploaded: %v, but got: %v", tc.ExpectedUploaded, uploaded)
+			}
+			if recorded := string(store.blobs[key+mediaTypeKeySuffix]); recorded != tc.ExpectedMediaType {
+				t.Fatalf("expected media type: %q, but got: %q", tc.ExpectedMediaType, recorded)
+			}
+		})
+	}
+}
+
+func TestImageAlreadyUploadedErrors(t *testing.T) {
+	const imageDigest = "sha256:da86e6ba6ca197bf6bc5e9d900febd906b133eaa4750e6bed647b0fbe50ed43e"
+	key := keyForImageRecord(imageDigest)
+	manifest := []byte(`{"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	failed := errors.New("failed")
+	testCases := []struct {
+		Name  string
+		Store *memBlobStore
+	}{
+		{
+			Name:  "exists fails",
+			Store: &memBlobStore{blobs: map[string][]byte{}, existsErr: failed},
+		},
+		{
+			Name:  "get fails",
+			Store: &memBlobStore{blobs: map[string][]byte{key: manifest}, getErr: failed},
+		},
+		{
+			Name:  "put fails",
+			Store: &memBlobStore{blobs: map[string][]byte{key: manifest}, putErr: failed},
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			uploader := newBlobUploader(tc.Store, false, nil)
+			uploaded, err := uploader.ImageAlreadyUploaded(imageDigest)
+			if !errors.Is(err, failed) || uploaded {
+				t.Fatalf("expected not uploaded with error, but got: %v, %v", uploaded, err)
+			}
+		})
+	}
+}
+
+// failingLayer is a v1.Layer whose Digest fails
+type failingLayer struct {
+	v1.Layer
+}
+
+func (failingLayer) Digest() (v1.Hash, error) {
+	return v1.Hash{}, errors.New("failed")
+}
+
+func TestUploadImageErrors(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgDigest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewDigest(u.Host + "/images/pause@" + imgDigest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the manifest is fetched by digest
+	m, err := manifestBlobFromRef(ref)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if digest, err := m.Digest(); err != nil || digest != imgDigest {
+		t.Fatalf("expected manifest digest %s, got %s, %v", imgDigest, digest, err)
+	}
+	missing, err := name.NewDigest(u.Host + "/images/missing@" + imgDigest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag(u.Host + "/images/pause:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := errors.New("failed")
+	testCases := []struct {
+		Name   string
+		Ref    name.Reference
+		Layers []v1.Layer
+		Store  *memBlobStore
+	}{
+		{
+			Name:   "layer fails",
+			Ref:    ref,
+			Layers: []v1.Layer{failingLayer{layers[0]}},
+			Store:  newMemBlobStore(),
+		},
+		{
+			Name:  "tag instead of digest",
+			Ref:   tag,
+			Store: newMemBlobStore(),
+		},
+		{
+			Name:  "manifest is missing",
+			Ref:   missing,
+			Store: newMemBlobStore(),
+		},
+		{
+			Name:  "manifest upload fails",
+			Ref:   ref,
+			Store: &memBlobStore{blobs: map[string][]byte{}, putErr: failed},
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			uploader := newBlobUploader(tc.Store, false, nil)
+			if err := uploader.UploadImage(tc.Ref, tc.Layers); err == nil {
+				t.Fatal("expected error but got none")
+			}
+			if len(tc.Store.blobs) != 0 {
+				t.Fatalf("expected nothing to be uploaded, got %d blobs", len(tc.Store.blobs))
+			}
+		})
+	}
+}
+
+func TestBlobUploaderCopy(t *testing.T) {
+	content := []byte("content")
+	sum := sha512.Sum512(content)
+	digest := v1.Hash{Algorithm: "sha512", Hex: hex.EncodeToString(sum[:])}
+	key := keyForLayer(digest.String())
+	failed := errors.New("failed")
+	testCases := []struct {
+		Name             string
+		Blob             *fakeBlob
+		Store            *memBlobStore
+		DryRun           bool
+		ExpectError      bool
+		ExpectedUploaded bool
+	}{
+		{
+			Name:             "uploaded",
+			Blob:             &fakeBlob{digest: digest, content: content},
+			Store:            newMemBlobStore(),
+			ExpectedUploaded: true,
+		},
+		{
+			Name:             "failing to check if it exists uploads anyway",
+			Blob:             &fakeBlob{digest: digest, content: content},
+			Store:            &memBlobStore{blobs: map[string][]byte{}, existsErr: failed},
+			ExpectedUploaded: true,
+		},
+		{
+			Name:   "dry run",
+			Blob:   &fakeBlob{digest: digest, content: content},
+			Store:  newMemBlobStore(),
+			DryRun: true,
+		},
+		{
+			Name:        "digest fails",
+			Blob:        &fakeBlob{digestErr: failed},
+			Store:       newMemBlobStore(),
+			ExpectError: true,
+		},
+		{
+			Name:        "unsupported digest",
+			Blob:        &fakeBlob{digest: v1.Hash{Algorithm: "md5", Hex: "9a0364b9e99bb480dd25e1f0284c8555"}},
+			Store:       newMemBlobStore(),
+			ExpectError: true,
+		},
+		{
+			Name:        "size fails",
+			Blob:        &fakeBlob{digest: digest, content: content, sizeErr: failed},
+			Store:       newMemBlobStore(),
+			ExpectError: true,
+		},
+		{
+			Name:        "upload fails",
+			Blob:        &fakeBlob{digest: digest, content: content},
+			Store:       &memBlobStore{blobs: map[string][]byte{}, putErr: failed},
+			ExpectError: true,
+		},
+	}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			uploader := newBlobUploader(tc.Store, tc.DryRun, nil)
+			err := uploader.copyLayer(tc.Blob)
+			if tc.ExpectError != (err != nil) {
+				t.Fatalf("expected error: %v, but got: %v", tc.ExpectError, err)
+			}
+			if _, uploaded := tc.Store.blobs[key]; uploaded != tc.ExpectedUploaded {
+				t.Fatalf("expected uploaded: %v, but got: %v", tc.ExpectedUploaded, uploaded)
+			}
+		})
+	}
+}
+
+func TestBlobUploaderSkipsExisting(t *testing.T) {
+	content := []byte("content")
+	sum := sha512.Sum512(content)
+	digest := v1.Hash{Algorithm: "sha512", Hex: hex.EncodeToString(sum[:])}
+	key := keyForLayer(digest.String())
+
+	// blobs already in the store are not uploaded again
+	store := newMemBlobStore()
+	store.blobs[key] = []byte("existing")
+	uploader := newBlobUploader(store, false, nil)
+	if err := uploader.copyLayer(&fakeBlob{digest: digest, content: content}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(store.blobs[key]) != "existing" {
+		t.Fatal("expected existing blob not to be uploaded again")
+	}
+
+	// blobs are only copied once per run, even if they are reuploaded
+	store = newMemBlobStore()
+	uploader = newBlobUploader(store, false, nil)
+	uploader.reuploadLayers = true
+	if err := uploader.copyLayer(&fakeBlob{digest: digest, content: content}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	delete(store.blobs, key)
+	if err := uploader.copyLayer(&fakeBlob{digest: digest, content: content}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// failing to get the size of a skipped blob only affects the summary
+	if err := uploader.copyLayer(&fakeBlob{digest: digest, content: content, sizeErr: errors.New("failed")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, uploaded := store.blobs[key]; uploaded {
+		t.Fatal("expected blob to be copied once")
+	}
+	if stats := uploader.DedupStats(); stats.Deduplicated != 2 || stats.BytesSaved != int64(len(content)) {
+		t.Fatalf("expected 2 skipped blobs saving %d bytes, got %+v", len(content), stats)
+	}
+}
@@ -37,15 +37,20 @@ type WalkImageLayersFunc func(ref name.Reference, layers []v1.Layer) error
 
 // Unfortunately this is only doable on GCP currently.
 //
-// TODO: To support other registries in the meantime, we could require a list of
-// image names as an input and plumb that through, then list tags and get something
-// close to this. The _catalog endpoint + tag listing could also work in some cases.
+// For other registries see WalkImageLayersOCI, which uses a list of
+// repositories or the _catalog endpoint + tag listing instead.
 //
-// However, even then, this is more complete because it lists all manifests, not just tags.
+// However, this is more complete because it lists all manifests, not just tags.
 // It's also simpler and more efficient.
 //
+// Because all manifests are listed, this includes cosign signatures and
+// attestations as well as OCI referrers, without needing to discover them.
+//
 // See: https://github.com/opencontainers/distribution-spec/issues/222
-func WalkImageLayersGCP(transport http.RoundTripper, repo name.Repository, walkImageLayers WalkImageLayersFunc, skipImage func(string) bool) error {
+//
+// Failures walking individual images and repositories are handled by failures,
+// which may retry them and continue with the rest of the walk.
+func WalkImageLayersGCP(transport http.RoundTripper, repo name.Repository, walkImageLayers WalkImageLayersFunc, skipImage func(string) bool, p *progress, failures *imageFailures) error {
 	g := new(errgroup.Group)
 	// TODO: This is really just an approximation to avoid exceeding typical socket limits
 	// See also quota limits:
@@ -54,7 +59,7 @@ func WalkImageLayersGCP(transport http.RoundTripper, repo name.Repository, walkI
 	g.Go(func() error {
 		return google.Walk(repo, func(r name.Repository, tags *google.Tags, err error) error {
 			if err != nil {
-				return err
+				return failures.Record(r, err)
 			}
 			for digest, metadata := range tags.Manifests {
 				digest := digest
@@ -62,6 +67,13 @@ func WalkImageLayersGCP(transport http.RoundTripper, repo name.Repository, walkI
 				if metadata.MediaType == string(types.DockerManifestList) || metadata.MediaType == string(types.OCIImageIndex) {
 					continue
 				}
+				if !isFetchableDigest(digest) {
+					// fail loudly instead of mirroring the repository incompletely
+					if err := failures.Record(r, fmt.Errorf("%w: %s", errUnsupportedDigest, digest)); err != nil {
+						return err
+					}
+					continue
+				}
 				ref, err := name.ParseReference(fmt.Sprintf("%s@%s", r, digest))
 				if err != nil {
 					return err
@@ -71,9 +83,12 @@ func WalkImageLayersGCP(transport http.RoundTripper, repo name.Repository, walkI
 						klog.V(4).Infof("Skipping already-uploaded: %s", ref)
 						return nil
 					}
-					return walkManifestLayers(transport, ref, walkImageLayers)
+					return failures.Do(ref, func() error {
+						return walkManifestLayers(transport, ref, walkImageLayers)
+					})
 				})
 			}
+			p.RepositoryWalked()
 			return nil
 		}, google.WithTransport(transport))
 	})
@@ -102,10 +117,14 @@ func walkManifestLayers(transport http.RoundTripper, ref name.Reference, walkIma
 		return walkImageLayers(ref, layers)
 	}
 
-	// we don't expect anything other than index, or image ...
+	// anything else may be an artifact such as a signature, SBOM or
+	// attestation, mirror whatever blobs it references
 	if !desc.MediaType.IsImage() {
-		klog.Warningf("Un-handled type: %s for %s", desc.MediaType, ref.String())
-		return nil
+		layers, err := layersForArtifact(transport, ref, desc)
+		if err != nil {
+			return err
+		}
+		return walkImageLayers(ref, layers)
 	}
 
 	// Handle normal images
@@ -0,0 +1,166 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"k8s.io/klog/v2"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// CatalogRepositories lists the repositories under root using the _catalog API
+//
+// _catalog is not part of the OCI distribution spec and not every registry
+// implements it (or allows it for all users), when it is unavailable the
+// repositories to walk must be supplied explicitly instead.
+func CatalogRepositories(transport http.RoundTripper, root name.Repository) ([]name.Repository, error) {
+	all, err := remote.Catalog(context.Background(), root.Registry, remote.WithTransport(transport))
+	if err != nil {
+		return nil, err
+	}
+	prefix := root.RepositoryStr()
+	repos := []name.Repository{}
+	for _, r := range all {
+		if r != prefix && !strings.HasPrefix(r, prefix+"/") {
+			continue
+		}
+		repo, err := name.NewRepository(root.RegistryStr() + "/" + r)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
+
+// WalkImageLayersOCI is like WalkImageLayersGCP, but only uses standard
+// OCI distribution APIs, so it works with any registry.
+//
+// Unlike WalkImageLayersGCP, this can only find manifests that are reachable
+// from a tag, either directly or via an index, or that are attached to those
+// as a referrer or cosign signature, attestation or SBOM.
+//
+// Failures walking individual images and repositories are handled by failures,
+// which may retry them and continue with the rest of the walk.
+func WalkImageLayersOCI(transport http.RoundTripper, repos []name.Repository, walkImageLayers WalkImageLayersFunc, skipImage func(string) bool, p *progress, failures *imageFailures) error {
+	g := new(errgroup.Group)
+	// TODO: This is really just an approximation to avoid exceeding typical socket limits
+	g.SetLimit(1000)
+	// many tags typically point to the same manifest, only walk each once
+	seen := &sync.Map{}
+	g.Go(func() error {
+		for _, repo := range repos {
+			var tags []string
+			if err := failures.Do(repo, func() error {
+				var err error
+				tags, err = remote.List(repo, remote.WithTransport(transport))
+				return err
+			}); err != nil {
+				return err
+			}
+			for _, tag := range tags {
+				ref := repo.Tag(tag)
+				g.Go(func() error {
+					var desc *v1.Descriptor
+					if err := failures.Do(ref, func() error {
+						var err error
+						desc, err = remote.Head(ref, remote.WithTransport(transport))
+						return err
+					}); err != nil || desc == nil {
+						return err
+					}
+					return walkDescriptorOCI(transport, repo, desc.Digest, desc.MediaType, seen, walkImageLayers, skipImage, failures)
+				})
+			}
+			p.RepositoryWalked()
+		}
+		return nil
+	})
+	return g.Wait()
+}
+
+// walkDescriptorOCI walks the manifest digest in repo, recursing into indexes
+// and anything attached to the manifest
+func walkDescriptorOCI(transport http.RoundTripper, repo name.Repository, digest v1.Hash, mediaType types.MediaType, seen *sync.Map, walkImageLayers WalkImageLayersFunc, skipImage func(string) bool, failures *imageFailures) error {
+	ref := repo.Digest(digest.String())
+	if _, loaded := seen.LoadOrStore(ref.String(), struct{}{}); loaded {
+		return nil
+	}
+	if err := walkManifestOCI(transport, ref, mediaType, seen, walkImageLayers, skipImage, failures); err != nil {
+		return err
+	}
+
+	// signatures etc. may be attached to images after they were uploaded, and
+	// even to other attached artifacts, so we check everything we walk
+	var referrers []v1.Descriptor
+	if err := failures.Do(ref, func() error {
+		var err error
+		referrers, err = referrersOf(transport, repo, digest)
+		return err
+	}); err != nil {
+		return err
+	}
+	for _, referrer := range referrers {
+		if err := walkDescriptorOCI(transport, repo, referrer.Digest, referrer.MediaType, seen, walkImageLayers, skipImage, failures); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkManifestOCI walks a single manifest, which may be an index
+func walkManifestOCI(transport http.RoundTripper, ref name.Digest, mediaType types.MediaType, seen *sync.Map, walkImageLayers WalkImageLayersFunc, skipImage func(string) bool, failures *imageFailures) error {
+	repo := ref.Context()
+	// unlike google.Walk, we have to resolve indexes to their child manifests
+	if mediaType.IsIndex() {
+		var manifest *v1.IndexManifest
+		if err := failures.Do(ref, func() error {
+			index, err := remote.Index(ref, remote.WithTransport(transport))
+			if err != nil {
+				return err
+			}
+			manifest, err = index.IndexManifest()
+			return err
+		}); err != nil || manifest == nil {
+			return err
+		}
+		for _, child := range manifest.Manifests {
+			if err := walkDescriptorOCI(transport, repo, child.Digest, child.MediaType, seen, walkImageLayers, skipImage, failures); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if skipImage(ref.DigestStr()) {
+		klog.V(4).Infof("Skipping already-uploaded: %s", ref)
+		return nil
+	}
+	return failures.Do(ref, func() error {
+		return walkManifestLayers(transport, ref, walkImageLayers)
+	})
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// testRegistry is an in-memory registry populated with some test images
+type testRegistry struct {
+	root name.Repository
+	// expected image manifest references, by digest
+	images []string
+	// image digest to the number of blobs (layers + config) in the image
+	blobs map[string]int
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	t.Helper()
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := name.NewRepository(u.Host + "/images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &testRegistry{root: root, blobs: map[string]int{}}
+
+	// an image with multiple tags
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range []string{"v1", "latest"} {
+		r.push(t, "images/pause", tag, img)
+	}
+	r.addImage(t, "images/pause", img)
+
+	// a multi-platform index in a nested repo
+	index, err := random.Index(512, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexRef := r.ref(t, "images/nested/etcd", "3.5")
+	if err := remote.WriteIndex(indexRef, index); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, desc := range manifest.Manifests {
+		child, err := index.Image(desc.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.addImage(t, "images/nested/etcd", child)
+	}
+
+	// an image outside of the root repo that should not be walked
+	other, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.push(t, "other/image", "v1", other)
+
+	sort.Strings(r.images)
+	return r
+}
+
+func (r *testRegistry) ref(t *testing.T, repo, tag string) name.Tag {
+	t.Helper()
+	ref, err := name.NewTag(r.root.RegistryStr() + "/" + repo + ":" + tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ref
+}
+
+func (r *testRegistry) push(t *testing.T, repo, tag string, img v1.Image) {
+	t.Helper()
+	if err := remote.Write(r.ref(t, repo, tag), img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (r *testRegistry) addImage(t *testing.T, repo string, img v1.Image) {
+	t.Helper()
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.images = append(r.images, r.root.RegistryStr()+"/"+repo+"@"+digest.String())
+	r.blobs[digest.String()] = len(layers) + 1
+}
+
+// imageRecorder is a WalkImageLayersFunc that records what it visits
+type imageRecorder struct {
+	mu     sync.Mutex
+	images []string
+	blobs  map[string]int
+}
+
+func (i *imageRecorder) walk(ref name.Reference, layers []v1.Layer) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.blobs == nil {
+		i.blobs = map[string]int{}
+	}
+	i.images = append(i.images, ref.String())
+	i.blobs[ref.Identifier()] = len(layers)
+	return nil
+}
+
+func (i *imageRecorder) sorted() []string {
+	sort.Strings(i.images)
+	return i.images
+}
+
+func noSkip(string) bool { return false }
+
+func TestWalkImageLayersOCICatalog(t *testing.T) {
+	r := newTestRegistry(t)
+	repos, err := CatalogRepositories(http.DefaultTransport, r.root)
+	if err != nil {
+		t.Fatalf("unexpected error listing catalog: %v", err)
+	}
+	if len(repos) != 2 {
+		t.Fatalf("expected two repos under %s, got: %v", r.root, repos)
+	}
+	recorder := &imageRecorder{}
+	if err := WalkImageLayersOCI(http.DefaultTransport, repos, recorder.walk, noSkip, nil, nil); err != nil {
+		t.Fatalf("unexpected error walking: %v", err)
+	}
+	if got := recorder.sorted(); !slices.Equal(got, r.images) {
+		t.Fatalf("expected to walk images %v but walked %v", r.images, got)
+	}
+	for digest, expected := range r.blobs {
+		if recorder.blobs[digest] != expected {
+			t.Errorf("expected %d blobs for %s, got %d", expected, digest, recorder.blobs[digest])
+		}
+	}
+}
+
+func TestWalkImageLayersOCIRepoListAndSkip(t *testing.T) {
+	r := newTestRegistry(t)
+	repos, err := sourceRepos(http.DefaultTransport, r.root, "pause, nested/etcd")
+	if err != nil {
+		t.Fatalf("unexpected error parsing repos: %v", err)
+	}
+	recorder := &imageRecorder{}
+	skipped := r.images[0]
+	skipImage := func(digest string) bool {
+		return skipped == r.root.RegistryStr()+"/images/pause@"+digest ||
+			skipped == r.root.RegistryStr()+"/images/nested/etcd@"+digest
+	}
+	if err := WalkImageLayersOCI(http.DefaultTransport, repos, recorder.walk, skipImage, nil, nil); err != nil {
+		t.Fatalf("unexpected error walking: %v", err)
+	}
+	if got := recorder.sorted(); !slices.Equal(got, r.images[1:]) {
+		t.Fatalf("expected to walk images %v but walked %v", r.images[1:], got)
+	}
+}
+
+func TestWalkImageLayersOCIMissingRepo(t *testing.T) {
+	r := newTestRegistry(t)
+	repos, err := sourceRepos(http.DefaultTransport, r.root, "does-not-exist")
+	if err != nil {
+		t.Fatalf("unexpected error parsing repos: %v", err)
+	}
+	recorder := &imageRecorder{}
+	if err := WalkImageLayersOCI(http.DefaultTransport, repos, recorder.walk, noSkip, nil, nil); err == nil {
+		t.Fatal("expected error walking non-existent repo")
+	}
+}
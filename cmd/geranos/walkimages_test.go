@@ -0,0 +1,73 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestWalkImageLayersGCPUnsupportedDigest(t *testing.T) {
+	// a repository listing a manifest we cannot fetch
+	digest := testDigest("sha512", []byte("manifest"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/images/tags/list":
+			fmt.Fprintf(w, `{"name": "images", "child": [], "tags": [], "manifest": {%q: {"mediaType": "application/vnd.oci.image.manifest.v1+json", "imageSizeBytes": "1", "timeCreatedMs": "0", "timeUploadedMs": "0", "tag": []}}}`, digest)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := name.NewRepository(u.Host + "/images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	walk := func(ref name.Reference, _ []v1.Layer) error {
+		t.Errorf("unexpected walk of %s", ref)
+		return nil
+	}
+
+	// the manifest must fail the walk instead of being skipped
+	failures := &imageFailures{attempts: 1}
+	err = WalkImageLayersGCP(http.DefaultTransport, repo, walk, noSkip, nil, failures)
+	if !errors.Is(err, errUnsupportedDigest) {
+		t.Fatalf("expected unsupported digest error but got: %v", err)
+	}
+
+	// or be reported when continuing on errors
+	failures = &imageFailures{continueOnError: true, attempts: 1}
+	if err := WalkImageLayersGCP(http.DefaultTransport, repo, walk, noSkip, nil, failures); err != nil {
+		t.Fatalf("unexpected error walking: %v", err)
+	}
+	if got := failures.Failures(); len(got) != 1 || got[0].Reference != repo.String() {
+		t.Fatalf("expected %s to fail, got: %+v", repo, got)
+	}
+}
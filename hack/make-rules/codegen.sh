@@ -23,14 +23,29 @@ cd "${REPO_ROOT}"
 
 source hack/tools/setup-go.sh
 
-echo "Downloading AWS, GCP & Azure IP ranges data..."
+echo "Downloading AWS, GCP, Azure, Oracle, DigitalOcean, Linode & Cloudflare IP ranges data..."
 curl -fLo 'pkg/net/cloudcidrs/internal/ranges2go/data/aws-ip-ranges.json' 'https://ip-ranges.amazonaws.com/ip-ranges.json'
+# NOTE: ip-ranges.json also covers the aws-cn partition (cn-north-1 and cn-northwest-1)
 curl -fLo 'pkg/net/cloudcidrs/internal/ranges2go/data/gcp-cloud.json' 'https://www.gstatic.com/ipranges/cloud.json'
 # NOTE: Azure Service Tags download URLs are dated and rotate weekly, this URL
 # must be periodically refreshed from:
 # https://www.microsoft.com/en-us/download/details.aspx?id=56519
 curl -fLo 'pkg/net/cloudcidrs/internal/ranges2go/data/azure-service-tags.json' 'https://download.microsoft.com/download/7/1/d/71d86715-5596-4529-9b13-da13a5de5b63/ServiceTags_Public_20260727.json'
 
+curl -fLo 'pkg/net/cloudcidrs/internal/ranges2go/data/oracle-public-ip-ranges.json' 'https://docs.oracle.com/en-us/iaas/tools/public_ip_ranges.json'
+curl -fLo 'pkg/net/cloudcidrs/internal/ranges2go/data/digitalocean-geofeed.csv' 'https://digitalocean.com/geo/google.csv'
+curl -fLo 'pkg/net/cloudcidrs/internal/ranges2go/data/linode-geofeed.csv' 'https://geoip.linode.com/'
+curl -fLo 'pkg/net/cloudcidrs/internal/ranges2go/data/cloudflare-ips-v4.txt' 'https://www.cloudflare.com/ips-v4'
+curl -fLo 'pkg/net/cloudcidrs/internal/ranges2go/data/cloudflare-ips-v6.txt' 'https://www.cloudflare.com/ips-v6'
+# NOTE: Hetzner and IBM Cloud geofeeds are not downloaded here, they may be
+# saved to hetzner-geofeed.csv and ibm-geofeed.csv in the data directory.
+# The data files for these clouds are optional, see optional_clouds.go, clouds
+# without data files are generated without any prefixes.
+
+# Additional RFC 8805 geofeeds, e.g. our own egress ranges or other clouds
+# which publish one, may be listed in
+# pkg/net/cloudcidrs/internal/ranges2go/data/geofeeds.json, see parse_geofeeds.go
+
 # AWS adds IP ranges for unreleased regions which we want to exclude
 EXCLUDED_AWS_REGIONS="me-west-1,sa-west-1,us-south-1" \
 OUT_FILE=pkg/net/cloudcidrs/zz_generated_range_data.go \
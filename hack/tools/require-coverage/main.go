@@ -43,11 +43,13 @@ var knownFailingFiles = set.New[string](
 	"k8s.io/registry.k8s.io/pkg/net/cloudcidrs/internal/ranges2go/gen.go",
 	// geranos is not easily tested and is not in the blocking path in production
 	// we should still test it better
+	"k8s.io/registry.k8s.io/cmd/geranos/failures.go",
 	"k8s.io/registry.k8s.io/cmd/geranos/main.go",
-	"k8s.io/registry.k8s.io/cmd/geranos/ratelimitroundtrip.go",
-	"k8s.io/registry.k8s.io/cmd/geranos/s3uploader.go",
+	"k8s.io/registry.k8s.io/cmd/geranos/progress.go",
+	"k8s.io/registry.k8s.io/cmd/geranos/referrers.go",
 	"k8s.io/registry.k8s.io/cmd/geranos/schemav1.go",
 	"k8s.io/registry.k8s.io/cmd/geranos/walkimages.go",
+	"k8s.io/registry.k8s.io/cmd/geranos/walkimages_oci.go",
 	// We cover this with integration tests and including integration coverage
 	// here would mask a lack of unit test coverage.
 	"k8s.io/registry.k8s.io/cmd/archeio/main.go",
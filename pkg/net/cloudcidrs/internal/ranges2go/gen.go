@@ -48,15 +48,15 @@ import (
 
 `
 
-func generateRangesGo(w io.Writer, cloudToRTP map[string]regionsToPrefixes) error {
+func generateRangesGo(w io.Writer, cloudToITP map[string]infoToPrefixes) error {
 	// generate source file header
 	if _, err := io.WriteString(w, fileHeader); err != nil {
 		return err
 	}
 
 	// ensure iteration order is predictable for reproducible codegen
-	clouds := make([]string, 0, len(cloudToRTP))
-	for cloud := range cloudToRTP {
+	clouds := make([]string, 0, len(cloudToITP))
+	for cloud := range cloudToITP {
 		clouds = append(clouds, cloud)
 	}
 	sort.Strings(clouds)
@@ -76,8 +76,8 @@ var regionToRanges = map[IPInfo][]netip.Prefix{
 		return err
 	}
 	for _, cloud := range clouds {
-		rtp := cloudToRTP[cloud]
-		if err := genCloud(w, cloud, rtp); err != nil {
+		itp := cloudToITP[cloud]
+		if err := genCloud(w, cloud, itp); err != nil {
 			return err
 		}
 	}
@@ -88,16 +88,33 @@ var regionToRanges = map[IPInfo][]netip.Prefix{
 	return nil
 }
 
-func genCloud(w io.Writer, cloud string, rtp regionsToPrefixes) error {
+func genCloud(w io.Writer, cloud string, itp infoToPrefixes) error {
 	// ensure iteration order is predictable for reproducible codegen
-	regions := make([]string, 0, len(rtp))
-	for region := range rtp {
-		regions = append(regions, region)
+	infos := make([]prefixInfo, 0, len(itp))
+	for info := range itp {
+		infos = append(infos, info)
 	}
-	sort.Strings(regions)
-	for _, region := range regions {
-		prefixes := rtp[region]
-		if _, err := fmt.Fprintf(w, "\t{Cloud: %s, Region: %q}: {\n", cloud, region); err != nil {
+	sort.Slice(infos, func(i, j int) bool {
+		a, b := infos[i], infos[j]
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		return a.NetworkBorderGroup < b.NetworkBorderGroup
+	})
+	for _, info := range infos {
+		prefixes := itp[info]
+		// only emit metadata we have, to keep the generated file smaller
+		key := fmt.Sprintf("Cloud: %s, Region: %q", cloud, info.Region)
+		if info.Service != "" {
+			key += fmt.Sprintf(", Service: %q", info.Service)
+		}
+		if info.NetworkBorderGroup != "" {
+			key += fmt.Sprintf(", NetworkBorderGroup: %q", info.NetworkBorderGroup)
+		}
+		if _, err := fmt.Fprintf(w, "\t{%s}: {\n", key); err != nil {
 			return err
 		}
 		for _, prefix := range prefixes {
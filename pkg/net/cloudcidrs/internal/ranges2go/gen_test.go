@@ -39,6 +39,30 @@ func TestGenerateRangesGo(t *testing.T) {
       "service": "AMAZON",
       "network_border_group": "me-south-1"
     },
+    {
+      "ip_prefix": "52.95.174.0/24",
+      "region": "me-south-1",
+      "service": "EC2",
+      "network_border_group": "me-south-1"
+    },
+    {
+      "ip_prefix": "15.230.0.0/24",
+      "region": "us-west-2",
+      "service": "EC2",
+      "network_border_group": "us-west-2-lax-1"
+    },
+    {
+      "ip_prefix": "34.208.0.0/12",
+      "region": "us-west-2",
+      "service": "EC2",
+      "network_border_group": "us-west-2"
+    },
+    {
+      "ip_prefix": "205.251.192.0/19",
+      "region": "GLOBAL",
+      "service": "CLOUDFRONT",
+      "network_border_group": "GLOBAL"
+    },
     {
       "ip_prefix": "15.185.0.0/16",
       "region": "me-south-1",
@@ -92,7 +116,7 @@ func TestGenerateRangesGo(t *testing.T) {
   ]
 }
 `
-	awsRTP, err := parseAWS(rawAWSData, []string{"me-south-10", "eu-south-10"})
+	awsITP, err := parseAWS(rawAWSData, []string{"me-south-10", "eu-south-10"})
 	if err != nil {
 		t.Fatalf("unexpected error parsing test data: %v", err)
 	}
@@ -126,7 +150,7 @@ func TestGenerateRangesGo(t *testing.T) {
   }]
 }
 `
-	gcpRTP, err := parseGCP(rawGCPData)
+	gcpITP, err := parseGCP(rawGCPData)
 	if err != nil {
 		t.Fatalf("unexpected error parsing test data: %v", err)
 	}
@@ -173,20 +197,31 @@ const GCP = "GCP"
 
 // regionToRanges contains a preparsed map of cloud IPInfo to netip.Prefix
 var regionToRanges = map[IPInfo][]netip.Prefix{
-	{Cloud: AWS, Region: "ap-northeast-2"}: {
+	{Cloud: AWS, Region: "GLOBAL", Service: "CLOUDFRONT"}: {
+		netip.PrefixFrom(netip.AddrFrom4([4]byte{205, 251, 192, 0}), 19),
+	},
+	{Cloud: AWS, Region: "ap-northeast-2", Service: "AMAZON"}: {
 		netip.PrefixFrom(netip.AddrFrom4([4]byte{3, 5, 140, 0}), 22),
 	},
-	{Cloud: AWS, Region: "eu-south-1"}: {
+	{Cloud: AWS, Region: "eu-south-1", Service: "AMAZON"}: {
 		netip.PrefixFrom(netip.AddrFrom16([16]byte{42, 5, 208, 58, 160, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0}), 56),
 		netip.PrefixFrom(netip.AddrFrom16([16]byte{42, 5, 208, 58, 160, 0, 4, 0, 0, 0, 0, 0, 0, 0, 0, 0}), 56),
 		netip.PrefixFrom(netip.AddrFrom16([16]byte{42, 5, 208, 58, 160, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}), 56),
 		netip.PrefixFrom(netip.AddrFrom16([16]byte{42, 5, 208, 122, 160, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}), 40),
 	},
-	{Cloud: AWS, Region: "me-south-1"}: {
+	{Cloud: AWS, Region: "me-south-1", Service: "AMAZON"}: {
 		netip.PrefixFrom(netip.AddrFrom4([4]byte{15, 185, 0, 0}), 16),
-		netip.PrefixFrom(netip.AddrFrom4([4]byte{52, 95, 174, 0}), 24),
 		netip.PrefixFrom(netip.AddrFrom4([4]byte{69, 107, 7, 136}), 29),
 	},
+	{Cloud: AWS, Region: "me-south-1", Service: "EC2"}: {
+		netip.PrefixFrom(netip.AddrFrom4([4]byte{52, 95, 174, 0}), 24),
+	},
+	{Cloud: AWS, Region: "us-west-2", Service: "EC2"}: {
+		netip.PrefixFrom(netip.AddrFrom4([4]byte{34, 208, 0, 0}), 12),
+	},
+	{Cloud: AWS, Region: "us-west-2", Service: "EC2", NetworkBorderGroup: "us-west-2-lax-1"}: {
+		netip.PrefixFrom(netip.AddrFrom4([4]byte{15, 230, 0, 0}), 24),
+	},
 	{Cloud: Azure, Region: "eastus"}: {
 		netip.PrefixFrom(netip.AddrFrom16([16]byte{38, 3, 16, 48, 2, 16, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}), 48),
 		netip.PrefixFrom(netip.AddrFrom4([4]byte{4, 156, 0, 0}), 15),
@@ -194,27 +229,27 @@ var regionToRanges = map[IPInfo][]netip.Prefix{
 	{Cloud: Azure, Region: "westeurope"}: {
 		netip.PrefixFrom(netip.AddrFrom4([4]byte{13, 69, 0, 0}), 17),
 	},
-	{Cloud: GCP, Region: "asia-east1"}: {
+	{Cloud: GCP, Region: "asia-east1", Service: "Google Cloud"}: {
 		netip.PrefixFrom(netip.AddrFrom4([4]byte{130, 211, 240, 0}), 20),
 		netip.PrefixFrom(netip.AddrFrom16([16]byte{38, 0, 25, 0, 64, 48, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}), 44),
 		netip.PrefixFrom(netip.AddrFrom4([4]byte{34, 137, 0, 0}), 16),
 		netip.PrefixFrom(netip.AddrFrom4([4]byte{34, 80, 0, 0}), 15),
 		netip.PrefixFrom(netip.AddrFrom4([4]byte{35, 185, 128, 0}), 19),
 	},
-	{Cloud: GCP, Region: "us-west4"}: {
+	{Cloud: GCP, Region: "us-west4", Service: "Google Cloud"}: {
 		netip.PrefixFrom(netip.AddrFrom16([16]byte{38, 0, 25, 0, 65, 128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}), 44),
 	},
 }
 `
 
-	cloudToRTP := map[string]regionsToPrefixes{
-		"AWS":   awsRTP,
-		"GCP":   gcpRTP,
-		"Azure": azureRTP,
+	cloudToITP := map[string]infoToPrefixes{
+		"AWS":   awsITP,
+		"GCP":   gcpITP,
+		"Azure": withRegionsOnly(azureRTP),
 	}
 	// generate and compare
 	w := &bytes.Buffer{}
-	if err := generateRangesGo(w, cloudToRTP); err != nil {
+	if err := generateRangesGo(w, cloudToITP); err != nil {
 		t.Fatalf("unexpected error generating: %v", err)
 	}
 	result := w.String()
@@ -0,0 +1,141 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+)
+
+/*
+	For more on this format see:
+	https://www.rfc-editor.org/rfc/rfc8805
+*/
+
+// geofeedEntry is one self-published IP geolocation feed entry
+type geofeedEntry struct {
+	Prefix netip.Prefix
+	// Country is the ISO 3166-1 alpha-2 country code, e.g. "US"
+	Country string
+	// Region is the ISO 3166-2 region code, e.g. "US-TX"
+	Region string
+	City   string
+	// postal code omitted, it is deprecated
+}
+
+// parseGeofeed parses raw RFC 8805 geofeed data published for cloud
+// and processes it to a regionsToPrefixes map with mapping, if it is not nil
+// or empty
+func parseGeofeed(cloud, raw string, mapping *geofeedRegionMapping) (regionsToPrefixes, error) {
+	entries, err := parseGeofeedCSV(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid geofeed for cloud %q: %w", cloud, err)
+	}
+	if mapping != nil && len(mapping.Regions) == 0 && mapping.DefaultRegion == "" {
+		mapping = nil
+	}
+	rtp, err := geofeedRegionsToPrefixes(entries, mapping)
+	if err != nil {
+		return nil, fmt.Errorf("invalid geofeed for cloud %q: %w", cloud, err)
+	}
+	return rtp, nil
+}
+
+// parseGeofeedCSV parses RFC 8805 geofeed CSV data
+func parseGeofeedCSV(raw string) ([]geofeedEntry, error) {
+	r := csv.NewReader(strings.NewReader(raw))
+	r.Comment = '#'
+	// trailing fields are optional
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	entries := []geofeedEntry{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		// some publishers do not mask the prefix, we mask it for them
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, err
+		}
+		entry := geofeedEntry{Prefix: prefix.Masked()}
+		for i, field := range []*string{&entry.Country, &entry.Region, &entry.City} {
+			if i+1 < len(record) {
+				*field = strings.TrimSpace(record[i+1])
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// geofeedRegionsToPrefixes groups geofeed entries by the region mapping
+// returns for them, or if mapping is nil by region code, or country code
+// for entries without a region
+func geofeedRegionsToPrefixes(entries []geofeedEntry, mapping *geofeedRegionMapping) (regionsToPrefixes, error) {
+	rtp := regionsToPrefixes{}
+	for _, entry := range entries {
+		region := entry.Region
+		if region == "" {
+			region = entry.Country
+		}
+		if mapping != nil {
+			region = mapping.regionFor(entry)
+		}
+		if region == "" {
+			return nil, fmt.Errorf("geofeed entry for %s has no region for location %q", entry.Prefix, entry.location())
+		}
+		rtp[region] = append(rtp[region], entry.Prefix)
+	}
+	sortAndDedupePrefixes(rtp)
+	return rtp, nil
+}
+
+// location returns the entry location for error messages, e.g. "US,US-TX,Dallas"
+func (e *geofeedEntry) location() string {
+	return strings.Join([]string{e.Country, e.Region, e.City}, ",")
+}
+
+// geofeedRegionMapping maps geofeed locations to our region names
+type geofeedRegionMapping struct {
+	// Regions maps an ISO 3166-2 region code (e.g. "US-TX") or else an
+	// ISO 3166-1 country code (e.g. "US") to a region name
+	Regions map[string]string `json:"regions"`
+	// DefaultRegion, if set, is the region for entries not in Regions
+	DefaultRegion string `json:"defaultRegion"`
+}
+
+// regionFor returns the region for entry, or "" if it is not mapped
+func (m *geofeedRegionMapping) regionFor(entry geofeedEntry) string {
+	if region, ok := m.Regions[entry.Region]; ok && entry.Region != "" {
+		return region
+	}
+	if region, ok := m.Regions[entry.Country]; ok && entry.Country != "" {
+		return region
+	}
+	return m.DefaultRegion
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const geofeedTestData = `# corporate egress
+192.0.2.0/24,US,US-CA,San Jose,
+198.51.100.0/24,US,US-NY,New York,
+203.0.113.0/24,DE,DE-BE,Berlin,
+2001:db8::/32,JP,JP-13,Tokyo,
+`
+
+func TestParseGeofeed(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Raw      string
+		Mapping  geofeedRegionMapping
+		Expected regionsToPrefixes
+		// ExpectedError, if set, must be contained in the error
+		ExpectedError string
+	}{
+		{
+			Name: "no mapping",
+			Raw:  geofeedTestData,
+			Expected: regionsToPrefixes{
+				"US-CA": []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+				"US-NY": []netip.Prefix{netip.MustParsePrefix("198.51.100.0/24")},
+				"DE-BE": []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")},
+				"JP-13": []netip.Prefix{netip.MustParsePrefix("2001:db8::/32")},
+			},
+		},
+		{
+			// unmasked prefixes are masked, duplicates are deduped, entries
+			// without a region are grouped by country, trailing fields
+			// are optional and blank lines are skipped
+			Name: "publisher quirks",
+			Raw: `192.0.2.5/24,US,US-TX,Dallas
+ 2001:db8::/32, US, US-TX, Dallas, 75201
+
+` + "  " + `
+192.0.2.0/24,US,US-TX,Dallas,
+198.51.100.0/24,SG
+`,
+			Expected: regionsToPrefixes{
+				"US-TX": []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24"), netip.MustParsePrefix("2001:db8::/32")},
+				"SG":    []netip.Prefix{netip.MustParsePrefix("198.51.100.0/24")},
+			},
+		},
+		{
+			// regions take precedence over countries, then the default
+			Name: "mapping",
+			Raw:  geofeedTestData,
+			Mapping: geofeedRegionMapping{
+				Regions: map[string]string{
+					"US-CA": "us-west-1",
+					"US":    "us-east-2",
+					"DE":    "eu-central-1",
+				},
+				DefaultRegion: "us-east-2",
+			},
+			Expected: regionsToPrefixes{
+				"us-west-1":    []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+				"us-east-2":    []netip.Prefix{netip.MustParsePrefix("198.51.100.0/24"), netip.MustParsePrefix("2001:db8::/32")},
+				"eu-central-1": []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")},
+			},
+		},
+		{
+			Name:          "unmapped location",
+			Raw:           geofeedTestData,
+			Mapping:       geofeedRegionMapping{Regions: map[string]string{"US": "us-east-2"}},
+			ExpectedError: `invalid geofeed for cloud "CorpEgress": geofeed entry for 203.0.113.0/24 has no region for location "DE,DE-BE,Berlin"`,
+		},
+		{
+			Name:          "no location",
+			Raw:           "203.0.113.0/24\n",
+			ExpectedError: "has no region",
+		},
+		{
+			Name:          "invalid prefix",
+			Raw:           "not-a-prefix/99,US,US-TX,Dallas,\n",
+			ExpectedError: `invalid geofeed for cloud "CorpEgress"`,
+		},
+		{
+			Name:          "invalid CSV",
+			Raw:           "192.0.2.0/24,\"US\n",
+			ExpectedError: `invalid geofeed for cloud "CorpEgress"`,
+		},
+	}
+	for _, tc := range testCases {
+		rtp, err := parseGeofeed("CorpEgress", tc.Raw, &tc.Mapping)
+		if tc.ExpectedError != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.ExpectedError) {
+				t.Fatalf("%s: expected error containing %q but got: %v", tc.Name, tc.ExpectedError, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.Name, err)
+		}
+		if !reflect.DeepEqual(rtp, tc.Expected) {
+			t.Fatalf("%s: result does not match, got: %v expected: %v", tc.Name, rtp, tc.Expected)
+		}
+	}
+}
@@ -14,7 +14,7 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-// ranges2go generates a go source file with pre-parsed AWS IP ranges data.
+// ranges2go generates a go source file with pre-parsed cloud IP ranges data.
 // See also genrawdata.sh for downloading the raw data to this binary.
 package main
 
@@ -44,12 +44,12 @@ func main() {
 	gcpRaw := mustReadFile(filepath.Join(dataDir, "gcp-cloud.json"))
 	azureRaw := mustReadFile(filepath.Join(dataDir, "azure-service-tags.json"))
 	// parse raw AWS IP range data
-	awsRTP, err := parseAWS(awsRaw, excludedAWSRegions)
+	awsITP, err := parseAWS(awsRaw, excludedAWSRegions)
 	if err != nil {
 		panic(err)
 	}
 	// parse GCP IP range data
-	gcpRTP, err := parseGCP(gcpRaw)
+	gcpITP, err := parseGCP(gcpRaw)
 	if err != nil {
 		panic(err)
 	}
@@ -58,17 +58,25 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	cloudToITP := map[string]infoToPrefixes{
+		"AWS":   awsITP,
+		"GCP":   gcpITP,
+		"Azure": withRegionsOnly(azureRTP),
+	}
+	// parse optional clouds, which may not have data files
+	if err := addOptionalClouds(dataDir, optionalClouds, cloudToITP); err != nil {
+		panic(err)
+	}
+	// parse additional geofeeds, if any
+	if err := addGeofeedClouds(dataDir, cloudToITP); err != nil {
+		panic(err)
+	}
 	// emit file
 	f, err := os.Create(outputPath)
 	if err != nil {
 		panic(err)
 	}
-	cloudToRTP := map[string]regionsToPrefixes{
-		"AWS":   awsRTP,
-		"GCP":   gcpRTP,
-		"Azure": azureRTP,
-	}
-	if err := generateRangesGo(f, cloudToRTP); err != nil {
+	if err := generateRangesGo(f, cloudToITP); err != nil {
 		panic(err)
 	}
 }
@@ -0,0 +1,95 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// optionalCloud is a cloud whose range data files may not be in the data
+// directory, in which case we still generate a constant for it, without
+// any prefixes
+type optionalCloud struct {
+	// Files are the data files, relative to the data directory
+	Files []string
+	// Parse parses the contents of Files, in the same order
+	Parse func(raws []string) (regionsToPrefixes, error)
+}
+
+// optionalClouds are the optional clouds by name, see codegen.sh
+var optionalClouds = map[string]optionalCloud{
+	"Oracle": {
+		Files: []string{"oracle-public-ip-ranges.json"},
+		Parse: func(raws []string) (regionsToPrefixes, error) { return parseOracle(raws[0]) },
+	},
+	"DigitalOcean": {
+		Files: []string{"digitalocean-geofeed.csv"},
+		Parse: func(raws []string) (regionsToPrefixes, error) { return parseDigitalOcean(raws[0]) },
+	},
+	"Linode": {
+		Files: []string{"linode-geofeed.csv"},
+		Parse: func(raws []string) (regionsToPrefixes, error) { return parseLinode(raws[0]) },
+	},
+	"Hetzner": {
+		Files: []string{"hetzner-geofeed.csv"},
+		Parse: func(raws []string) (regionsToPrefixes, error) { return parseHetzner(raws[0]) },
+	},
+	"IBM": {
+		Files: []string{"ibm-geofeed.csv"},
+		Parse: func(raws []string) (regionsToPrefixes, error) { return parseIBM(raws[0]) },
+	},
+	"Cloudflare": {
+		Files: []string{"cloudflare-ips-v4.txt", "cloudflare-ips-v6.txt"},
+		Parse: func(raws []string) (regionsToPrefixes, error) { return parseCloudflare(raws[0], raws[1]) },
+	},
+}
+
+// addOptionalClouds adds clouds to cloudToITP, reading their data files
+// from dataDir. A cloud without any of its data files has no prefixes.
+func addOptionalClouds(dataDir string, clouds map[string]optionalCloud, cloudToITP map[string]infoToPrefixes) error {
+	for cloud, optional := range clouds {
+		raws := make([]string, 0, len(optional.Files))
+		var missing []string
+		for _, file := range optional.Files {
+			raw, err := os.ReadFile(filepath.Join(dataDir, file))
+			if errors.Is(err, os.ErrNotExist) {
+				missing = append(missing, file)
+				continue
+			} else if err != nil {
+				return err
+			}
+			raws = append(raws, string(raw))
+		}
+		if len(missing) == len(optional.Files) {
+			fmt.Printf("No data for cloud %s, generating it without prefixes\n", cloud)
+			cloudToITP[cloud] = infoToPrefixes{}
+			continue
+		} else if len(missing) != 0 {
+			return fmt.Errorf("missing data files for cloud %q: %v", cloud, missing)
+		}
+		rtp, err := optional.Parse(raws)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", strings.Join(optional.Files, ", "), err)
+		}
+		cloudToITP[cloud] = withRegionsOnly(rtp)
+	}
+	return nil
+}
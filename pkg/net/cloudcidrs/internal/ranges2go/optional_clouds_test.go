@@ -0,0 +1,118 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAddOptionalClouds(t *testing.T) {
+	dataDir := t.TempDir()
+	files := map[string]string{
+		"oracle-public-ip-ranges.json": oracleTestData,
+		"digitalocean-geofeed.csv":     "104.131.0.0/18,US,US-NY,New York,10011\n",
+		"linode-geofeed.csv":           "45.33.0.0/22,US,US-CA,Fremont,\n",
+		"hetzner-geofeed.csv":          "5.9.0.0/16,DE,,Falkenstein,\n",
+		"cloudflare-ips-v4.txt":        "173.245.48.0/20\n",
+		"cloudflare-ips-v6.txt":        "2400:cb00::/32\n",
+		"ibm-geofeed.csv":              "169.45.0.0/16,US,US-TX,Dallas,\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dataDir, name), []byte(contents), 0o600); err != nil {
+			t.Fatalf("failed to write test file: %v", err)
+		}
+	}
+	cloudToITP := map[string]infoToPrefixes{}
+	if err := addOptionalClouds(dataDir, optionalClouds, cloudToITP); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]infoToPrefixes{
+		"Oracle": {
+			{Region: "us-phoenix-1"}:   {netip.MustParsePrefix("129.146.0.0/21"), netip.MustParsePrefix("134.70.8.0/21")},
+			{Region: "eu-frankfurt-1"}: {netip.MustParsePrefix("130.61.0.0/16")},
+		},
+		"DigitalOcean": {{Region: "US-NY"}: {netip.MustParsePrefix("104.131.0.0/18")}},
+		"Linode":       {{Region: "US-CA"}: {netip.MustParsePrefix("45.33.0.0/22")}},
+		"Hetzner":      {{Region: "DE"}: {netip.MustParsePrefix("5.9.0.0/16")}},
+		"IBM":          {{Region: "US-TX"}: {netip.MustParsePrefix("169.45.0.0/16")}},
+		"Cloudflare": {
+			{Region: globalRegion}: {netip.MustParsePrefix("173.245.48.0/20"), netip.MustParsePrefix("2400:cb00::/32")},
+		},
+	}
+	if !reflect.DeepEqual(cloudToITP, expected) {
+		t.Fatalf("result does not match, got: %v expected: %v", cloudToITP, expected)
+	}
+
+	// clouds without data still get a constant
+	cloudToITP = map[string]infoToPrefixes{}
+	missing := map[string]optionalCloud{"Missing": {Files: []string{"missing.csv"}}}
+	if err := addOptionalClouds(dataDir, missing, cloudToITP); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(cloudToITP, map[string]infoToPrefixes{"Missing": {}}) {
+		t.Fatalf("expected cloud without prefixes, got: %v", cloudToITP)
+	}
+}
+
+func TestAddOptionalCloudsInvalid(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Files map[string]string
+	}{
+		{
+			Name:  "partial data",
+			Files: map[string]string{"a.txt": "173.245.48.0/20\n"},
+		},
+		{
+			Name:  "invalid data",
+			Files: map[string]string{"a.txt": "not-a-prefix/99\n", "b.txt": ""},
+		},
+		{
+			// a directory cannot be read
+			Name:  "unreadable data",
+			Files: map[string]string{"a.txt/": ""},
+		},
+	}
+	clouds := map[string]optionalCloud{
+		"Test": {
+			Files: []string{"a.txt", "b.txt"},
+			Parse: func(raws []string) (regionsToPrefixes, error) { return parseCloudflare(raws[0], raws[1]) },
+		},
+	}
+	for _, tc := range testCases {
+		dataDir := t.TempDir()
+		for name, contents := range tc.Files {
+			var err error
+			if dir, ok := strings.CutSuffix(name, "/"); ok {
+				err = os.Mkdir(filepath.Join(dataDir, dir), 0o755)
+			} else {
+				err = os.WriteFile(filepath.Join(dataDir, name), []byte(contents), 0o600)
+			}
+			if err != nil {
+				t.Fatalf("failed to write test file: %v", err)
+			}
+		}
+		if err := addOptionalClouds(dataDir, clouds, map[string]infoToPrefixes{}); err == nil {
+			t.Errorf("%s: expected error but got none", tc.Name)
+		}
+	}
+}
@@ -20,17 +20,18 @@ import (
 	"encoding/json"
 	"net/netip"
 	"slices"
-	"sort"
 )
 
 // parseAWS parses raw AWS IP ranges JSON data
-// and processes it to a regionsToPrefixes map
-func parseAWS(raw string, excludedRegions []string) (regionsToPrefixes, error) {
+// and processes it to an infoToPrefixes map
+//
+// ip-ranges.json also lists the aws-cn partition (cn-north-1 and cn-northwest-1)
+func parseAWS(raw string, excludedRegions []string) (infoToPrefixes, error) {
 	parsed, err := parseAWSIPRangesJSON([]byte(raw))
 	if err != nil {
 		return nil, err
 	}
-	return awsRegionsToPrefixesFromData(parsed, excludedRegions)
+	return awsInfoToPrefixesFromData(parsed, excludedRegions)
 }
 
 /*
@@ -45,17 +46,17 @@ type AWSIPRangesJSON struct {
 }
 
 type AWSPrefix struct {
-	IPPrefix string `json:"ip_prefix"`
-	Region   string `json:"region"`
-	Service  string `json:"service"`
-	// network_border_group omitted
+	IPPrefix           string `json:"ip_prefix"`
+	Region             string `json:"region"`
+	Service            string `json:"service"`
+	NetworkBorderGroup string `json:"network_border_group"`
 }
 
 type AWSIPv6Prefix struct {
-	IPv6Prefix string `json:"ipv6_prefix"`
-	Region     string `json:"region"`
-	Service    string `json:"service"`
-	// network_border_group omitted
+	IPv6Prefix         string `json:"ipv6_prefix"`
+	Region             string `json:"region"`
+	Service            string `json:"service"`
+	NetworkBorderGroup string `json:"network_border_group"`
 }
 
 // parseIPRangesJSON parse AWS IP ranges JSON data
@@ -68,44 +69,79 @@ func parseAWSIPRangesJSON(rawJSON []byte) (*AWSIPRangesJSON, error) {
 	return r, nil
 }
 
-// awsRegionsToPrefixesFromData processes the raw unmarshalled JSON into regionsToPrefixes map
-func awsRegionsToPrefixesFromData(data *AWSIPRangesJSON, excludedRegions []string) (regionsToPrefixes, error) {
-	// convert from AWS published structure to a map by region, parse Prefixes
-	rtp := regionsToPrefixes{}
-	for _, prefix := range data.Prefixes {
-		region := prefix.Region
-		ipPrefix, err := netip.ParsePrefix(prefix.IPPrefix)
+// awsInfoToPrefixesFromData processes the raw unmarshalled JSON into infoToPrefixes map
+//
+// AWS lists prefixes once for each service using them, including the
+// "AMAZON" service which lists every prefix, each prefix is assigned to the
+// most specific service by awsPreferredService
+func awsInfoToPrefixesFromData(data *AWSIPRangesJSON, excludedRegions []string) (infoToPrefixes, error) {
+	type regionPrefix struct {
+		region string
+		prefix netip.Prefix
+	}
+	prefixToInfo := map[regionPrefix]prefixInfo{}
+	add := func(rawPrefix, region, service, networkBorderGroup string) error {
+		ipPrefix, err := netip.ParsePrefix(rawPrefix)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if slices.Contains(excludedRegions, region) {
-			continue
+			return nil
+		}
+		key := regionPrefix{region: region, prefix: ipPrefix}
+		info, exists := prefixToInfo[key]
+		if !exists {
+			info = prefixInfo{Region: region, Service: service}
+			// border groups are only interesting when they are not the region
+			if networkBorderGroup != region {
+				info.NetworkBorderGroup = networkBorderGroup
+			}
+		} else {
+			info.Service = awsPreferredService(info.Service, service)
 		}
-		rtp[region] = append(rtp[region], ipPrefix)
+		prefixToInfo[key] = info
+		return nil
 	}
-	for _, prefix := range data.IPv6Prefixes {
-		region := prefix.Region
-		ipPrefix, err := netip.ParsePrefix(prefix.IPv6Prefix)
-		if err != nil {
+	for _, prefix := range data.Prefixes {
+		if err := add(prefix.IPPrefix, prefix.Region, prefix.Service, prefix.NetworkBorderGroup); err != nil {
 			return nil, err
 		}
-		if slices.Contains(excludedRegions, region) {
-			continue
+	}
+	for _, prefix := range data.IPv6Prefixes {
+		if err := add(prefix.IPv6Prefix, prefix.Region, prefix.Service, prefix.NetworkBorderGroup); err != nil {
+			return nil, err
 		}
-		rtp[region] = append(rtp[region], ipPrefix)
 	}
 
-	// flatten
-	numPrefixes := 0
-	for region := range rtp {
-		// this approach allows us to produce consistent generated results
-		// since the ip ranges will be ordered
-		sort.Slice(rtp[region], func(i, j int) bool {
-			return rtp[region][i].String() < rtp[region][j].String()
-		})
-		rtp[region] = dedupeSortedPrefixes(rtp[region])
-		numPrefixes += len(rtp[region])
+	// convert to a map by info
+	itp := infoToPrefixes{}
+	for key, info := range prefixToInfo {
+		itp[info] = append(itp[info], key.prefix)
 	}
+	sortAndDedupePrefixes(itp)
+	return itp, nil
+}
 
-	return rtp, nil
+// awsPreferredService returns the more specific of two services listing the
+// same prefix, "AMAZON" lists all prefixes and "EC2" lists many prefixes also
+// listed by other services, otherwise we pick the first alphabetically so
+// that the result is consistent
+func awsPreferredService(a, b string) string {
+	rank := func(service string) int {
+		switch service {
+		case "AMAZON":
+			return 0
+		case "EC2":
+			return 1
+		default:
+			return 2
+		}
+	}
+	if rank(a) != rank(b) {
+		if rank(a) > rank(b) {
+			return a
+		}
+		return b
+	}
+	return min(a, b)
 }
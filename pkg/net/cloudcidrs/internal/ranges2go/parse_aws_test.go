@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"net/netip"
 	"reflect"
 	"testing"
 )
@@ -46,16 +47,18 @@ func TestAWSParseIPRangesJSON(t *testing.T) {
 	expectedParsed := &AWSIPRangesJSON{
 		Prefixes: []AWSPrefix{
 			{
-				IPPrefix: "3.5.140.0/22",
-				Region:   "ap-northeast-2",
-				Service:  "AMAZON",
+				IPPrefix:           "3.5.140.0/22",
+				Region:             "ap-northeast-2",
+				Service:            "AMAZON",
+				NetworkBorderGroup: "ap-northeast-2",
 			},
 		},
 		IPv6Prefixes: []AWSIPv6Prefix{
 			{
-				IPv6Prefix: "2a05:d07a:a000::/40",
-				Region:     "eu-south-1",
-				Service:    "AMAZON",
+				IPv6Prefix:         "2a05:d07a:a000::/40",
+				Region:             "eu-south-1",
+				Service:            "AMAZON",
+				NetworkBorderGroup: "eu-south-1",
 			},
 		},
 	}
@@ -78,7 +81,52 @@ func TestAWSParseIPRangesJSON(t *testing.T) {
 	}
 }
 
-func TestAWSRegionsToPrefixesFromData(t *testing.T) {
+func TestAWSInfoToPrefixesFromData(t *testing.T) {
+	t.Run("services and border groups", func(t *testing.T) {
+		t.Parallel()
+		data := &AWSIPRangesJSON{
+			Prefixes: []AWSPrefix{
+				{IPPrefix: "3.5.140.0/22", Region: "ap-northeast-2", Service: "AMAZON", NetworkBorderGroup: "ap-northeast-2"},
+				{IPPrefix: "3.5.140.0/22", Region: "ap-northeast-2", Service: "S3", NetworkBorderGroup: "ap-northeast-2"},
+				{IPPrefix: "3.5.140.0/22", Region: "ap-northeast-2", Service: "EC2", NetworkBorderGroup: "ap-northeast-2"},
+				{IPPrefix: "15.230.0.0/24", Region: "us-west-2", Service: "AMAZON", NetworkBorderGroup: "us-west-2-lax-1"},
+				{IPPrefix: "15.230.0.0/24", Region: "us-west-2", Service: "EC2", NetworkBorderGroup: "us-west-2-lax-1"},
+				{IPPrefix: "205.251.192.0/19", Region: "GLOBAL", Service: "AMAZON", NetworkBorderGroup: "GLOBAL"},
+				{IPPrefix: "205.251.192.0/19", Region: "GLOBAL", Service: "CLOUDFRONT_ORIGIN_FACING", NetworkBorderGroup: "GLOBAL"},
+				{IPPrefix: "205.251.192.0/19", Region: "GLOBAL", Service: "CLOUDFRONT", NetworkBorderGroup: "GLOBAL"},
+				{IPPrefix: "52.95.174.0/24", Region: "me-south-1", Service: "AMAZON", NetworkBorderGroup: "me-south-1"},
+			},
+			IPv6Prefixes: []AWSIPv6Prefix{
+				{IPv6Prefix: "2600:1f14::/35", Region: "us-west-2", Service: "EC2", NetworkBorderGroup: "us-west-2"},
+			},
+		}
+		// each prefix must be assigned to its most specific service,
+		// and border groups only kept when they are not the region
+		expected := infoToPrefixes{
+			{Region: "ap-northeast-2", Service: "S3"}: []netip.Prefix{
+				netip.MustParsePrefix("3.5.140.0/22"),
+			},
+			{Region: "us-west-2", Service: "EC2", NetworkBorderGroup: "us-west-2-lax-1"}: []netip.Prefix{
+				netip.MustParsePrefix("15.230.0.0/24"),
+			},
+			{Region: "us-west-2", Service: "EC2"}: []netip.Prefix{
+				netip.MustParsePrefix("2600:1f14::/35"),
+			},
+			{Region: "GLOBAL", Service: "CLOUDFRONT"}: []netip.Prefix{
+				netip.MustParsePrefix("205.251.192.0/19"),
+			},
+			{Region: "me-south-1", Service: "AMAZON"}: []netip.Prefix{
+				netip.MustParsePrefix("52.95.174.0/24"),
+			},
+		}
+		itp, err := awsInfoToPrefixesFromData(data, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(itp, expected) {
+			t.Fatalf("result does not match, got: %v expected: %v", itp, expected)
+		}
+	})
 	t.Run("bad IPv4 prefixes", func(t *testing.T) {
 		t.Parallel()
 		badV4Prefixes := &AWSIPRangesJSON{
@@ -90,7 +138,7 @@ func TestAWSRegionsToPrefixesFromData(t *testing.T) {
 				},
 			},
 		}
-		_, err := awsRegionsToPrefixesFromData(badV4Prefixes, nil)
+		_, err := awsInfoToPrefixesFromData(badV4Prefixes, nil)
 		if err == nil {
 			t.Fatal("expected error parsing bogus prefix but got none")
 		}
@@ -113,7 +161,7 @@ func TestAWSRegionsToPrefixesFromData(t *testing.T) {
 				},
 			},
 		}
-		_, err := awsRegionsToPrefixesFromData(badV6Prefixes, nil)
+		_, err := awsInfoToPrefixesFromData(badV6Prefixes, nil)
 		if err == nil {
 			t.Fatal("expected error parsing bogus prefix but got none")
 		}
@@ -121,6 +169,37 @@ func TestAWSRegionsToPrefixesFromData(t *testing.T) {
 }
 
 func TestParseAWS(t *testing.T) {
+	t.Run("aws-cn partition", func(t *testing.T) {
+		t.Parallel()
+		// ip-ranges.json lists the aws-cn regions alongside the others
+		raw := `{"prefixes":[
+  {"ip_prefix":"3.5.140.0/22","region":"ap-northeast-2","service":"AMAZON","network_border_group":"ap-northeast-2"},
+  {"ip_prefix":"52.80.0.0/16","region":"cn-north-1","service":"AMAZON","network_border_group":"cn-north-1"},
+  {"ip_prefix":"52.80.0.0/16","region":"cn-north-1","service":"EC2","network_border_group":"cn-north-1"},
+  {"ip_prefix":"52.82.0.0/17","region":"cn-northwest-1","service":"AMAZON","network_border_group":"cn-northwest-1"}
+],"ipv6_prefixes":[
+  {"ipv6_prefix":"2404:c2c0::/40","region":"cn-northwest-1","service":"AMAZON","network_border_group":"cn-northwest-1"}
+]}`
+		expected := infoToPrefixes{
+			{Region: "ap-northeast-2", Service: "AMAZON"}: []netip.Prefix{
+				netip.MustParsePrefix("3.5.140.0/22"),
+			},
+			{Region: "cn-north-1", Service: "EC2"}: []netip.Prefix{
+				netip.MustParsePrefix("52.80.0.0/16"),
+			},
+			{Region: "cn-northwest-1", Service: "AMAZON"}: []netip.Prefix{
+				netip.MustParsePrefix("2404:c2c0::/40"),
+				netip.MustParsePrefix("52.82.0.0/17"),
+			},
+		}
+		itp, err := parseAWS(raw, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(itp, expected) {
+			t.Fatalf("result does not match, got: %v expected: %v", itp, expected)
+		}
+	})
 	t.Run("unparsable data", func(t *testing.T) {
 		t.Parallel()
 		badJSON := `{"prefixes":false}`
@@ -130,3 +209,22 @@ func TestParseAWS(t *testing.T) {
 		}
 	})
 }
+
+func TestAWSPreferredService(t *testing.T) {
+	testCases := []struct {
+		A, B     string
+		Expected string
+	}{
+		{A: "AMAZON", B: "EC2", Expected: "EC2"},
+		{A: "EC2", B: "AMAZON", Expected: "EC2"},
+		{A: "EC2", B: "S3", Expected: "S3"},
+		{A: "S3", B: "EC2", Expected: "S3"},
+		{A: "CLOUDFRONT_ORIGIN_FACING", B: "CLOUDFRONT", Expected: "CLOUDFRONT"},
+		{A: "AMAZON", B: "AMAZON", Expected: "AMAZON"},
+	}
+	for _, tc := range testCases {
+		if service := awsPreferredService(tc.A, tc.B); service != tc.Expected {
+			t.Errorf("awsPreferredService(%q, %q): expected %q but got %q", tc.A, tc.B, tc.Expected, service)
+		}
+	}
+}
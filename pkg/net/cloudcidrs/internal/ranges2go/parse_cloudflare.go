@@ -0,0 +1,54 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"net/netip"
+	"strings"
+)
+
+// cloudflareRegion is the region for all Cloudflare prefixes,
+// which are anycast and so are not specific to any region
+const cloudflareRegion = globalRegion
+
+// parseCloudflare parses the Cloudflare IPv4 and IPv6 range lists
+// and processes them to a regionsToPrefixes map
+// https://www.cloudflare.com/ips/
+func parseCloudflare(rawIPv4, rawIPv6 string) (regionsToPrefixes, error) {
+	rtp := regionsToPrefixes{}
+	for _, raw := range []string{rawIPv4, rawIPv6} {
+		// one prefix per line
+		scanner := bufio.NewScanner(strings.NewReader(raw))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			ipPrefix, err := netip.ParsePrefix(line)
+			if err != nil {
+				return nil, err
+			}
+			rtp[cloudflareRegion] = append(rtp[cloudflareRegion], ipPrefix)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	sortAndDedupePrefixes(rtp)
+	return rtp, nil
+}
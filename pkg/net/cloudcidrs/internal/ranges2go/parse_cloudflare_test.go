@@ -0,0 +1,63 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCloudflare(t *testing.T) {
+	const ipv4Data = `173.245.48.0/20
+103.21.244.0/22
+173.245.48.0/20
+`
+	const ipv6Data = `2400:cb00::/32
+
+2606:4700::/32`
+	rtp, err := parseCloudflare(ipv4Data, ipv6Data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// all prefixes are anycast, duplicates must be deduped and results sorted
+	expected := regionsToPrefixes{
+		"GLOBAL": []netip.Prefix{
+			netip.MustParsePrefix("103.21.244.0/22"),
+			netip.MustParsePrefix("173.245.48.0/20"),
+			netip.MustParsePrefix("2400:cb00::/32"),
+			netip.MustParsePrefix("2606:4700::/32"),
+		},
+	}
+	if !reflect.DeepEqual(rtp, expected) {
+		t.Fatalf("result does not match, got: %v expected: %v", rtp, expected)
+	}
+}
+
+func TestParseCloudflareBadPrefix(t *testing.T) {
+	if _, err := parseCloudflare("not-a-prefix/99\n", ""); err == nil {
+		t.Fatal("expected error parsing invalid prefix but got none")
+	}
+}
+
+func TestParseCloudflareLongLine(t *testing.T) {
+	if _, err := parseCloudflare("", strings.Repeat("a", bufio.MaxScanTokenSize)); err == nil {
+		t.Fatal("expected error parsing overlong line but got none")
+	}
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestParseDigitalOcean(t *testing.T) {
+	const testData = `5.101.96.0/21,NL,NL-NH,Amsterdam,1098 XH
+2a03:b0c0::/48,NL,NL-NH,Amsterdam,1098 XH
+104.131.0.0/18,US,US-NY,New York,10011
+`
+	rtp, err := parseDigitalOcean(testData)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := regionsToPrefixes{
+		"NL-NH": []netip.Prefix{
+			netip.MustParsePrefix("2a03:b0c0::/48"),
+			netip.MustParsePrefix("5.101.96.0/21"),
+		},
+		"US-NY": []netip.Prefix{
+			netip.MustParsePrefix("104.131.0.0/18"),
+		},
+	}
+	if !reflect.DeepEqual(rtp, expected) {
+		t.Fatalf("result does not match, got: %v expected: %v", rtp, expected)
+	}
+}
+
+func TestParseDigitalOceanBadPrefix(t *testing.T) {
+	if _, err := parseDigitalOcean("not-a-prefix/99,US,US-TX,Dallas,\n"); err == nil {
+		t.Fatal("expected error parsing invalid prefix but got none")
+	}
+}
@@ -20,17 +20,16 @@ import (
 	"encoding/json"
 	"errors"
 	"net/netip"
-	"sort"
 )
 
 // parseGCP parses raw GCP cloud.json data
-// and processes it to a regionsToPrefixes map
-func parseGCP(raw string) (regionsToPrefixes, error) {
+// and processes it to an infoToPrefixes map
+func parseGCP(raw string) (infoToPrefixes, error) {
 	parsed, err := parseGCPCloudJSON([]byte(raw))
 	if err != nil {
 		return nil, err
 	}
-	return gcpRegionsToPrefixesFromData(parsed)
+	return gcpInfoToPrefixesFromData(parsed)
 }
 
 type GCPCloudJSON struct {
@@ -42,7 +41,7 @@ type GCPPrefix struct {
 	IPv4Prefix string `json:"ipv4Prefix"`
 	IPv6Prefix string `json:"ipv6Prefix"`
 	Scope      string `json:"scope"`
-	// service omitted
+	Service    string `json:"service"`
 }
 
 // parseGCPCloudJSON parses GCP cloud.json IP ranges JSON data
@@ -54,40 +53,28 @@ func parseGCPCloudJSON(rawJSON []byte) (*GCPCloudJSON, error) {
 	return r, nil
 }
 
-// gcpRegionsToPrefixesFromData processes the raw unmarshalled JSON into regionsToPrefixes map
-func gcpRegionsToPrefixesFromData(data *GCPCloudJSON) (regionsToPrefixes, error) {
-	// convert from AWS published structure to a map by region, parse Prefixes
-	rtp := regionsToPrefixes{}
+// gcpInfoToPrefixesFromData processes the raw unmarshalled JSON into infoToPrefixes map
+func gcpInfoToPrefixesFromData(data *GCPCloudJSON) (infoToPrefixes, error) {
+	// convert from GCP published structure to a map by region and service, parse Prefixes
+	itp := infoToPrefixes{}
 	for _, prefix := range data.Prefixes {
-		region := prefix.Scope
+		info := prefixInfo{Region: prefix.Scope, Service: prefix.Service}
 		if prefix.IPv4Prefix != "" {
 			ipPrefix, err := netip.ParsePrefix(prefix.IPv4Prefix)
 			if err != nil {
 				return nil, err
 			}
-			rtp[region] = append(rtp[region], ipPrefix)
+			itp[info] = append(itp[info], ipPrefix)
 		} else if prefix.IPv6Prefix != "" {
 			ipPrefix, err := netip.ParsePrefix(prefix.IPv6Prefix)
 			if err != nil {
 				return nil, err
 			}
-			rtp[region] = append(rtp[region], ipPrefix)
+			itp[info] = append(itp[info], ipPrefix)
 		} else {
 			return nil, errors.New("unexpected entry with no ipv4Prefix or ipv6Prefix")
 		}
 	}
-
-	// flatten
-	numPrefixes := 0
-	for region := range rtp {
-		// this approach allows us to produce consistent generated results
-		// since the ip ranges will be ordered
-		sort.Slice(rtp[region], func(i, j int) bool {
-			return rtp[region][i].String() < rtp[region][j].String()
-		})
-		rtp[region] = dedupeSortedPrefixes(rtp[region])
-		numPrefixes += len(rtp[region])
-	}
-
-	return rtp, nil
+	sortAndDedupePrefixes(itp)
+	return itp, nil
 }
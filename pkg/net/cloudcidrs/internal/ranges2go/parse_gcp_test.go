@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"net/netip"
 	"reflect"
 	"testing"
 )
@@ -42,10 +43,12 @@ func TestGCPParseIPRangesJSON(t *testing.T) {
 			{
 				IPv4Prefix: "34.80.0.0/15",
 				Scope:      "asia-east1",
+				Service:    "Google Cloud",
 			},
 			{
 				IPv6Prefix: "2600:1900:4180::/44",
 				Scope:      "us-west4",
+				Service:    "Google Cloud",
 			},
 		},
 	}
@@ -68,7 +71,7 @@ func TestGCPParseIPRangesJSON(t *testing.T) {
 	}
 }
 
-func TestGCPRegionsToPrefixesFromData(t *testing.T) {
+func TestGCPInfoToPrefixesFromData(t *testing.T) {
 	t.Run("bad IPv4 prefixes", func(t *testing.T) {
 		t.Parallel()
 		badV4Prefixes := &GCPCloudJSON{
@@ -79,7 +82,7 @@ func TestGCPRegionsToPrefixesFromData(t *testing.T) {
 				},
 			},
 		}
-		_, err := gcpRegionsToPrefixesFromData(badV4Prefixes)
+		_, err := gcpInfoToPrefixesFromData(badV4Prefixes)
 		if err == nil {
 			t.Fatal("expected error parsing bogus prefix but got none")
 		}
@@ -98,7 +101,7 @@ func TestGCPRegionsToPrefixesFromData(t *testing.T) {
 				},
 			},
 		}
-		_, err := gcpRegionsToPrefixesFromData(badV6Prefixes)
+		_, err := gcpInfoToPrefixesFromData(badV6Prefixes)
 		if err == nil {
 			t.Fatal("expected error parsing bogus prefix but got none")
 		}
@@ -112,7 +115,7 @@ func TestGCPRegionsToPrefixesFromData(t *testing.T) {
 				},
 			},
 		}
-		_, err := gcpRegionsToPrefixesFromData(badNoPrefixes)
+		_, err := gcpInfoToPrefixesFromData(badNoPrefixes)
 		if err == nil {
 			t.Fatal("expected error parsing bogus prefix but got none")
 		}
@@ -120,6 +123,19 @@ func TestGCPRegionsToPrefixesFromData(t *testing.T) {
 }
 
 func TestParseGCP(t *testing.T) {
+	t.Run("valid data", func(t *testing.T) {
+		t.Parallel()
+		itp, err := parseGCP(`{"prefixes": [{"ipv4Prefix": "34.80.0.0/15", "service": "Google Cloud", "scope": "asia-east1"}]}`)
+		if err != nil {
+			t.Fatalf("unexpected error parsing valid data: %v", err)
+		}
+		expected := infoToPrefixes{
+			{Region: "asia-east1", Service: "Google Cloud"}: {netip.MustParsePrefix("34.80.0.0/15")},
+		}
+		if !reflect.DeepEqual(expected, itp) {
+			t.Errorf("parsed did not match expected: %#v != %#v", itp, expected)
+		}
+	})
 	t.Run("unparsable data", func(t *testing.T) {
 		t.Parallel()
 		badJSON := `{"prefixes":false}`
@@ -0,0 +1,115 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"unicode"
+)
+
+// geofeedsConfigFile is the optional file in the data directory listing
+// additional RFC 8805 geofeeds to generate clouds from, e.g.
+//
+//	{
+//	  "geofeeds": [
+//	    {
+//	      "cloud": "CorpEgress",
+//	      "file": "corp-egress-geofeed.csv",
+//	      "regions": {"US-CA": "us-west-1", "US": "us-east-2", "DE": "eu-central-1"},
+//	      "defaultRegion": "us-east-2"
+//	    }
+//	  ]
+//	}
+//
+// Locations are mapped to regions by ISO 3166-2 region code, then by country
+// code, then defaultRegion. Without "regions" or "defaultRegion" the
+// geofeed region (or country) code is used as-is.
+const geofeedsConfigFile = "geofeeds.json"
+
+type GeofeedsConfig struct {
+	Geofeeds []GeofeedConfig `json:"geofeeds"`
+}
+
+type GeofeedConfig struct {
+	// Cloud is the cloud name, which must be a valid exported Go identifier
+	// as we generate a constant for it
+	Cloud string `json:"cloud"`
+	// File is the geofeed CSV file, relative to the data directory
+	File string `json:"file"`
+	geofeedRegionMapping
+}
+
+// parseGeofeedsConfig parses and validates a geofeeds config file
+func parseGeofeedsConfig(raw []byte) (*GeofeedsConfig, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	config := &GeofeedsConfig{}
+	if err := decoder.Decode(config); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, feed := range config.Geofeeds {
+		if !token.IsIdentifier(feed.Cloud) || !unicode.IsUpper([]rune(feed.Cloud)[0]) {
+			return nil, fmt.Errorf("invalid geofeed cloud name, must be an exported Go identifier: %q", feed.Cloud)
+		}
+		if seen[feed.Cloud] {
+			return nil, fmt.Errorf("duplicate geofeed cloud: %q", feed.Cloud)
+		}
+		seen[feed.Cloud] = true
+		if feed.File == "" {
+			return nil, fmt.Errorf("geofeed for cloud %q has no file", feed.Cloud)
+		}
+	}
+	return config, nil
+}
+
+// addGeofeedClouds adds the clouds in the geofeeds config file in dataDir,
+// if there is one, to cloudToITP
+func addGeofeedClouds(dataDir string, cloudToITP map[string]infoToPrefixes) error {
+	raw, err := os.ReadFile(filepath.Join(dataDir, geofeedsConfigFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	config, err := parseGeofeedsConfig(raw)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", geofeedsConfigFile, err)
+	}
+	for i := range config.Geofeeds {
+		feed := &config.Geofeeds[i]
+		if _, exists := cloudToITP[feed.Cloud]; exists {
+			return fmt.Errorf("geofeed cloud %q is already defined", feed.Cloud)
+		}
+		rawFeed, err := os.ReadFile(filepath.Join(dataDir, feed.File))
+		if err != nil {
+			return err
+		}
+		rtp, err := parseGeofeed(feed.Cloud, string(rawFeed), &feed.geofeedRegionMapping)
+		if err != nil {
+			return err
+		}
+		cloudToITP[feed.Cloud] = withRegionsOnly(rtp)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"maps"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGeofeedsConfig(t *testing.T) {
+	const valid = `{"geofeeds": [{"cloud": "CorpEgress", "file": "corp.csv", "regions": {"US": "us-east-2"}, "defaultRegion": "eu-central-1"}]}`
+	config, err := parseGeofeedsConfig([]byte(valid))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &GeofeedsConfig{
+		Geofeeds: []GeofeedConfig{{
+			Cloud: "CorpEgress",
+			File:  "corp.csv",
+			geofeedRegionMapping: geofeedRegionMapping{
+				Regions:       map[string]string{"US": "us-east-2"},
+				DefaultRegion: "eu-central-1",
+			},
+		}},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("result does not match, got: %#v expected: %#v", config, expected)
+	}
+	for _, invalid := range []string{
+		`{"geofeeds": [}`,
+		`{"geofeeds": [{"cloud": "corpEgress", "file": "corp.csv"}]}`,
+		`{"geofeeds": [{"cloud": "Corp Egress", "file": "corp.csv"}]}`,
+		`{"geofeeds": [{"cloud": "", "file": "corp.csv"}]}`,
+		`{"geofeeds": [{"cloud": "Corp"}]}`,
+		`{"geofeeds": [{"cloud": "Corp", "file": "a.csv"}, {"cloud": "Corp", "file": "b.csv"}]}`,
+		`{"geofeeds": [{"cloud": "Corp", "file": "corp.csv", "region": {}}]}`,
+	} {
+		if _, err := parseGeofeedsConfig([]byte(invalid)); err == nil {
+			t.Errorf("expected error parsing %s but got none", invalid)
+		}
+	}
+}
+
+func TestAddGeofeedClouds(t *testing.T) {
+	// no config is fine
+	cloudToITP := map[string]infoToPrefixes{"AWS": {}}
+	if err := addGeofeedClouds(t.TempDir(), cloudToITP); err != nil {
+		t.Fatalf("unexpected error without config: %v", err)
+	}
+	if len(cloudToITP) != 1 {
+		t.Fatalf("expected no clouds to be added without config, got: %v", cloudToITP)
+	}
+
+	dataDir := t.TempDir()
+	writeFile := func(name, contents string) {
+		if err := os.WriteFile(filepath.Join(dataDir, name), []byte(contents), 0o600); err != nil {
+			t.Fatalf("failed to write test file: %v", err)
+		}
+	}
+	writeFile("corp.csv", geofeedTestData)
+	writeFile(geofeedsConfigFile, `{"geofeeds": [{"cloud": "CorpEgress", "file": "corp.csv", "defaultRegion": "us-east-2"}]}`)
+	if err := addGeofeedClouds(dataDir, cloudToITP); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := infoToPrefixes{
+		{Region: "us-east-2"}: []netip.Prefix{
+			netip.MustParsePrefix("192.0.2.0/24"),
+			netip.MustParsePrefix("198.51.100.0/24"),
+			netip.MustParsePrefix("2001:db8::/32"),
+			netip.MustParsePrefix("203.0.113.0/24"),
+		},
+	}
+	if !reflect.DeepEqual(cloudToITP["CorpEgress"], expected) {
+		t.Fatalf("result does not match, got: %v expected: %v", cloudToITP["CorpEgress"], expected)
+	}
+}
+
+func TestAddGeofeedCloudsInvalid(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Config string
+		// Files are written to the data directory, directories end in "/"
+		Files map[string]string
+	}{
+		{
+			Name:  "unreadable config",
+			Files: map[string]string{geofeedsConfigFile + "/": ""},
+		},
+		{
+			Name:   "invalid config",
+			Config: `{"geofeeds": [}`,
+		},
+		{
+			// geofeeds cannot replace existing clouds
+			Name:   "existing cloud",
+			Config: `{"geofeeds": [{"cloud": "AWS", "file": "corp.csv"}]}`,
+			Files:  map[string]string{"corp.csv": geofeedTestData},
+		},
+		{
+			Name:   "missing geofeed",
+			Config: `{"geofeeds": [{"cloud": "CorpEgress", "file": "corp.csv"}]}`,
+		},
+		{
+			Name:   "invalid geofeed",
+			Config: `{"geofeeds": [{"cloud": "CorpEgress", "file": "corp.csv"}]}`,
+			Files:  map[string]string{"corp.csv": "not-a-prefix/99,US\n"},
+		},
+	}
+	for _, tc := range testCases {
+		dataDir := t.TempDir()
+		if tc.Config != "" {
+			tc.Files = maps.Clone(tc.Files)
+			if tc.Files == nil {
+				tc.Files = map[string]string{}
+			}
+			tc.Files[geofeedsConfigFile] = tc.Config
+		}
+		for name, contents := range tc.Files {
+			var err error
+			if dir, ok := strings.CutSuffix(name, "/"); ok {
+				err = os.Mkdir(filepath.Join(dataDir, dir), 0o755)
+			} else {
+				err = os.WriteFile(filepath.Join(dataDir, name), []byte(contents), 0o600)
+			}
+			if err != nil {
+				t.Fatalf("failed to write test file: %v", err)
+			}
+		}
+		if err := addGeofeedClouds(dataDir, map[string]infoToPrefixes{"AWS": {}}); err == nil {
+			t.Errorf("%s: expected error but got none", tc.Name)
+		}
+	}
+}
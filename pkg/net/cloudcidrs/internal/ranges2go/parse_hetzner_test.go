@@ -0,0 +1,57 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestParseHetzner(t *testing.T) {
+	// entries without a region are grouped by country
+	const testData = `5.9.0.0/16,DE,,Falkenstein,
+65.21.0.0/16,FI,FI-18,Helsinki,
+2a01:4f8::/32,DE,,Falkenstein,
+5.161.0.0/16,US,US-VA,Ashburn,
+`
+	rtp, err := parseHetzner(testData)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := regionsToPrefixes{
+		"DE": []netip.Prefix{
+			netip.MustParsePrefix("2a01:4f8::/32"),
+			netip.MustParsePrefix("5.9.0.0/16"),
+		},
+		"FI-18": []netip.Prefix{
+			netip.MustParsePrefix("65.21.0.0/16"),
+		},
+		"US-VA": []netip.Prefix{
+			netip.MustParsePrefix("5.161.0.0/16"),
+		},
+	}
+	if !reflect.DeepEqual(rtp, expected) {
+		t.Fatalf("result does not match, got: %v expected: %v", rtp, expected)
+	}
+}
+
+func TestParseHetznerBadPrefix(t *testing.T) {
+	if _, err := parseHetzner("not-a-prefix/99,DE,,Falkenstein,\n"); err == nil {
+		t.Fatal("expected error parsing invalid prefix but got none")
+	}
+}
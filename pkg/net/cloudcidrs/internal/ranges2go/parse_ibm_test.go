@@ -0,0 +1,52 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestParseIBM(t *testing.T) {
+	const testData = `169.45.0.0/16,US,US-TX,Dallas,
+159.122.0.0/16,DE,DE-HE,Frankfurt,
+169.46.0.0/16,US,US-TX,Dallas,
+`
+	rtp, err := parseIBM(testData)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := regionsToPrefixes{
+		"DE-HE": []netip.Prefix{
+			netip.MustParsePrefix("159.122.0.0/16"),
+		},
+		"US-TX": []netip.Prefix{
+			netip.MustParsePrefix("169.45.0.0/16"),
+			netip.MustParsePrefix("169.46.0.0/16"),
+		},
+	}
+	if !reflect.DeepEqual(rtp, expected) {
+		t.Fatalf("result does not match, got: %v expected: %v", rtp, expected)
+	}
+}
+
+func TestParseIBMBadPrefix(t *testing.T) {
+	if _, err := parseIBM("not-a-prefix/99,US,US-TX,Dallas,\n"); err == nil {
+		t.Fatal("expected error parsing invalid prefix but got none")
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestParseLinode(t *testing.T) {
+	const testData = `# Linode geofeed
+45.33.0.0/22,US,US-CA,Fremont,
+2600:3c00::/32,US,US-TX,Richardson,
+45.79.0.0/21,US,US-TX,Richardson,
+`
+	rtp, err := parseLinode(testData)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := regionsToPrefixes{
+		"US-CA": []netip.Prefix{
+			netip.MustParsePrefix("45.33.0.0/22"),
+		},
+		"US-TX": []netip.Prefix{
+			netip.MustParsePrefix("2600:3c00::/32"),
+			netip.MustParsePrefix("45.79.0.0/21"),
+		},
+	}
+	if !reflect.DeepEqual(rtp, expected) {
+		t.Fatalf("result does not match, got: %v expected: %v", rtp, expected)
+	}
+}
+
+func TestParseLinodeBadPrefix(t *testing.T) {
+	if _, err := parseLinode("not-a-prefix/99,US,US-TX,Dallas,\n"); err == nil {
+		t.Fatal("expected error parsing invalid prefix but got none")
+	}
+}
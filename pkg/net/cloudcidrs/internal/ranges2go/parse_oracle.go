@@ -0,0 +1,77 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/netip"
+)
+
+// parseOracle parses raw Oracle Cloud public IP ranges JSON data
+// and processes it to a regionsToPrefixes map
+func parseOracle(raw string) (regionsToPrefixes, error) {
+	parsed, err := parseOraclePublicIPRangesJSON([]byte(raw))
+	if err != nil {
+		return nil, err
+	}
+	return oracleRegionsToPrefixesFromData(parsed)
+}
+
+/*
+	For more on this data see:
+	https://docs.oracle.com/en-us/iaas/Content/General/Concepts/addressranges.htm
+*/
+
+type OraclePublicIPRangesJSON struct {
+	Regions []OracleRegion `json:"regions"`
+	// last_updated_timestamp omitted
+}
+
+type OracleRegion struct {
+	Region string       `json:"region"`
+	CIDRs  []OracleCIDR `json:"cidrs"`
+}
+
+type OracleCIDR struct {
+	CIDR string `json:"cidr"`
+	// tags omitted
+}
+
+// parseOraclePublicIPRangesJSON parses Oracle Cloud public IP ranges JSON data
+func parseOraclePublicIPRangesJSON(rawJSON []byte) (*OraclePublicIPRangesJSON, error) {
+	r := &OraclePublicIPRangesJSON{}
+	if err := json.Unmarshal(rawJSON, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// oracleRegionsToPrefixesFromData processes the raw unmarshalled JSON into regionsToPrefixes map
+func oracleRegionsToPrefixesFromData(data *OraclePublicIPRangesJSON) (regionsToPrefixes, error) {
+	rtp := regionsToPrefixes{}
+	for _, region := range data.Regions {
+		for _, cidr := range region.CIDRs {
+			ipPrefix, err := netip.ParsePrefix(cidr.CIDR)
+			if err != nil {
+				return nil, err
+			}
+			rtp[region.Region] = append(rtp[region.Region], ipPrefix)
+		}
+	}
+	sortAndDedupePrefixes(rtp)
+	return rtp, nil
+}
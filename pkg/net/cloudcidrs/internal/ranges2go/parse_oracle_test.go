@@ -0,0 +1,88 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+const oracleTestData = `{
+  "last_updated_timestamp": "2026-07-27T21:17:00.621465",
+  "regions": [
+    {
+      "region": "us-phoenix-1",
+      "cidrs": [
+        {
+          "cidr": "129.146.0.0/21",
+          "tags": ["OCI"]
+        },
+        {
+          "cidr": "134.70.8.0/21",
+          "tags": ["OSN", "OBJECT_STORAGE"]
+        },
+        {
+          "cidr": "129.146.0.0/21",
+          "tags": ["OCI"]
+        }
+      ]
+    },
+    {
+      "region": "eu-frankfurt-1",
+      "cidrs": [
+        {
+          "cidr": "130.61.0.0/16",
+          "tags": ["OCI"]
+        }
+      ]
+    }
+  ]
+}`
+
+func TestParseOracle(t *testing.T) {
+	rtp, err := parseOracle(oracleTestData)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// duplicate prefixes must be deduped, and results must be sorted
+	expected := regionsToPrefixes{
+		"us-phoenix-1": []netip.Prefix{
+			netip.MustParsePrefix("129.146.0.0/21"),
+			netip.MustParsePrefix("134.70.8.0/21"),
+		},
+		"eu-frankfurt-1": []netip.Prefix{
+			netip.MustParsePrefix("130.61.0.0/16"),
+		},
+	}
+	if !reflect.DeepEqual(rtp, expected) {
+		t.Fatalf("result does not match, got: %v expected: %v", rtp, expected)
+	}
+}
+
+func TestParseOracleBadJSON(t *testing.T) {
+	if _, err := parseOracle(`{"regions": [}`); err == nil {
+		t.Fatal("expected error parsing invalid JSON but got none")
+	}
+}
+
+func TestParseOracleBadPrefix(t *testing.T) {
+	const badPrefixData = `{"regions": [{"region": "us-phoenix-1", "cidrs": [{"cidr": "not-a-prefix/99"}]}]}`
+	if _, err := parseOracle(badPrefixData); err == nil {
+		t.Fatal("expected error parsing invalid prefix but got none")
+	}
+}
@@ -16,7 +16,10 @@ limitations under the License.
 
 package main
 
-import "net/netip"
+import (
+	"net/netip"
+	"sort"
+)
 
 func dedupeSortedPrefixes(s []netip.Prefix) []netip.Prefix {
 	l := len(s)
@@ -35,3 +38,14 @@ func dedupeSortedPrefixes(s []netip.Prefix) []netip.Prefix {
 	}
 	return s[0:j]
 }
+
+// sortAndDedupePrefixes sorts and dedupes the prefixes for each key,
+// this approach allows us to produce consistent generated results
+func sortAndDedupePrefixes[K comparable, M ~map[K][]netip.Prefix](m M) {
+	for key := range m {
+		sort.Slice(m[key], func(i, j int) bool {
+			return m[key][i].String() < m[key][j].String()
+		})
+		m[key] = dedupeSortedPrefixes(m[key])
+	}
+}
@@ -22,3 +22,29 @@ import (
 
 // regionToPrefixes is the structure we process the JSON into
 type regionsToPrefixes map[string][]netip.Prefix
+
+// globalRegion is the region for prefixes that are not in any one region,
+// this must match cloudcidrs.GlobalRegion
+const globalRegion = "GLOBAL"
+
+// prefixInfo is the metadata we generate for prefixes, in addition to the cloud
+type prefixInfo struct {
+	Region string
+	// Service is the service using the prefixes, if known
+	Service string
+	// NetworkBorderGroup is the AWS network border group, if it is not the region
+	NetworkBorderGroup string
+}
+
+// infoToPrefixes is regionsToPrefixes with additional metadata
+type infoToPrefixes map[prefixInfo][]netip.Prefix
+
+// withRegionsOnly converts rtp to infoToPrefixes, for clouds that only
+// publish regions
+func withRegionsOnly(rtp regionsToPrefixes) infoToPrefixes {
+	itp := make(infoToPrefixes, len(rtp))
+	for region, prefixes := range rtp {
+		itp[prefixInfo{Region: region}] = prefixes
+	}
+	return itp
+}
@@ -19,4 +19,29 @@ package cloudcidrs
 type IPInfo struct {
 	Cloud  string
 	Region string
+	// Service is the cloud service using the IP, if known,
+	// e.g. "EC2", "CLOUDFRONT" or "GLOBALACCELERATOR" for AWS, or
+	// "Google Cloud" for GCP
+	Service string
+	// NetworkBorderGroup is the AWS network border group, if it is not the
+	// region, e.g. "us-west-2-lax-1" for a Local Zone
+	NetworkBorderGroup string
+}
+
+// GlobalRegion is the Region of prefixes that are not in any one region,
+// e.g. AWS CloudFront edge locations and Cloudflare anycast prefixes
+const GlobalRegion = "GLOBAL"
+
+// awsEdgeServices are AWS services whose prefixes are announced from edge
+// locations worldwide, even when they are listed under a region
+var awsEdgeServices = map[string]bool{
+	"CLOUDFRONT":        true,
+	"GLOBALACCELERATOR": true,
+}
+
+// IsGlobal returns true if the IP is not known to be in Region, either because
+// it is in GlobalRegion or because it is used by an edge network service,
+// so the client may be anywhere
+func (i IPInfo) IsGlobal() bool {
+	return i.Region == GlobalRegion || (i.Cloud == AWS && awsEdgeServices[i.Service])
 }
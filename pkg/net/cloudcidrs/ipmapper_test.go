@@ -67,6 +67,36 @@ func TestNewIPMapper(t *testing.T) {
 	}
 }
 
+func TestNewIPMapperInfo(t *testing.T) {
+	mapper := NewIPMapper()
+	// the service is generated for clouds which publish it
+	expected := IPInfo{Cloud: GCP, Region: "asia-east1", Service: "Google Cloud"}
+	if info, matched := mapper.GetIP(netip.MustParseAddr("104.155.192.1")); !matched || info != expected {
+		t.Fatalf("result does not match, got: (%+v, %t) expected: (%+v, true)", info, matched, expected)
+	}
+}
+
+func TestIPInfoIsGlobal(t *testing.T) {
+	testCases := []struct {
+		Info     IPInfo
+		Expected bool
+	}{
+		{Info: IPInfo{Cloud: AWS, Region: "GLOBAL", Service: "CLOUDFRONT"}, Expected: true},
+		{Info: IPInfo{Cloud: AWS, Region: "GLOBAL", Service: "AMAZON"}, Expected: true},
+		{Info: IPInfo{Cloud: AWS, Region: "eu-west-1", Service: "GLOBALACCELERATOR"}, Expected: true},
+		{Info: IPInfo{Cloud: AWS, Region: "eu-west-1", Service: "EC2"}, Expected: false},
+		{Info: IPInfo{Cloud: AWS, Region: "us-west-2", Service: "EC2", NetworkBorderGroup: "us-west-2-lax-1"}, Expected: false},
+		{Info: IPInfo{Cloud: GCP, Region: "europe-west1", Service: "Google Cloud"}, Expected: false},
+		{Info: IPInfo{Cloud: Cloudflare, Region: "GLOBAL"}, Expected: true},
+		{Info: IPInfo{Cloud: Hetzner, Region: "DE"}, Expected: false},
+	}
+	for _, tc := range testCases {
+		if global := tc.Info.IsGlobal(); global != tc.Expected {
+			t.Errorf("%+v: expected IsGlobal() %t but got %t", tc.Info, tc.Expected, global)
+		}
+	}
+}
+
 /*  for benchmarking memory / init time */
 
 func BenchmarkNewIPMapper(b *testing.B) {
@@ -0,0 +1,457 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+)
+
+// metadataStartMarker precedes the metadata map at the end of the database
+var metadataStartMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparatorSize is the size of the zeros between tree and data
+const dataSectionSeparatorSize = 16
+
+// maxDecodeDepth bounds nesting while decoding, so corrupt data cannot loop
+const maxDecodeDepth = 32
+
+// Location is the location of an IP, as found in GeoIP2 / GeoLite2
+// Country and City databases
+type Location struct {
+	// Country is the ISO 3166-1 alpha-2 country code, e.g. "FR"
+	Country string
+	// Continent is the two letter continent code, e.g. "EU"
+	Continent string
+}
+
+// Reader looks up IPs in a MaxMind DB database, it implements
+// cidrs.IPMapper[Location] and is safe for concurrent use
+type Reader struct {
+	buf        []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	// ipv4Start is the node for ::/96 in IPv6 databases,
+	// where IPv4 addresses are stored
+	ipv4Start uint
+}
+
+// NewReader parses a MaxMind DB database from b, which must not be modified
+// while the Reader is in use
+func NewReader(b []byte) (*Reader, error) {
+	metadataStart := bytes.LastIndex(b, metadataStartMarker)
+	if metadataStart == -1 {
+		return nil, errors.New("invalid MaxMind DB: metadata not found")
+	}
+	metadataStart += len(metadataStartMarker)
+	metadataDecoder := decoder{buf: b[metadataStart:]}
+	metadataValue, _, err := metadataDecoder.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MaxMind DB metadata: %w", err)
+	}
+	metadata, ok := metadataValue.(map[string]any)
+	if !ok {
+		return nil, errors.New("invalid MaxMind DB metadata: not a map")
+	}
+	r := &Reader{buf: b}
+	for key, field := range map[string]*uint{
+		"node_count":  &r.nodeCount,
+		"record_size": &r.recordSize,
+		"ip_version":  &r.ipVersion,
+	} {
+		v, ok := metadata[key].(uint64)
+		if !ok {
+			return nil, fmt.Errorf("invalid MaxMind DB metadata: missing %s", key)
+		}
+		*field = uint(v)
+	}
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported MaxMind DB record size: %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported MaxMind DB IP version: %d", r.ipVersion)
+	}
+	dataEnd := uint(metadataStart - len(metadataStartMarker))
+	// checked before multiplying, so a corrupt node_count cannot overflow
+	if r.nodeCount > dataEnd/(r.recordSize/4) {
+		return nil, errors.New("invalid MaxMind DB: search tree is larger than the database")
+	}
+	treeSize := r.nodeCount * r.recordSize / 4
+	dataStart := treeSize + dataSectionSeparatorSize
+	if dataStart > dataEnd {
+		return nil, errors.New("invalid MaxMind DB: search tree is larger than the database")
+	}
+	r.data = b[dataStart:dataEnd]
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readRecord(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// GetIP returns the location of ip, if the database has one
+func (r *Reader) GetIP(ip netip.Addr) (Location, bool) {
+	offset, ok := r.lookup(ip)
+	if !ok {
+		return Location{}, false
+	}
+	// records may hold much more, e.g. localized names in City databases,
+	// so only the fields we need are decoded
+	d := decoder{buf: r.data}
+	loc := Location{
+		Country:   d.stringAt(offset, "country", "iso_code"),
+		Continent: d.stringAt(offset, "continent", "code"),
+	}
+	if loc.Country == "" {
+		// e.g. satellite providers, where only the registration is known
+		loc.Country = d.stringAt(offset, "registered_country", "iso_code")
+	}
+	return loc, loc.Country != "" || loc.Continent != ""
+}
+
+// lookup returns the data section offset of the record for ip, if any
+func (r *Reader) lookup(ip netip.Addr) (uint, bool) {
+	ip = ip.Unmap()
+	node := uint(0)
+	var addr []byte
+	switch {
+	case ip.Is4() && r.ipVersion == 6:
+		node = r.ipv4Start
+		a := ip.As4()
+		addr = a[:]
+	case ip.Is4():
+		a := ip.As4()
+		addr = a[:]
+	case ip.Is6() && r.ipVersion == 6:
+		a := ip.As16()
+		addr = a[:]
+	default:
+		return 0, false
+	}
+	for i := 0; i < len(addr)*8 && node < r.nodeCount; i++ {
+		bit := uint(addr[i/8]>>(7-i%8)) & 1
+		node = r.readRecord(node, bit)
+	}
+	// node == nodeCount means no data for this IP
+	if node <= r.nodeCount {
+		return 0, false
+	}
+	offset := node - r.nodeCount - dataSectionSeparatorSize
+	return offset, offset < uint(len(r.data))
+}
+
+// readRecord reads the left (bit 0) or right (bit 1) record of node
+func (r *Reader) readRecord(node, bit uint) uint {
+	nodeSize := r.recordSize / 4
+	b := r.buf[node*nodeSize : (node+1)*nodeSize]
+	switch r.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		// the middle byte holds the high nibble of each record
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// data section field types
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEndMarker = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+// decoder decodes MaxMind DB data section values
+//
+// Values are decoded to string, float64, []byte, uint64, int64, bool,
+// map[string]any or []any, uint128 values are decoded as []byte.
+type decoder struct {
+	buf []byte
+}
+
+var errTruncated = errors.New("unexpected end of data")
+
+// decode decodes the value at offset, returning it and the offset after it
+func (d *decoder) decode(offset uint, depth int) (any, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, errors.New("data is nested too deeply")
+	}
+	typeNum, size, offset, err := d.decodeControl(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if typeNum == typePointer {
+		value, _, err := d.decode(size, depth+1)
+		return value, offset, err
+	}
+	switch typeNum {
+	case typeMap:
+		m := make(map[string]any, min(size, 64))
+		for range size {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			keyString, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			m[keyString], offset, err = d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, min(size, 64))
+		for range size {
+			var value any
+			value, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	case typeContainer, typeEndMarker:
+		return nil, offset, nil
+	}
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errTruncated
+	}
+	b := d.buf[offset : offset+size]
+	offset += size
+	switch typeNum {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes, typeUint128:
+		return b, offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size: %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size: %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("invalid unsigned integer size: %d", size)
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, offset, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid int32 size: %d", size)
+		}
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), offset, nil
+	default:
+		return nil, 0, fmt.Errorf("unknown data type: %d", typeNum)
+	}
+}
+
+// decodeControl decodes the control byte at offset, returning the type, the
+// payload size and the offset of the payload, or for pointers the offset
+// pointed to and the offset after the pointer
+func (d *decoder) decodeControl(offset uint) (uint, uint, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, 0, 0, errTruncated
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typeNum := uint(ctrl >> 5)
+	if typeNum == typePointer {
+		pointer, next, err := d.decodePointer(ctrl, offset)
+		return typeNum, pointer, next, err
+	}
+	if typeNum == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return 0, 0, 0, errTruncated
+		}
+		typeNum = 7 + uint(d.buf[offset])
+		offset++
+	}
+	size, offset, err := d.decodeSize(ctrl, offset)
+	return typeNum, size, offset, err
+}
+
+// skip returns the offset after the value at offset, without decoding it
+func (d *decoder) skip(offset uint, depth int) (uint, error) {
+	if depth > maxDecodeDepth {
+		return 0, errors.New("data is nested too deeply")
+	}
+	typeNum, size, offset, err := d.decodeControl(offset)
+	if err != nil {
+		return 0, err
+	}
+	switch typeNum {
+	case typePointer, typeBool, typeContainer, typeEndMarker:
+		return offset, nil
+	case typeMap:
+		// each entry is a key and a value
+		size *= 2
+		fallthrough
+	case typeArray:
+		for range size {
+			offset, err = d.skip(offset, depth+1)
+			if err != nil {
+				return 0, err
+			}
+		}
+		return offset, nil
+	}
+	if offset+size > uint(len(d.buf)) {
+		return 0, errTruncated
+	}
+	return offset + size, nil
+}
+
+// decodePath decodes the value at path in the nested maps at offset,
+// skipping over other values, it returns nil if there is no such value
+func (d *decoder) decodePath(offset uint, depth int, path ...string) (any, error) {
+	if len(path) == 0 {
+		value, _, err := d.decode(offset, depth)
+		return value, err
+	}
+	if depth > maxDecodeDepth {
+		return nil, errors.New("data is nested too deeply")
+	}
+	typeNum, size, offset, err := d.decodeControl(offset)
+	if err != nil {
+		return nil, err
+	}
+	switch typeNum {
+	case typePointer:
+		return d.decodePath(size, depth+1, path...)
+	case typeMap:
+	default:
+		return nil, nil
+	}
+	for range size {
+		key, next, err := d.decode(offset, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		keyString, ok := key.(string)
+		if !ok {
+			return nil, errors.New("map key is not a string")
+		}
+		if keyString == path[0] {
+			return d.decodePath(next, depth+1, path[1:]...)
+		}
+		offset, err = d.skip(next, depth+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// stringAt returns the value at path in the record at offset if it is a
+// string, or ""
+func (d *decoder) stringAt(offset uint, path ...string) string {
+	value, _ := d.decodePath(offset, 0, path...)
+	s, _ := value.(string)
+	return s
+}
+
+// decodeSize decodes the payload size following the control byte
+func (d *decoder) decodeSize(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1F)
+	if size < 29 {
+		return size, offset, nil
+	}
+	n := size - 28
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errTruncated
+	}
+	var v uint
+	for _, c := range d.buf[offset : offset+n] {
+		v = v<<8 | uint(c)
+	}
+	switch size {
+	case 29:
+		return 29 + v, offset + n, nil
+	case 30:
+		return 285 + v, offset + n, nil
+	default:
+		return 65821 + v, offset + n, nil
+	}
+}
+
+// decodePointer decodes a pointer, returning the data section offset it
+// points to, and the offset after it
+func (d *decoder) decodePointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint((ctrl>>3)&0x3) + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errTruncated
+	}
+	var v uint
+	if n != 4 {
+		v = uint(ctrl & 0x7)
+	}
+	for _, c := range d.buf[offset : offset+n] {
+		v = v<<8 | uint(c)
+	}
+	switch n {
+	case 2:
+		v += 2048
+	case 3:
+		v += 526336
+	}
+	return v, offset + n, nil
+}
@@ -0,0 +1,509 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"sort"
+	"strings"
+	"testing"
+
+	"k8s.io/registry.k8s.io/pkg/net/cidrs"
+)
+
+// ensure Reader is usable alongside the cloud IPMappers
+var _ cidrs.IPMapper[Location] = &Reader{}
+
+func TestReader(t *testing.T) {
+	records := map[string]map[string]any{
+		"35.180.0.0/16": {
+			"continent": map[string]any{"code": "EU", "geoname_id": uint32(6255148)},
+			"country":   map[string]any{"iso_code": "FR", "names": map[string]any{"en": "France"}},
+		},
+		"192.0.2.0/24": {
+			"continent":          map[string]any{"code": "NA"},
+			"registered_country": map[string]any{"iso_code": "US"},
+		},
+		"2001:db8::/32": {
+			"continent": map[string]any{"code": "OC"},
+			"country":   map[string]any{"iso_code": "AU"},
+		},
+		"198.51.100.0/24": {
+			"traits": map[string]any{"is_anycast": true},
+		},
+	}
+	for _, ipVersion := range []int{6, 4} {
+		for _, recordSize := range []int{24, 28, 32} {
+			db := buildTestDatabase(t, ipVersion, recordSize, records)
+			r, err := NewReader(db)
+			if err != nil {
+				t.Fatalf("failed to read database (ip_version %d, record_size %d): %v", ipVersion, recordSize, err)
+			}
+			testCases := []struct {
+				IP       string
+				Expected Location
+				Matches  bool
+			}{
+				{IP: "35.180.1.1", Expected: Location{Country: "FR", Continent: "EU"}, Matches: true},
+				{IP: "::ffff:35.180.1.1", Expected: Location{Country: "FR", Continent: "EU"}, Matches: true},
+				{IP: "35.181.1.1", Matches: false},
+				{IP: "192.0.2.1", Expected: Location{Country: "US", Continent: "NA"}, Matches: true},
+				{IP: "198.51.100.1", Matches: false},
+				{IP: "2001:db8::1", Expected: Location{Country: "AU", Continent: "OC"}, Matches: ipVersion == 6},
+				{IP: "2001:db9::1", Matches: false},
+			}
+			for _, tc := range testCases {
+				expected := tc.Expected
+				if !tc.Matches {
+					expected = Location{}
+				}
+				loc, matches := r.GetIP(netip.MustParseAddr(tc.IP))
+				if matches != tc.Matches || loc != expected {
+					t.Errorf("ip_version %d, record_size %d, %s: expected %+v, %v but got %+v, %v",
+						ipVersion, recordSize, tc.IP, expected, tc.Matches, loc, matches)
+				}
+			}
+		}
+	}
+}
+
+func TestNewReaderInvalid(t *testing.T) {
+	valid := buildTestDatabase(t, 6, 24, map[string]map[string]any{
+		"35.180.0.0/16": {"country": map[string]any{"iso_code": "FR"}},
+	})
+	// node_count * record_size overflows to a tree smaller than the database
+	hugeNodeCount := append(make([]byte, 80), testMetadata(map[string]any{"node_count": uint64(1 << 62), "record_size": uint16(32), "ip_version": uint16(4)})...)
+	testCases := []struct {
+		Name string
+		DB   []byte
+	}{
+		{Name: "empty", DB: []byte{}},
+		{Name: "no metadata", DB: valid[:bytes.LastIndex(valid, metadataStartMarker)]},
+		{Name: "truncated metadata", DB: valid[:len(valid)-4]},
+		{Name: "truncated tree", DB: valid[bytes.LastIndex(valid, metadataStartMarker)-20:]},
+		{Name: "node count overflows the tree size", DB: hugeNodeCount},
+		{Name: "missing data section separator", DB: append(make([]byte, 8), testMetadata(map[string]any{"node_count": uint32(1), "record_size": uint16(32), "ip_version": uint16(4)})...)},
+		{Name: "metadata is not a map", DB: testMetadata("metadata")},
+		{Name: "missing node count", DB: testMetadata(map[string]any{"record_size": uint16(24), "ip_version": uint16(6)})},
+		{Name: "unsupported record size", DB: testMetadata(map[string]any{"node_count": uint32(0), "record_size": uint16(16), "ip_version": uint16(6)})},
+		{Name: "unsupported IP version", DB: testMetadata(map[string]any{"node_count": uint32(0), "record_size": uint16(24), "ip_version": uint16(5)})},
+	}
+	for _, tc := range testCases {
+		if _, err := NewReader(tc.DB); err == nil {
+			t.Errorf("%s: expected error but got none", tc.Name)
+		}
+	}
+}
+
+// testMetadata returns a database with no search tree or data, and metadata
+func testMetadata(metadata any) []byte {
+	b := bytes.NewBuffer(bytes.Clone(metadataStartMarker))
+	writeTestValue(b, metadata)
+	return b.Bytes()
+}
+
+func TestReaderInvalidData(t *testing.T) {
+	// a single node IPv4 database, 0.0.0.0/1 points to data at offset,
+	// 128.0.0.0/1 has no data
+	database := func(offset uint32, data []byte) []byte {
+		const nodeCount = 1
+		b := &bytes.Buffer{}
+		_ = binary.Write(b, binary.BigEndian, nodeCount+dataSectionSeparatorSize+offset)
+		_ = binary.Write(b, binary.BigEndian, uint32(nodeCount))
+		b.Write(make([]byte, dataSectionSeparatorSize))
+		b.Write(data)
+		b.Write(testMetadata(map[string]any{"node_count": uint32(nodeCount), "record_size": uint16(32), "ip_version": uint16(4)}))
+		return b.Bytes()
+	}
+	testCases := []struct {
+		Name string
+		DB   []byte
+	}{
+		{Name: "data offset out of range", DB: database(10, []byte{0x40})},
+		{Name: "invalid data", DB: database(0, []byte{0x42, 'F'})},
+		{Name: "data is not a map", DB: database(0, []byte{0x42, 'F', 'R'})},
+	}
+	for _, tc := range testCases {
+		r, err := NewReader(tc.DB)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.Name, err)
+		}
+		if loc, matches := r.GetIP(netip.MustParseAddr("1.1.1.1")); matches {
+			t.Errorf("%s: expected no match but got %+v", tc.Name, loc)
+		}
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Data     []byte
+		Expected any
+	}{
+		{Name: "empty string", Data: []byte{0x40}, Expected: ""},
+		{Name: "uint16", Data: []byte{0xa2, 0x01, 0x02}, Expected: uint64(0x0102)},
+		{Name: "uint32 zero", Data: []byte{0xc0}, Expected: uint64(0)},
+		{Name: "int32 negative", Data: []byte{0x04, 0x01, 0xff, 0xff, 0xff, 0xff}, Expected: int64(-1)},
+		{Name: "bool true", Data: []byte{0x01, 0x07}, Expected: true},
+		{Name: "double", Data: []byte{0x68, 0x3f, 0xf0, 0, 0, 0, 0, 0, 0}, Expected: float64(1)},
+		{Name: "float", Data: []byte{0x04, 0x08, 0x3f, 0x80, 0, 0}, Expected: float64(1)},
+		{Name: "uint64", Data: []byte{0x01, 0x02, 0x01}, Expected: uint64(1)},
+		{
+			// a map whose value is a pointer to the key string at offset 1
+			Name:     "pointer",
+			Data:     []byte{0xe1, 0x41, 'a', 0x20, 0x01},
+			Expected: map[string]any{"a": "a"},
+		},
+		{
+			Name:     "pointer with a 4 byte offset",
+			Data:     []byte{0xe1, 0x41, 'a', 0x38, 0x00, 0x00, 0x00, 0x01},
+			Expected: map[string]any{"a": "a"},
+		},
+		{Name: "bytes", Data: []byte{0x82, 0x01, 0x02}, Expected: []byte{0x01, 0x02}},
+		{Name: "uint128", Data: []byte{0x01, 0x03, 0x01}, Expected: []byte{0x01}},
+		{Name: "container", Data: []byte{0x00, 0x05}, Expected: nil},
+		{Name: "end marker", Data: []byte{0x00, 0x06}, Expected: nil},
+		{Name: "29 byte string", Data: append([]byte{0x5d, 0x00}, bytes.Repeat([]byte{'a'}, 29)...), Expected: strings.Repeat("a", 29)},
+		{Name: "285 byte string", Data: append([]byte{0x5e, 0x00, 0x00}, bytes.Repeat([]byte{'a'}, 285)...), Expected: strings.Repeat("a", 285)},
+		{Name: "65821 byte string", Data: append([]byte{0x5f, 0x00, 0x00, 0x00}, bytes.Repeat([]byte{'a'}, 65821)...), Expected: strings.Repeat("a", 65821)},
+		{
+			Name:     "array",
+			Data:     []byte{0x02, 0x04, 0x41, 'a', 0x41, 'b'},
+			Expected: []any{"a", "b"},
+		},
+	}
+	for _, tc := range testCases {
+		d := decoder{buf: tc.Data}
+		value, _, err := d.decode(0, 0)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.Name, err)
+			continue
+		}
+		if !deepEqual(value, tc.Expected) {
+			t.Errorf("%s: expected %#v but got %#v", tc.Name, tc.Expected, value)
+		}
+	}
+}
+
+func TestDecoderInvalid(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Data []byte
+	}{
+		{Name: "empty", Data: []byte{}},
+		{Name: "truncated extended type", Data: []byte{0x00}},
+		{Name: "truncated pointer", Data: []byte{0x20}},
+		{Name: "2 byte pointer out of range", Data: []byte{0x28, 0x00, 0x00}},
+		{Name: "3 byte pointer out of range", Data: []byte{0x30, 0x00, 0x00, 0x00}},
+		{Name: "truncated size", Data: []byte{0x5e, 0x00}},
+		{Name: "truncated string", Data: []byte{0x42, 'a'}},
+		{Name: "truncated map key", Data: []byte{0xe1}},
+		{Name: "map key is not a string", Data: []byte{0xe1, 0xc0, 0x40}},
+		{Name: "truncated map value", Data: []byte{0xe1, 0x41, 'a'}},
+		{Name: "truncated array", Data: []byte{0x01, 0x04}},
+		{Name: "invalid double size", Data: []byte{0x61, 0x00}},
+		{Name: "invalid float size", Data: []byte{0x01, 0x08, 0x00}},
+		{Name: "invalid unsigned integer size", Data: append([]byte{0x09, 0x02}, make([]byte, 9)...)},
+		{Name: "invalid int32 size", Data: append([]byte{0x05, 0x01}, make([]byte, 5)...)},
+		{Name: "unknown type", Data: []byte{0x00, 0x09}},
+		// a pointer to itself must not recurse forever
+		{Name: "self referencing pointer", Data: []byte{0x20, 0x00}},
+	}
+	for _, tc := range testCases {
+		d := decoder{buf: tc.Data}
+		if value, _, err := d.decode(0, 0); err == nil {
+			t.Errorf("%s: expected error but got %#v", tc.Name, value)
+		}
+	}
+}
+
+func TestDecodePath(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Data     []byte
+		Path     []string
+		Expected any
+	}{
+		{
+			Name:     "nested value",
+			Data:     []byte{0xe1, 0x41, 'a', 0xe1, 0x41, 'b', 0x41, 'x'},
+			Path:     []string{"a", "b"},
+			Expected: "x",
+		},
+		{
+			// pointer, bool, map, array and uint16 values before the key
+			Name: "skips other values",
+			Data: []byte{
+				0xe6, 0x41, 'p', 0x20, 0x00, 0x41, 't', 0x01, 0x07,
+				0x41, 'm', 0xe1, 0x41, 'k', 0x41, 'v', 0x41, 'l', 0x01, 0x04, 0x41, 'v',
+				0x41, 's', 0xa1, 0x01, 0x41, 'a', 0x41, 'x',
+			},
+			Path:     []string{"a"},
+			Expected: "x",
+		},
+		{
+			Name:     "map by pointer",
+			Data:     []byte{0x20, 0x02, 0xe1, 0x41, 'a', 0x41, 'x'},
+			Path:     []string{"a"},
+			Expected: "x",
+		},
+		{Name: "missing key", Data: []byte{0xe1, 0x41, 'a', 0x41, 'x'}, Path: []string{"b"}},
+		{Name: "not a map", Data: []byte{0x41, 'x'}, Path: []string{"a"}},
+	}
+	for _, tc := range testCases {
+		d := decoder{buf: tc.Data}
+		value, err := d.decodePath(0, 0, tc.Path...)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.Name, err)
+			continue
+		}
+		if !deepEqual(value, tc.Expected) {
+			t.Errorf("%s: expected %#v but got %#v", tc.Name, tc.Expected, value)
+		}
+	}
+}
+
+func TestDecodePathInvalid(t *testing.T) {
+	deeplyNested := []byte{0xe1, 0x41, 'b'}
+	for range maxDecodeDepth + 1 {
+		deeplyNested = append(deeplyNested, 0x01, 0x04)
+	}
+	testCases := []struct {
+		Name string
+		Data []byte
+	}{
+		{Name: "empty", Data: []byte{}},
+		{Name: "truncated map key", Data: []byte{0xe1}},
+		{Name: "map key is not a string", Data: []byte{0xe1, 0xc0, 0x40}},
+		{Name: "truncated skipped value", Data: []byte{0xe1, 0x41, 'b'}},
+		{Name: "truncated skipped string", Data: []byte{0xe1, 0x41, 'b', 0x42, 'x'}},
+		{Name: "truncated skipped array", Data: []byte{0xe1, 0x41, 'b', 0x01, 0x04}},
+		{Name: "deeply nested skipped value", Data: deeplyNested},
+		{Name: "truncated value", Data: []byte{0xe1, 0x41, 'a', 0x42, 'x'}},
+		// a pointer to itself must not recurse forever
+		{Name: "self referencing pointer", Data: []byte{0x20, 0x00}},
+	}
+	for _, tc := range testCases {
+		d := decoder{buf: tc.Data}
+		if value, err := d.decodePath(0, 0, "a"); err == nil {
+			t.Errorf("%s: expected error but got %#v", tc.Name, value)
+		}
+	}
+}
+
+func FuzzDecoder(f *testing.F) {
+	f.Add([]byte{0xe1, 0x41, 'a', 0x20, 0x01})
+	f.Add([]byte{0x02, 0x04, 0x41, 'a', 0x41, 'b'})
+	f.Add([]byte{0x04, 0x01, 0xff, 0xff, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		d := decoder{buf: b}
+		// only checks that decoding terminates without panicking
+		_, _, _ = d.decode(0, 0)
+		_, _ = d.decodePath(0, 0, "a", "b")
+	})
+}
+
+func FuzzReader(f *testing.F) {
+	records := map[string]map[string]any{
+		"35.180.0.0/16": {"country": map[string]any{"iso_code": "FR"}},
+		"2001:db8::/32": {"continent": map[string]any{"code": "OC"}},
+	}
+	for _, ipVersion := range []int{6, 4} {
+		for _, recordSize := range []int{24, 28, 32} {
+			f.Add(buildTestDatabase(f, ipVersion, recordSize, records))
+		}
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		r, err := NewReader(b)
+		if err != nil {
+			return
+		}
+		// only checks that lookups do not panic on corrupt databases
+		for _, ip := range []string{"35.180.1.1", "2001:db8::1", "0.0.0.0", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"} {
+			_, _ = r.GetIP(netip.MustParseAddr(ip))
+		}
+	})
+}
+
+func deepEqual(a, b any) bool {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			if !deepEqual(v, b[k]) {
+				return false
+			}
+		}
+		return true
+	case []byte:
+		b, ok := b.([]byte)
+		return ok && bytes.Equal(a, b)
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !deepEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// buildTestDatabase writes a MaxMind DB mapping prefixes to records
+func buildTestDatabase(t testing.TB, ipVersion, recordSize int, records map[string]map[string]any) []byte {
+	t.Helper()
+	const (
+		empty = -1
+		// data records are stored as dataRecord - offset into data
+		dataRecord = -2
+	)
+	// nodes hold child node indexes, empty, or a data reference
+	nodes := [][2]int{{empty, empty}}
+	data := &bytes.Buffer{}
+	prefixes := make([]string, 0, len(records))
+	for prefix := range records {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, p := range prefixes {
+		prefix := netip.MustParsePrefix(p)
+		bits := prefix.Bits()
+		var addr []byte
+		if prefix.Addr().Is4() {
+			a := prefix.Addr().As4()
+			addr = a[:]
+			if ipVersion == 6 {
+				addr = append(make([]byte, 12), addr...)
+				bits += 96
+			}
+		} else {
+			if ipVersion == 4 {
+				continue
+			}
+			a := prefix.Addr().As16()
+			addr = a[:]
+		}
+		offset := data.Len()
+		writeTestValue(data, records[p])
+		node := 0
+		for i := 0; i < bits; i++ {
+			bit := int(addr[i/8]>>(7-i%8)) & 1
+			if i == bits-1 {
+				nodes[node][bit] = dataRecord - offset
+				break
+			}
+			if nodes[node][bit] == empty {
+				nodes = append(nodes, [2]int{empty, empty})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+	nodeCount := len(nodes)
+	out := &bytes.Buffer{}
+	recordValue := func(v int) uint32 {
+		switch {
+		case v == empty:
+			return uint32(nodeCount)
+		case v <= dataRecord:
+			return uint32(nodeCount + dataSectionSeparatorSize + dataRecord - v)
+		default:
+			return uint32(v)
+		}
+	}
+	for _, node := range nodes {
+		left, right := recordValue(node[0]), recordValue(node[1])
+		switch recordSize {
+		case 24:
+			out.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left)})
+			out.Write([]byte{byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			out.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left)})
+			out.WriteByte(byte((left>>24)&0x0F)<<4 | byte((right>>24)&0x0F))
+			out.Write([]byte{byte(right >> 16), byte(right >> 8), byte(right)})
+		case 32:
+			_ = binary.Write(out, binary.BigEndian, left)
+			_ = binary.Write(out, binary.BigEndian, right)
+		}
+	}
+	out.Write(make([]byte, dataSectionSeparatorSize))
+	out.Write(data.Bytes())
+	out.Write(metadataStartMarker)
+	writeTestValue(out, map[string]any{
+		"node_count":    uint32(nodeCount),
+		"record_size":   uint16(recordSize),
+		"ip_version":    uint16(ipVersion),
+		"database_type": "Test-Country",
+	})
+	return out.Bytes()
+}
+
+// writeTestValue writes v in the data section format,
+// supporting the types used in buildTestDatabase
+func writeTestValue(w *bytes.Buffer, v any) {
+	writeControl := func(typeNum, size int) {
+		if typeNum <= 7 {
+			w.WriteByte(byte(typeNum<<5 | size))
+		} else {
+			w.WriteByte(byte(size))
+			w.WriteByte(byte(typeNum - 7))
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		writeControl(typeString, len(v))
+		w.WriteString(v)
+	case uint16:
+		writeControl(typeUint16, 2)
+		_ = binary.Write(w, binary.BigEndian, v)
+	case uint32:
+		writeControl(typeUint32, 4)
+		_ = binary.Write(w, binary.BigEndian, v)
+	case uint64:
+		writeControl(typeUint64, 8)
+		_ = binary.Write(w, binary.BigEndian, v)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		writeControl(typeBool, size)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		writeControl(typeMap, len(keys))
+		for _, k := range keys {
+			writeTestValue(w, k)
+			writeTestValue(w, v[k])
+		}
+	default:
+		panic("unsupported test value type")
+	}
+}